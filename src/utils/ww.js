@@ -35,11 +35,16 @@ const loadWasmModule = memoize(async (wasmUrl) => {
   return instance
 })
 
-const processHTML = (html) => loadWasmModule('/parser.wasm').then(() => globals.VMailParser(html))
-const inlineCSS = (html) => loadWasmModule('/inliner.wasm').then(() => globals.VMailInliner(html))
+const processHTML = (html, options = {}) =>
+  loadWasmModule('/parser.wasm').then(() => globals.VMailParser(html, options))
+const processTemplate = (template, options = {}) =>
+  loadWasmModule('/parser.wasm').then(() => globals.VMailTemplateParser(template, options))
+const inlineCSS = (html, options = {}) =>
+  loadWasmModule('/inliner.wasm').then(() => globals.VMailInliner(html, options))
 
 expose({
   processHTML,
+  processTemplate,
   inlineCSS,
   clientsListWithStats
 })
@@ -2,7 +2,10 @@ module github.com/le0pard/vmail/wasm_inliner
 
 go 1.25.0
 
-require github.com/le0pard/vmail/wasm_inliner/inliner v0.0.0-20260418123752-128b1209f74d
+require (
+	github.com/le0pard/vmail/wasm_inliner/inliner v0.0.0-20260418123752-128b1209f74d
+	github.com/le0pard/vmail/wasm_shared/includes v0.0.0-00010101000000-000000000000
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
@@ -11,3 +14,5 @@ require (
 )
 
 replace github.com/le0pard/vmail/wasm_inliner/inliner => ./inliner
+
+replace github.com/le0pard/vmail/wasm_shared/includes => ../wasm_shared/includes
@@ -0,0 +1,405 @@
+package inliner
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	parse "github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+
+	"github.com/andybalholm/cascadia"
+
+	"golang.org/x/net/html"
+)
+
+// origins of computed declarations
+const (
+	STYLE_ORIGIN_INLINE     = "inline"
+	STYLE_ORIGIN_STYLESHEET = "stylesheet"
+	STYLE_ORIGIN_ATTRIBUTE  = "attribute"
+	STYLE_ORIGIN_INHERITED  = "inherited"
+)
+
+var (
+	// properties, which inherited by children elements
+	inheritedCssProperties = map[string]bool{
+		"border-collapse": true, "border-spacing": true, "caption-side": true, "color": true, "cursor": true,
+		"direction": true, "empty-cells": true, "font": true, "font-family": true, "font-size": true,
+		"font-style": true, "font-variant": true, "font-weight": true, "letter-spacing": true, "line-height": true,
+		"list-style": true, "list-style-image": true, "list-style-position": true, "list-style-type": true,
+		"quotes": true, "text-align": true, "text-indent": true, "text-transform": true, "visibility": true,
+		"white-space": true, "word-spacing": true,
+	}
+	// html attributes, which work as css declarations
+	presentationalAttributes = map[string]string{
+		"align":      "text-align",
+		"background": "background-image",
+		"bgcolor":    "background-color",
+		"height":     "height",
+		"valign":     "vertical-align",
+		"width":      "width",
+	}
+	// presentational attributes of specific elements
+	presentationalElementAttributes = map[string]map[string]string{
+		"body": {"text": "color"},
+		"font": {"color": "color", "face": "font-family"},
+	}
+	numericAttributeRe = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+// StyleDeclaration is declaration, which win the cascade for element
+type StyleDeclaration struct {
+	Property      string               `json:"property"`
+	Value         string               `json:"value"`
+	Important     bool                 `json:"important"`
+	Origin        string               `json:"origin"`
+	Selector      string               `json:"selector,omitempty"`  // stylesheet rule selector
+	Specificity   cascadia.Specificity `json:"specificity"`         // stylesheet rule specificity
+	Attribute     string               `json:"attribute,omitempty"` // html attribute
+	Line          int                  `json:"line"`                // line of rule, attribute or inline style in document
+	File          string               `json:"file,omitempty"`      // included partial, empty for main document
+	InheritedFrom string               `json:"inherited_from,omitempty"`
+
+	order int
+}
+
+// ElementStyle is computed style of element
+type ElementStyle struct {
+	Path         string             `json:"path"` // selector, which match only this element
+	Tag          string             `json:"tag"`
+	Line         int                `json:"line"`
+	File         string             `json:"file,omitempty"`
+	Declarations []StyleDeclaration `json:"declarations"` // sorted by property
+	Node         *html.Node         `json:"-"`
+}
+
+// Get return computed declaration of element by property name
+func (es ElementStyle) Get(property string) (StyleDeclaration, bool) {
+	property = strings.ToLower(property)
+	index := sort.Search(len(es.Declarations), func(i int) bool { return es.Declarations[i].Property >= property })
+	if index < len(es.Declarations) && es.Declarations[index].Property == property {
+		return es.Declarations[index], true
+	}
+	return StyleDeclaration{}, false
+}
+
+type ComputedStyles struct {
+	Elements []ElementStyle `json:"elements"` // in document order
+}
+
+// Match return computed styles of elements, matched by css selector
+func (cs *ComputedStyles) Match(selector string) ([]ElementStyle, error) {
+	sel, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []ElementStyle
+	for _, element := range cs.Elements {
+		if sel.Match(element.Node) {
+			elements = append(elements, element)
+		}
+	}
+	return elements, nil
+}
+
+type styleRule struct {
+	selector     cascadia.Sel
+	key          string
+	declarations []StyleDeclaration
+}
+
+// isHigherThan compare declarations by cascade: importance, origin, specificity and order
+func (decl StyleDeclaration) isHigherThan(other StyleDeclaration) bool {
+	if decl.Important != other.Important {
+		return decl.Important
+	}
+	originRank := map[string]int{STYLE_ORIGIN_ATTRIBUTE: 0, STYLE_ORIGIN_STYLESHEET: 1, STYLE_ORIGIN_INLINE: 2}
+	if originRank[decl.Origin] != originRank[other.Origin] {
+		return originRank[decl.Origin] > originRank[other.Origin]
+	}
+	if decl.Specificity != other.Specificity {
+		return other.Specificity.Less(decl.Specificity)
+	}
+	return decl.order > other.order
+}
+
+// parseDeclarationValue join values without "!important" flag
+func parseDeclarationValue(values []css.Token) (string, bool) {
+	value, important := "", false
+	for i := 0; i < len(values); i++ {
+		if values[i].TokenType == css.DelimToken && string(values[i].Data) == "!" &&
+			i+1 < len(values) && strings.ToLower(string(values[i+1].Data)) == "important" {
+			important = true
+			i += 1
+			continue
+		}
+		value += string(values[i].Data)
+	}
+	return strings.Trim(value, WHITESPACE), important
+}
+
+// collectStyleRules parse style element into rules, rules inside at-rules are not applied (same as for inlining)
+func (inlr *InlineEngine) collectStyleRules(content string, line int, order *int) []styleRule {
+	var (
+		rules    []styleRule
+		selector string
+		depth    int
+		current  []StyleDeclaration
+	)
+
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(content)), false)
+	for {
+		gt, _, data := p.Next()
+		if gt == css.ErrorGrammar {
+			return rules
+		}
+		inlr.traceCssGrammar(gt, data, p, content, []stylesheetLine{{line: line}})
+
+		switch gt {
+		case css.BeginAtRuleGrammar:
+			depth += 1
+		case css.EndAtRuleGrammar:
+			depth -= 1
+		case css.QualifiedRuleGrammar, css.BeginRulesetGrammar:
+			selector += string(data)
+			for _, val := range p.Values() {
+				selector += string(val.Data)
+			}
+			if gt == css.QualifiedRuleGrammar {
+				selector += ","
+			}
+			current = nil
+		case css.DeclarationGrammar, css.CustomPropertyGrammar:
+			value, important := parseDeclarationValue(p.Values())
+			*order += 1
+			offset := min(p.Offset(), len(content))
+			current = append(current, StyleDeclaration{
+				Property:  strings.ToLower(string(data)),
+				Value:     value,
+				Important: important,
+				Origin:    STYLE_ORIGIN_STYLESHEET,
+				Line:      line + strings.Count(content[:offset], "\n"),
+				order:     *order,
+			})
+		case css.EndRulesetGrammar:
+			group, err := cascadia.ParseGroup(selector)
+			if depth > 0 || err != nil {
+				inlr.traceSelector(TRACE_SELECTOR_SKIP, "invalid", selector, 0)
+			} else {
+				for _, sel := range group {
+					if len(sel.PseudoElement()) > 0 {
+						inlr.traceSelector(TRACE_SELECTOR_SKIP, "pseudo", sel.String(), 0)
+						continue
+					}
+					rules = append(rules, styleRule{selector: sel, key: strings.Join(strings.Fields(sel.String()), " "), declarations: current})
+				}
+			}
+			selector, current = "", nil
+		}
+	}
+}
+
+func isStyleMediaApplied(node *html.Node) bool {
+	for _, attr := range node.Attr {
+		if strings.ToLower(attr.Key) != "media" || len(strings.Trim(attr.Val, WHITESPACE)) == 0 {
+			continue
+		}
+		for _, mediaPart := range mediaSplitInlineRe.Split(strings.ToLower(attr.Val), -1) {
+			if mediaInlineRe.MatchString(strings.Trim(mediaPart, WHITESPACE)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func attributeDeclaration(attribute, property, value string, line int) StyleDeclaration {
+	value = strings.Trim(value, WHITESPACE)
+	switch {
+	case property == "background-image":
+		value = "url(" + value + ")"
+	case (property == "width" || property == "height") && numericAttributeRe.MatchString(value):
+		value += "px"
+	}
+	return StyleDeclaration{Property: property, Value: value, Origin: STYLE_ORIGIN_ATTRIBUTE, Attribute: attribute, Line: line}
+}
+
+// elementPath build selector with tag names and positions from root
+func elementPath(node *html.Node) string {
+	var parts []string
+	for ; node != nil && node.Type == html.ElementNode; node = node.Parent {
+		position := 1
+		for sibling := node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+			if sibling.Type == html.ElementNode {
+				position += 1
+			}
+		}
+		part := node.Data
+		if node.Parent != nil && node.Parent.Type == html.ElementNode {
+			part += ":nth-child(" + strconv.Itoa(position) + ")"
+		}
+		parts = append([]string{part}, parts...)
+	}
+	return strings.Join(parts, " > ")
+}
+
+// cascade find winning declarations of element
+func cascade(node *html.Node, rules []styleRule, line int) map[string]StyleDeclaration {
+	winners := make(map[string]StyleDeclaration)
+	apply := func(decl StyleDeclaration) {
+		if current, ok := winners[decl.Property]; !ok || decl.isHigherThan(current) {
+			winners[decl.Property] = decl
+		}
+	}
+
+	for _, attr := range node.Attr {
+		key := strings.ToLower(attr.Key)
+		if property, ok := presentationalElementAttributes[node.Data][key]; ok {
+			apply(attributeDeclaration(key, property, attr.Val, line))
+		} else if property, ok := presentationalAttributes[key]; ok {
+			apply(attributeDeclaration(key, property, attr.Val, line))
+		}
+	}
+
+	for _, rule := range rules {
+		if !rule.selector.Match(node) {
+			continue
+		}
+		for _, decl := range rule.declarations {
+			decl.Selector = rule.key
+			decl.Specificity = rule.selector.Specificity()
+			apply(decl)
+		}
+	}
+
+	for _, attr := range node.Attr {
+		if strings.ToLower(attr.Key) != "style" {
+			continue
+		}
+		p := css.NewParser(parse.NewInput(bytes.NewBufferString(attr.Val)), true)
+		for order := 0; ; order++ {
+			gt, _, data := p.Next()
+			if gt == css.ErrorGrammar {
+				break
+			}
+			if gt != css.DeclarationGrammar && gt != css.CustomPropertyGrammar {
+				continue
+			}
+			value, important := parseDeclarationValue(p.Values())
+			apply(StyleDeclaration{
+				Property:  strings.ToLower(string(data)),
+				Value:     value,
+				Important: important,
+				Origin:    STYLE_ORIGIN_INLINE,
+				Line:      line,
+				order:     order,
+			})
+		}
+	}
+	return winners
+}
+
+// ComputeStyles return cascaded declarations with origins for each element of html document
+func (inlr *InlineEngine) ComputeStyles(htmlDoc []byte) (*ComputedStyles, error) {
+	var (
+		err   error
+		rules []styleRule
+		order int
+		lines = make(map[*html.Node]int)
+		walk  func(node *html.Node)
+	)
+
+	if inlr.options.Includes != nil {
+		if htmlDoc, inlr.includeLines, err = resolveIncludes(htmlDoc, inlr.options.Includes); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := html.Parse(bytes.NewReader(annotateLines(htmlDoc)))
+	if err != nil {
+		return nil, err
+	}
+
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			startLine, endLine := takeNodeLines(node)
+			lines[node] = startLine
+			if node.Data == "style" && isStyleMediaApplied(node) && node.FirstChild != nil && node.FirstChild.Type == html.TextNode {
+				rules = append(rules, inlr.collectStyleRules(node.FirstChild.Data, endLine, &order)...)
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	computed := &ComputedStyles{}
+	var compute func(node *html.Node, inherited map[string]StyleDeclaration)
+	compute = func(node *html.Node, inherited map[string]StyleDeclaration) {
+		if node.Type == html.ElementNode {
+			path := elementPath(node)
+			winners := cascade(node, rules, lines[node])
+
+			declarations := make(map[string]StyleDeclaration, len(winners)+len(inherited))
+			for property, decl := range inherited {
+				declarations[property] = decl
+			}
+			for property, decl := range winners {
+				if strings.EqualFold(decl.Value, "inherit") {
+					continue
+				}
+				declarations[property] = decl
+			}
+
+			element := ElementStyle{Path: path, Tag: node.Data, Line: lines[node], Node: node}
+			for _, decl := range declarations {
+				element.Declarations = append(element.Declarations, decl)
+			}
+			sort.Slice(element.Declarations, func(i, j int) bool {
+				return element.Declarations[i].Property < element.Declarations[j].Property
+			})
+			computed.Elements = append(computed.Elements, element)
+
+			inherited = make(map[string]StyleDeclaration)
+			for property, decl := range declarations {
+				if !inheritedCssProperties[property] && !strings.HasPrefix(property, "--") {
+					continue
+				}
+				if decl.Origin != STYLE_ORIGIN_INHERITED {
+					decl.Origin, decl.InheritedFrom = STYLE_ORIGIN_INHERITED, path
+				}
+				decl.Important = false
+				inherited[property] = decl
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			compute(child, inherited)
+		}
+	}
+	compute(doc, nil)
+
+	for i := range computed.Elements {
+		element := &computed.Elements[i]
+		element.File, element.Line = inlr.originFile(element.Line), inlr.originLine(element.Line)
+		for j := range element.Declarations {
+			decl := &element.Declarations[j]
+			decl.File, decl.Line = inlr.originFile(decl.Line), inlr.originLine(decl.Line)
+		}
+	}
+
+	return computed, nil
+}
+
+func ComputeStylesInHTML(htmlDoc []byte) (*ComputedStyles, error) {
+	return ComputeStylesInHTMLWithOptions(htmlDoc, InlinerOptions{})
+}
+
+func ComputeStylesInHTMLWithOptions(htmlDoc []byte, options InlinerOptions) (*ComputedStyles, error) {
+	return InitInlinerWithOptions(options).ComputeStyles(htmlDoc)
+}
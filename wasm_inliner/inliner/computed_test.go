@@ -0,0 +1,97 @@
+package inliner
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestComputeStylesInHTML(t *testing.T) {
+	htmlDoc := `<html>
+<head>
+	<style>
+		p { color: red; font-size: 14px; }
+		.note { color: blue; }
+		#main p { color: green; }
+		.warn { color: orange !important; }
+		@media (max-width: 600px) {
+			p { color: black; }
+		}
+	</style>
+</head>
+<body text="#333333">
+	<table id="main" bgcolor="#ffffff" width="600"><tr><td>
+		<p class="note">Note</p>
+		<p class="warn" style="color: purple; margin: 0">Warning</p>
+		<span>Text</span>
+	</td></tr></table>
+</body></html>`
+
+	styles, err := ComputeStylesInHTML([]byte(htmlDoc))
+	if err != nil {
+		t.Fatalf("ComputeStylesInHTML: %v", err)
+	}
+
+	var tests = []struct {
+		selector string
+		property string
+		want     StyleDeclaration
+	}{
+		{"body", "color", StyleDeclaration{Value: "#333333", Origin: STYLE_ORIGIN_ATTRIBUTE, Attribute: "text", Line: 13}},
+		{"table", "width", StyleDeclaration{Value: "600px", Origin: STYLE_ORIGIN_ATTRIBUTE, Attribute: "width", Line: 14}},
+		{"p.note", "color", StyleDeclaration{Value: "green", Origin: STYLE_ORIGIN_STYLESHEET, Selector: "#main p", Specificity: [3]int{1, 0, 1}, Line: 6}},
+		{"p.note", "font-size", StyleDeclaration{Value: "14px", Origin: STYLE_ORIGIN_STYLESHEET, Selector: "p", Specificity: [3]int{0, 0, 1}, Line: 4}},
+		{"p.warn", "color", StyleDeclaration{Value: "orange", Important: true, Origin: STYLE_ORIGIN_STYLESHEET, Selector: ".warn", Specificity: [3]int{0, 1, 0}, Line: 7}},
+		{"p.warn", "margin", StyleDeclaration{Value: "0", Origin: STYLE_ORIGIN_INLINE, Line: 16}},
+		{"span", "color", StyleDeclaration{Value: "#333333", Origin: STYLE_ORIGIN_INHERITED, Attribute: "text", Line: 13, InheritedFrom: "html > body:nth-child(2)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector+" "+tt.property, func(t *testing.T) {
+			elements, err := styles.Match(tt.selector)
+			if err != nil || len(elements) != 1 {
+				t.Fatalf("Match(%q) = %d elements, error %v", tt.selector, len(elements), err)
+			}
+			got, ok := elements[0].Get(tt.property)
+			if !ok {
+				t.Fatalf("Get(%q): not found in %+v", tt.property, elements[0].Declarations)
+			}
+			tt.want.Property = tt.property
+			got.order = 0
+			if got != tt.want {
+				t.Errorf("Get(%q) = %+v, want %+v", tt.property, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStylesWithIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"styles.html": {Data: []byte("<style>\n.title { color: red; }\n</style>")},
+		"title.hbs":   {Data: []byte("\n<p class=\"title\" width=\"100\">Hello</p>")},
+	}
+	htmlDoc := "<html>\n<head>\n<!-- include \"styles.html\" -->\n</head>\n<body>\n{{> title}}\n</body></html>"
+
+	styles, err := ComputeStylesInHTMLWithOptions([]byte(htmlDoc), InlinerOptions{Includes: fsys})
+	if err != nil {
+		t.Fatalf("ComputeStylesInHTMLWithOptions: %v", err)
+	}
+
+	elements, err := styles.Match("p.title")
+	if err != nil || len(elements) != 1 {
+		t.Fatalf("Match(p.title) = %d elements, error %v", len(elements), err)
+	}
+	if elements[0].File != "title.hbs" || elements[0].Line != 2 {
+		t.Errorf("element origin: got %s:%d, want title.hbs:2", elements[0].File, elements[0].Line)
+	}
+	if decl, _ := elements[0].Get("color"); decl.File != "styles.html" || decl.Line != 2 {
+		t.Errorf("color origin: got %s:%d, want styles.html:2", decl.File, decl.Line)
+	}
+	if decl, _ := elements[0].Get("width"); decl.File != "title.hbs" || decl.Line != 2 {
+		t.Errorf("width origin: got %s:%d, want title.hbs:2", decl.File, decl.Line)
+	}
+
+	elements, _ = styles.Match("body")
+	if len(elements) != 1 || elements[0].File != "" || elements[0].Line != 5 {
+		t.Errorf("body origin: got %+v, want main document line 5", elements)
+	}
+}
@@ -0,0 +1,84 @@
+package inliner
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInlineCssWithIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"styles.html":          {Data: []byte("<style>.title { color: red; }</style>")},
+		"title.hbs":            {Data: []byte(`<p class="title">Hello</p>`)},
+		"a.html":               {Data: []byte(`<!-- include "b.html" -->`)},
+		"b.html":               {Data: []byte(`<!-- include "a.html" -->`)},
+		"partials/layout.html": {Data: []byte(`<div>{{> button}}</div>`)},
+		"partials/button.hbs":  {Data: []byte(`<a class="title">Buy</a>`)},
+	}
+
+	var tests = []struct {
+		name    string
+		html    string
+		want    string
+		wantErr string
+	}{
+		{
+			"include and partial",
+			`<html><head><!-- include "styles.html" --></head><body>{{> title}}</body></html>`,
+			`<p class="title" style="color:red;">Hello</p>`,
+			"",
+		},
+		{
+			"nested partial relative to including file",
+			`<html><head><!-- include "styles.html" --></head><body><!-- include "partials/layout.html" --></body></html>`,
+			`<a class="title" style="color:red;">Buy</a>`,
+			"",
+		},
+		{
+			"cycle",
+			`<html><body><!-- include "a.html" --></body></html>`,
+			"",
+			"include cycle: (document) -> a.html -> b.html -> a.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InlineCssInHTMLWithOptions([]byte(tt.html), InlinerOptions{Includes: fsys})
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InlineCssInHTMLWithOptions() error = %v", err)
+			}
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("expected %q in %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInlineCssTraceWithIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"styles.html": {Data: []byte("<style>\n.title { color: red; }\n</style>")},
+	}
+	htmlDoc := "<html>\n<head>\n<!-- include \"styles.html\" -->\n</head>\n<body><p class=\"title\">Hello</p></body></html>"
+
+	collector := &traceCollector{}
+	if _, err := InlineCssInHTMLWithOptions([]byte(htmlDoc), InlinerOptions{Includes: fsys, Trace: collector}); err != nil {
+		t.Fatalf("InlineCssInHTMLWithOptions() error = %v", err)
+	}
+
+	for _, event := range collector.events {
+		if event.Kind == TRACE_CSS_GRAMMAR && event.Data == ".title" {
+			if event.File != "styles.html" || event.Line != 2 {
+				t.Errorf("trace event origin: got %s:%d, want styles.html:2", event.File, event.Line)
+			}
+			return
+		}
+	}
+	t.Errorf("trace event for .title not found in %v", collector.events)
+}
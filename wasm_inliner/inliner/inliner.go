@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -31,9 +32,11 @@ var (
 )
 
 type StylesheetsTags struct {
-	Parent  *html.Node
-	Node    *html.Node
-	Content string
+	Parent   *html.Node
+	Node     *html.Node
+	Content  string
+	Line     int // line of content start in document, if lines are annotated
+	External bool
 }
 
 type CSSGroupSelectors struct {
@@ -52,10 +55,27 @@ type InlineEngine struct {
 	wg sync.WaitGroup
 	// lock for report
 	mx sync.RWMutex
+	// configuration
+	options InlinerOptions
+	// origins of lines, if includes resolved
+	includeLines []lineOrigin
+}
+
+type InlinerOptions struct {
+	// receive css grammar events and selectors matches, disabled if nil
+	Trace TraceSink
+	// template root for <!-- include "file.html" --> and {{> partial}} directives, disabled if nil
+	Includes fs.FS
 }
 
 func InitInliner() *InlineEngine {
-	return &InlineEngine{}
+	return InitInlinerWithOptions(InlinerOptions{})
+}
+
+func InitInlinerWithOptions(options InlinerOptions) *InlineEngine {
+	return &InlineEngine{
+		options: options,
+	}
 }
 
 func indexOf(data []string, element string) int {
@@ -67,10 +87,12 @@ func indexOf(data []string, element string) int {
 	return -1 //not found
 }
 
-func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, string, error) {
+func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, string, []stylesheetLine, error) {
 	var (
 		externalStylesheets []StylesheetsTags
 		contents            []string
+		sheetLines          []stylesheetLine
+		offset              int
 		head                *html.Node = nil
 		body                *html.Node = nil
 		crawler             func(*html.Node)
@@ -81,6 +103,8 @@ func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, stri
 
 	crawler = func(node *html.Node) {
 		if node.Type == html.ElementNode {
+			startLine, endLine := takeNodeLines(node)
+
 			if node.Data == "head" && head == nil {
 				head = node
 			}
@@ -114,6 +138,7 @@ func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, stri
 						Parent:  node.Parent,
 						Node:    node,
 						Content: node.FirstChild.Data,
+						Line:    endLine,
 					})
 				}
 			}
@@ -168,9 +193,11 @@ func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, stri
 
 					if len(body) > 0 {
 						externalStylesheets = append(externalStylesheets, StylesheetsTags{
-							Parent:  node.Parent,
-							Node:    node,
-							Content: string(body),
+							Parent:   node.Parent,
+							Node:     node,
+							Content:  string(body),
+							Line:     startLine,
+							External: true,
 						})
 					}
 				}
@@ -184,13 +211,15 @@ func extractHeadBodyAndStylesheets(doc *html.Node) (*html.Node, *html.Node, stri
 
 	for _, item := range externalStylesheets {
 		contents = append(contents, item.Content)
+		sheetLines = append(sheetLines, stylesheetLine{offset: offset, line: item.Line, external: item.External})
+		offset += len(item.Content) + 1 // joined by new line
 		item.Parent.RemoveChild(item.Node)
 	}
 
-	return head, body, strings.Join(contents, "\n"), nil
+	return head, body, strings.Join(contents, "\n"), sheetLines, nil
 }
 
-func (inlr *InlineEngine) collectStyles(p *css.Parser) (string, error) {
+func (inlr *InlineEngine) collectStyles(p *css.Parser, sheetContent string, sheetLines []stylesheetLine) (string, error) {
 	var (
 		collectedCSS      string = ""
 		countClosedStyles int    = 0
@@ -199,11 +228,10 @@ func (inlr *InlineEngine) collectStyles(p *css.Parser) (string, error) {
 	for {
 		gt, _, data := p.Next()
 
-		// log.Printf("[collectStyles]: %v - %v - %v\n", gt, string(data), p.Values())
-
 		if gt == css.ErrorGrammar {
 			return collectedCSS, nil
 		}
+		inlr.traceCssGrammar(gt, data, p, sheetContent, sheetLines)
 
 		switch gt {
 		case css.BeginRulesetGrammar, css.BeginAtRuleGrammar:
@@ -262,25 +290,31 @@ func (inlr *InlineEngine) inlineRulesetToTags(doc *html.Node, cssStore CSSSelect
 
 	for _, selectorGroup := range cssStore.Selectors {
 		if selectorGroup.NotApply {
+			inlr.traceSelector(TRACE_SELECTOR_SKIP, "pseudo", selectorGroup.Key, 0)
 			additionalCSS += converCssSelectorToString(selectorGroup.Key, cssStore.AttributesOrder, cssStore.Attributes)
 			continue
 		}
 
 		if resetSelectors.MatchString(selectorGroup.Key) {
+			inlr.traceSelector(TRACE_SELECTOR_SKIP, "reset", selectorGroup.Key, 0)
 			additionalCSS += converCssSelectorToString(selectorGroup.Key, cssStore.AttributesOrder, cssStore.Attributes)
 			continue
 		}
 
-		// log.Printf("[selectorGroup]: %v\n", selectorGroup)
-
 		selector, err := cascadia.ParseGroup(selectorGroup.Key)
 		if err != nil {
+			inlr.traceSelector(TRACE_SELECTOR_SKIP, "invalid", selectorGroup.Key, 0)
 			continue
 		}
 
-		// log.Printf("[selector]: %v\n", selector)
+		nodes := cascadia.Selector(selector.Match).MatchAll(doc)
+		if len(nodes) > 0 {
+			inlr.traceSelector(TRACE_SELECTOR_MATCH, "", selectorGroup.Key, len(nodes))
+		} else {
+			inlr.traceSelector(TRACE_SELECTOR_MISS, "", selectorGroup.Key, 0)
+		}
 
-		for _, node := range cascadia.Selector(selector.Match).MatchAll(doc) {
+		for _, node := range nodes {
 			// switch node.DataAtom {
 			// case a.Style:
 			// }
@@ -374,7 +408,7 @@ func (inlr *InlineEngine) inlineRulesetToTags(doc *html.Node, cssStore CSSSelect
 	return additionalCSS, nil
 }
 
-func (inlr *InlineEngine) inlineStyleSheetContent(doc *html.Node, sheetContent string) (string, error) {
+func (inlr *InlineEngine) inlineStyleSheetContent(doc *html.Node, sheetContent string, sheetLines []stylesheetLine) (string, error) {
 	var (
 		cssStore CSSSelectors = CSSSelectors{
 			Selectors:       []CSSGroupSelectors{},
@@ -384,17 +418,14 @@ func (inlr *InlineEngine) inlineStyleSheetContent(doc *html.Node, sheetContent s
 		notAppliedCss string = ""
 	)
 
-	// log.Printf("[sheetContent]: %v\n", sheetContent)
-
 	p := css.NewParser(parse.NewInput(bytes.NewBufferString(sheetContent)), false)
 	for {
 		gt, _, data := p.Next()
 
-		// log.Printf("[inlineStyleSheetContent]: %v - %v - %v\n", gt, string(data), p.Values())
-
 		if gt == css.ErrorGrammar {
 			return notAppliedCss, nil
 		}
+		inlr.traceCssGrammar(gt, data, p, sheetContent, sheetLines)
 
 		switch gt {
 		case css.AtRuleGrammar:
@@ -409,7 +440,7 @@ func (inlr *InlineEngine) inlineStyleSheetContent(doc *html.Node, sheetContent s
 				notAppliedCss += string(val.Data)
 			}
 			notAppliedCss += "{"
-			additionalCss, err := inlr.collectStyles(p)
+			additionalCss, err := inlr.collectStyles(p, sheetContent, sheetLines)
 			if err == nil {
 				notAppliedCss += additionalCss
 			}
@@ -458,7 +489,6 @@ func (inlr *InlineEngine) inlineStyleSheetContent(doc *html.Node, sheetContent s
 			}
 		}
 	}
-	return notAppliedCss, nil
 }
 
 func (inlr *InlineEngine) addNonAppliedCssToDom(doc *html.Node, sheetContent string) {
@@ -493,11 +523,21 @@ func (inlr *InlineEngine) InlineCss(htmlDoc []byte) ([]byte, error) {
 		return htmlDoc, nil // empty doc
 	}
 
+	if inlr.options.Includes != nil {
+		if htmlDoc, inlr.includeLines, err = resolveIncludes(htmlDoc, inlr.options.Includes); err != nil {
+			return []byte{}, err
+		}
+	}
+
+	if inlr.isTraceEnabled() {
+		htmlDoc = annotateLines(htmlDoc) // trace events have lines in document
+	}
+
 	if doc, err = html.Parse(bytes.NewReader(htmlDoc)); err != nil {
 		return []byte{}, err
 	}
 
-	head, body, stylesheetContents, err := extractHeadBodyAndStylesheets(doc)
+	head, body, stylesheetContents, stylesheetLines, err := extractHeadBodyAndStylesheets(doc)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -510,7 +550,7 @@ func (inlr *InlineEngine) InlineCss(htmlDoc []byte) ([]byte, error) {
 		body = doc // no body, use html as root
 	}
 
-	notAppliedCss, err := inlr.inlineStyleSheetContent(body, stylesheetContents)
+	notAppliedCss, err := inlr.inlineStyleSheetContent(body, stylesheetContents, stylesheetLines)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -530,7 +570,11 @@ func (inlr *InlineEngine) InlineCss(htmlDoc []byte) ([]byte, error) {
 }
 
 func InlineCssInHTML(htmlDoc []byte) ([]byte, error) {
-	inliner := InitInliner()
+	return InlineCssInHTMLWithOptions(htmlDoc, InlinerOptions{})
+}
+
+func InlineCssInHTMLWithOptions(htmlDoc []byte, options InlinerOptions) ([]byte, error) {
+	inliner := InitInlinerWithOptions(options)
 	newHtmlDoc, err := inliner.InlineCss(htmlDoc)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,171 @@
+package inliner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	css "github.com/tdewolff/parse/v2/css"
+
+	"golang.org/x/net/html"
+)
+
+// trace events kinds
+const (
+	TRACE_CSS_GRAMMAR    = "css_grammar"
+	TRACE_SELECTOR_MATCH = "selector_match"
+	TRACE_SELECTOR_MISS  = "selector_miss"
+	TRACE_SELECTOR_SKIP  = "selector_skip"
+
+	// temporary attribute with line of start tag
+	lineAttribute = "data-vmail-line"
+)
+
+type TraceEvent struct {
+	Kind    string `json:"kind"`
+	Type    string `json:"type"` // grammar type or reason of selector skip
+	Data    string `json:"data"` // grammar data or selector
+	Offset  int    `json:"offset"`
+	Line    int    `json:"line"`           // line in document or included partial
+	File    string `json:"file,omitempty"` // included partial, empty for main document
+	Matches int    `json:"matches"`        // count of elements, matched by selector
+}
+
+// stylesheetLine is start of stylesheet in collected stylesheets content
+type stylesheetLine struct {
+	offset   int // offset in collected content
+	line     int // line in document
+	external bool
+}
+
+// TraceSink receive inliner events
+type TraceSink interface {
+	Trace(event TraceEvent)
+}
+
+// TraceWriter write events as json lines
+type TraceWriter struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{w: w}
+}
+
+func (tw *TraceWriter) Trace(event TraceEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+
+	tw.w.Write(append(line, '\n'))
+}
+
+func (inlr *InlineEngine) isTraceEnabled() bool {
+	return inlr.options.Trace != nil
+}
+
+func (inlr *InlineEngine) trace(event TraceEvent) {
+	if inlr.isTraceEnabled() {
+		inlr.options.Trace.Trace(event)
+	}
+}
+
+func (inlr *InlineEngine) traceCssGrammar(gt css.GrammarType, data []byte, p *css.Parser, sheetContent string, sheetLines []stylesheetLine) {
+	if !inlr.isTraceEnabled() {
+		return
+	}
+
+	cssData := string(data)
+	for _, val := range p.Values() {
+		cssData += string(val.Data)
+	}
+	offset := min(p.Offset(), len(sheetContent))
+	line := stylesheetDocumentLine(sheetContent, sheetLines, offset)
+	inlr.trace(TraceEvent{
+		Kind:   TRACE_CSS_GRAMMAR,
+		Type:   gt.String(),
+		Data:   cssData,
+		Offset: offset,
+		Line:   inlr.originLine(line),
+		File:   inlr.originFile(line),
+	})
+}
+
+// stylesheetDocumentLine map offset in collected stylesheets content to line in document,
+// external stylesheets have line of <link> tag
+func stylesheetDocumentLine(sheetContent string, sheetLines []stylesheetLine, offset int) int {
+	sheet := stylesheetLine{line: 1}
+	for _, sheetLine := range sheetLines {
+		if sheetLine.offset > offset {
+			break
+		}
+		sheet = sheetLine
+	}
+	if sheet.external {
+		return sheet.line
+	}
+	return sheet.line + strings.Count(sheetContent[sheet.offset:offset], "\n")
+}
+
+func (inlr *InlineEngine) traceSelector(kind, reason, selector string, matches int) {
+	inlr.trace(TraceEvent{
+		Kind:    kind,
+		Type:    reason,
+		Data:    selector,
+		Matches: matches,
+	})
+}
+
+// annotateLines add line of each start tag as attribute, so it is available after html parsing
+func annotateLines(htmlDoc []byte) []byte {
+	var (
+		out  bytes.Buffer
+		line = 1
+	)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(htmlDoc))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				return out.Bytes()
+			}
+			return htmlDoc
+		}
+		raw := tokenizer.Raw()
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			// line of tag end, so style content lines can be counted from it
+			tagLine := line
+			line += bytes.Count(raw, []byte("\n"))
+			token := tokenizer.Token()
+			token.Attr = append(token.Attr, html.Attribute{Key: lineAttribute, Val: strconv.Itoa(tagLine) + ":" + strconv.Itoa(line)})
+			out.WriteString(token.String())
+			continue
+		}
+		line += bytes.Count(raw, []byte("\n"))
+		out.Write(raw)
+	}
+}
+
+// takeNodeLines remove line attribute from node and return lines of tag start and end
+func takeNodeLines(node *html.Node) (int, int) {
+	for i, attr := range node.Attr {
+		if attr.Key != lineAttribute {
+			continue
+		}
+		node.Attr = append(node.Attr[:i], node.Attr[i+1:]...)
+		start, end, _ := strings.Cut(attr.Val, ":")
+		startLine, _ := strconv.Atoi(start)
+		endLine, _ := strconv.Atoi(end)
+		return startLine, endLine
+	}
+	return 0, 0
+}
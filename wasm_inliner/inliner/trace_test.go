@@ -0,0 +1,67 @@
+package inliner
+
+import (
+	"sync"
+	"testing"
+)
+
+type traceCollector struct {
+	mx     sync.Mutex
+	events []TraceEvent
+}
+
+func (tc *traceCollector) Trace(event TraceEvent) {
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+	tc.events = append(tc.events, event)
+}
+
+func TestInlineCssInHTMLTrace(t *testing.T) {
+	htmlDoc := `<html>
+<head>
+	<style>
+		.title { color: red; }
+		.missing { color: blue; }
+		a:hover { color: green; }
+	</style>
+</head>
+<body>
+	<h1 class="title">Title</h1>
+	<p class="title">Text</p>
+</body></html>`
+
+	collector := &traceCollector{}
+	if _, err := InlineCssInHTMLWithOptions([]byte(htmlDoc), InlinerOptions{Trace: collector}); err != nil {
+		t.Fatalf("InlineCssInHTMLWithOptions: %v", err)
+	}
+
+	var tests = []struct {
+		kind    string
+		typ     string
+		data    string
+		line    int
+		matches int
+	}{
+		{TRACE_CSS_GRAMMAR, "BeginRuleset", ".title", 4, 0},
+		{TRACE_CSS_GRAMMAR, "Declaration", "colorred", 4, 0},
+		{TRACE_SELECTOR_MATCH, "", ".title", 0, 2},
+		{TRACE_SELECTOR_MISS, "", ".missing", 0, 0},
+		{TRACE_SELECTOR_SKIP, "pseudo", "a:hover", 0, 0},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, event := range collector.events {
+			if event.Kind == tt.kind && event.Type == tt.typ && event.Data == tt.data {
+				found = true
+				if event.Line != tt.line || event.Matches != tt.matches {
+					t.Errorf("Trace: event %s %q: got line %d and %d matches, want %d and %d", tt.kind, tt.data, event.Line, event.Matches, tt.line, tt.matches)
+				}
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Trace: event %s %s %q not found in %v", tt.kind, tt.typ, tt.data, collector.events)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package main
 // Import the package to access the Wasm environment
 import (
 	"errors"
+	"os"
 	"syscall/js"
 
 	"github.com/le0pard/vmail/wasm_inliner/inliner"
+	"github.com/le0pard/vmail/wasm_shared/includes"
 )
 
 func rejectWithError(reject js.Value, message string) {
@@ -19,12 +21,37 @@ func rejectWithError(reject js.Value, message string) {
 	reject.Invoke(errorObject)
 }
 
+// parseInlinerOptions read options object, passed as second argument
+func parseInlinerOptions(value js.Value) inliner.InlinerOptions {
+	options := inliner.InlinerOptions{}
+	// partials for include directives: {"header.html": "<table>..."}
+	if partials := value.Get("includes"); partials.Type() == js.TypeObject {
+		files := make(includes.MapFS)
+		keys := js.Global().Get("Object").Call("keys", partials)
+		for i := 0; i < keys.Length(); i++ {
+			name := keys.Index(i).String()
+			files[name] = []byte(partials.Get(name).String())
+		}
+		options.Includes = files
+	}
+	// debug events printed to browser console
+	if value.Get("trace").Truthy() {
+		options.Trace = inliner.NewTraceWriter(os.Stdout)
+	}
+	return options
+}
+
 // VMail returns a JavaScript function
 func VMailInliner() js.Func {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		// Get the HTML as argument
 		// args[0] is a js.Value, so we need to get a string out of it
 		htmlBody := args[0].String()
+		// args[1] is optional object with inliner options
+		options := inliner.InlinerOptions{}
+		if len(args) > 1 && args[1].Type() == js.TypeObject {
+			options = parseInlinerOptions(args[1])
+		}
 		// Handler for the Promise: this is a JS function
 		// It receives two arguments, which are JS functions themselves: resolve and reject
 		handler := js.FuncOf(func(promiseThis js.Value, promiseArgs []js.Value) interface{} {
@@ -34,7 +61,7 @@ func VMailInliner() js.Func {
 			// This way, we don't block the event loop and avoid a deadlock
 			go func() {
 
-				htmlResult, err := inliner.InlineCssInHTML([]byte(htmlBody))
+				htmlResult, err := inliner.InlineCssInHTMLWithOptions([]byte(htmlBody), options)
 				if err != nil {
 					rejectWithError(reject, err.Error())
 					return
@@ -2,11 +2,17 @@ module github.com/le0pard/vmail/wasm_parser
 
 go 1.25.0
 
-require github.com/le0pard/vmail/wasm_parser/parser v0.0.0-20260418123752-128b1209f74d
+require (
+	github.com/le0pard/vmail/wasm_parser/parser v0.0.0-20260418123752-128b1209f74d
+	github.com/le0pard/vmail/wasm_shared/includes v0.0.0-00010101000000-000000000000
+)
 
 require (
+	github.com/andybalholm/cascadia v1.3.3 // indirect
 	github.com/tdewolff/parse/v2 v2.8.11 // indirect
 	golang.org/x/net v0.55.0 // indirect
 )
 
 replace github.com/le0pard/vmail/wasm_parser/parser => ./parser
+
+replace github.com/le0pard/vmail/wasm_shared/includes => ../wasm_shared/includes
@@ -3,11 +3,13 @@ package main
 // Import the package to access the Wasm environment
 import (
 	"errors"
+	"os"
 	"sort"
 	"sync"
 	"syscall/js"
 
 	"github.com/le0pard/vmail/wasm_parser/parser"
+	"github.com/le0pard/vmail/wasm_shared/includes"
 )
 
 type ReportNestedLevelMap struct {
@@ -36,6 +38,20 @@ func rejectWithError(reject js.Value, message string) {
 	reject.Invoke(errorObject)
 }
 
+func collectPositionReport(position parser.SourcePosition) map[string]interface{} {
+	return map[string]interface{}{
+		"line":                position.Line,
+		"file":                position.File,
+		"snippet":             position.Snippet,
+		"context":             position.Context,
+		"selector":            position.Selector,
+		"at_rules":            stringsToInterfaces(position.AtRules),
+		"media":               position.Media,
+		"guard":               position.Guard,
+		"template_conditions": stringsToInterfaces(position.TemplateConditions),
+	}
+}
+
 func collectItemReport(item parser.ReportContainer) map[string]interface{} {
 	// hash to slice
 	lines := make([]int, 0, len(item.Lines))
@@ -50,10 +66,18 @@ func collectItemReport(item parser.ReportContainer) map[string]interface{} {
 		linesObj[i] = line
 	}
 
+	occurrencesObj := make([]interface{}, len(item.Occurrences))
+	for i, occurrence := range item.Occurrences {
+		occurrencesObj[i] = collectPositionReport(occurrence)
+	}
+
 	report := map[string]interface{}{
-		"rules":      item.Rules,
-		"lines":      linesObj,
-		"more_lines": item.MoreLines,
+		"rules":            item.Rules,
+		"lines":            linesObj,
+		"more_lines":       item.MoreLines,
+		"occurrences":      occurrencesObj,
+		"only_conditional": item.OnlyConditional,
+		"only_guarded":     item.OnlyGuarded,
 	}
 	return report
 }
@@ -74,6 +98,441 @@ func collectNestedLevelReport(items map[string]map[string]parser.ReportContainer
 	return itemsReports
 }
 
+func collectHtmlDiagnosticsReport(items []parser.HtmlDiagnostic) []interface{} {
+	diagnostics := make([]interface{}, len(items))
+	for i, item := range items {
+		diagnostics[i] = map[string]interface{}{
+			"type":        item.Type,
+			"severity":    item.Severity,
+			"tag":         item.Tag,
+			"message":     item.Message,
+			"description": item.Description,
+			"line":        item.Line,
+			"file":        item.File,
+			"column":      item.Column,
+			"snippet":     item.Snippet,
+			"context":     item.Context,
+		}
+	}
+	return diagnostics
+}
+
+func collectStyleBlocksReport(items []parser.StyleBlock) []interface{} {
+	blocks := make([]interface{}, len(items))
+	for i, item := range items {
+		issues := make([]interface{}, len(item.Issues))
+		for j, issue := range item.Issues {
+			issues[j] = map[string]interface{}{
+				"type":        issue.Type,
+				"description": issue.Description,
+			}
+		}
+
+		blocks[i] = map[string]interface{}{
+			"index":        item.Index,
+			"line":         item.Line,
+			"file":         item.File,
+			"location":     item.Location,
+			"media":        item.Media,
+			"conditional":  item.Conditional,
+			"bytes":        item.Bytes,
+			"rulesets":     item.Rulesets,
+			"at_rules":     item.AtRules,
+			"declarations": item.Declarations,
+			"has_rules":    item.HasRules,
+			"issues":       issues,
+			"snippet":      item.Snippet,
+			"context":      item.Context,
+		}
+	}
+	return blocks
+}
+
+func collectImagesReport(items []parser.ImageReference) []interface{} {
+	images := make([]interface{}, len(items))
+	for i, item := range items {
+		images[i] = map[string]interface{}{
+			"url":           item.Url,
+			"source":        item.Source,
+			"format":        item.Format,
+			"format_source": item.FormatSource,
+			"line":          item.Line,
+			"file":          item.File,
+			"snippet":       item.Snippet,
+			"context":       item.Context,
+		}
+	}
+	return images
+}
+
+func stringsToInterfaces(items []string) []interface{} {
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
+
+func collectCssVariablesGraphReport(graph parser.CssVariablesGraph) map[string]interface{} {
+	definitions := make([]interface{}, len(graph.Definitions))
+	for i, item := range graph.Definitions {
+		definitions[i] = map[string]interface{}{
+			"name":     item.Name,
+			"value":    item.Value,
+			"scope":    item.Scope,
+			"at_rules": stringsToInterfaces(item.AtRules),
+			"line":     item.Line,
+			"file":     item.File,
+			"snippet":  item.Snippet,
+			"context":  item.Context,
+		}
+	}
+
+	usages := make([]interface{}, len(graph.Usages))
+	for i, item := range graph.Usages {
+		usages[i] = map[string]interface{}{
+			"name":           item.Name,
+			"property":       item.Property,
+			"selector":       item.Selector,
+			"fallback":       item.Fallback,
+			"has_fallback":   item.HasFallback,
+			"is_defined":     item.IsDefined,
+			"resolved_value": item.ResolvedValue,
+			"issues":         stringsToInterfaces(item.Issues),
+			"line":           item.Line,
+			"file":           item.File,
+			"snippet":        item.Snippet,
+			"context":        item.Context,
+		}
+	}
+
+	return map[string]interface{}{
+		"definitions":         definitions,
+		"usages":              usages,
+		"unsupported_clients": stringsToInterfaces(graph.UnsupportedClients),
+		"more":                graph.More,
+	}
+}
+
+func collectFontsReport(fonts parser.FontsReport) map[string]interface{} {
+	stacks := make([]interface{}, len(fonts.Stacks))
+	for i, item := range fonts.Stacks {
+		lines := make([]interface{}, len(item.Lines))
+		for j, line := range item.Lines {
+			lines[j] = line
+		}
+		stacks[i] = map[string]interface{}{
+			"stack":     item.Stack,
+			"families":  stringsToInterfaces(item.Families),
+			"generic":   item.Generic,
+			"web_fonts": stringsToInterfaces(item.WebFonts),
+			"issues":    stringsToInterfaces(item.Issues),
+			"count":     item.Count,
+			"lines":     lines,
+			"line":      item.Line,
+			"file":      item.File,
+			"snippet":   item.Snippet,
+			"context":   item.Context,
+		}
+	}
+
+	fontFaces := make([]interface{}, len(fonts.FontFaces))
+	for i, item := range fonts.FontFaces {
+		sources := make([]interface{}, len(item.Sources))
+		for j, source := range item.Sources {
+			sources[j] = map[string]interface{}{
+				"url":      source.Url,
+				"format":   source.Format,
+				"is_local": source.IsLocal,
+			}
+		}
+		fontFaces[i] = map[string]interface{}{
+			"family":  item.Family,
+			"weight":  item.Weight,
+			"style":   item.Style,
+			"sources": sources,
+			"formats": stringsToInterfaces(item.Formats),
+			"line":    item.Line,
+			"file":    item.File,
+			"snippet": item.Snippet,
+			"context": item.Context,
+		}
+	}
+
+	links := make([]interface{}, len(fonts.Links))
+	for i, item := range fonts.Links {
+		links[i] = map[string]interface{}{
+			"url":      item.Url,
+			"host":     item.Host,
+			"source":   item.Source,
+			"families": stringsToInterfaces(item.Families),
+			"line":     item.Line,
+			"file":     item.File,
+			"snippet":  item.Snippet,
+			"context":  item.Context,
+		}
+	}
+
+	return map[string]interface{}{
+		"stacks":                        stacks,
+		"font_faces":                    fontFaces,
+		"links":                         links,
+		"web_fonts_unsupported_clients": stringsToInterfaces(fonts.WebFontsUnsupportedClients),
+		"more":                          fonts.More,
+	}
+}
+
+func collectColorPaletteReport(palette parser.ColorPalette) map[string]interface{} {
+	colors := make([]interface{}, len(palette.Colors))
+	for i, item := range palette.Colors {
+		lines := make([]interface{}, len(item.Lines))
+		for j, line := range item.Lines {
+			lines[j] = line
+		}
+		colors[i] = map[string]interface{}{
+			"hex":                 item.Hex,
+			"values":              stringsToInterfaces(item.Values),
+			"sources":             stringsToInterfaces(item.Sources),
+			"count":               item.Count,
+			"lines":               lines,
+			"nearest_brand_color": item.NearestBrandColor,
+			"brand_distance":      item.BrandDistance,
+			"issues":              stringsToInterfaces(item.Issues),
+			"line":                item.Line,
+			"file":                item.File,
+			"snippet":             item.Snippet,
+			"context":             item.Context,
+		}
+	}
+
+	return map[string]interface{}{
+		"colors":       colors,
+		"brand_colors": stringsToInterfaces(palette.BrandColors),
+		"more":         palette.More,
+	}
+}
+
+func collectContrastReport(contrast parser.ContrastReport) map[string]interface{} {
+	issues := make([]interface{}, len(contrast.Issues))
+	for i, item := range contrast.Issues {
+		issues[i] = map[string]interface{}{
+			"scheme":     item.Scheme,
+			"level":      item.Level,
+			"ratio":      item.Ratio,
+			"required":   item.Required,
+			"foreground": item.Foreground,
+			"background": item.Background,
+			"large_text": item.LargeText,
+			"text":       item.Text,
+			"line":       item.Line,
+			"file":       item.File,
+			"snippet":    item.Snippet,
+			"context":    item.Context,
+		}
+	}
+
+	return map[string]interface{}{
+		"issues": issues,
+		"more":   contrast.More,
+	}
+}
+
+func collectTableLayoutReport(layout parser.TableLayout) map[string]interface{} {
+	tables := make([]interface{}, len(layout.Tables))
+	for i, item := range layout.Tables {
+		rows := make([]interface{}, len(item.Rows))
+		for j, row := range item.Rows {
+			rows[j] = map[string]interface{}{
+				"line":  row.Line,
+				"cells": row.Cells,
+				"width": row.Width,
+			}
+		}
+		tables[i] = map[string]interface{}{
+			"depth":        item.Depth,
+			"parent":       item.Parent,
+			"width":        item.Width,
+			"parent_width": item.ParentWidth,
+			"rows":         rows,
+			"line":         item.Line,
+			"file":         item.File,
+			"snippet":      item.Snippet,
+			"context":      item.Context,
+		}
+	}
+
+	return map[string]interface{}{
+		"tables":    tables,
+		"max_depth": layout.MaxDepth,
+		"more":      layout.More,
+	}
+}
+
+func collectGmailReport(gmail parser.GmailReport) map[string]interface{} {
+	blocks := make([]interface{}, len(gmail.StyleBlocks))
+	for i, item := range gmail.StyleBlocks {
+		blocks[i] = map[string]interface{}{
+			"index":   item.Index,
+			"line":    item.Line,
+			"file":    item.File,
+			"bytes":   item.Bytes,
+			"dropped": item.Dropped,
+			"reasons": stringsToInterfaces(item.Reasons),
+		}
+	}
+
+	return map[string]interface{}{
+		"bytes":             gmail.Bytes,
+		"inlined_bytes":     gmail.InlinedBytes,
+		"clip_threshold":    gmail.ClipThreshold,
+		"clipped":           gmail.Clipped,
+		"clip_line":         gmail.ClipLine,
+		"clip_file":         gmail.ClipFile,
+		"inlined_clipped":   gmail.InlinedClipped,
+		"inlined_clip_line": gmail.InlinedClipLine,
+		"style_blocks":      blocks,
+	}
+}
+
+func collectSourceMapReport(segments []parser.SourceMapSegment) []interface{} {
+	items := make([]interface{}, len(segments))
+	for i, segment := range segments {
+		items[i] = map[string]interface{}{
+			"flat_line": segment.FlatLine,
+			"lines":     segment.Lines,
+			"file":      segment.File,
+			"line":      segment.Line,
+		}
+	}
+	return items
+}
+
+// parseParserOptions read options object, passed as second argument
+func parseParserOptions(value js.Value) parser.ParserOptions {
+	options := parser.ParserOptions{}
+	if value.Type() != js.TypeObject {
+		return options
+	}
+
+	if brandColors := value.Get("brandColors"); brandColors.Type() == js.TypeObject {
+		for i := 0; i < brandColors.Length(); i++ {
+			options.BrandColors = append(options.BrandColors, brandColors.Index(i).String())
+		}
+	}
+	if tolerance := value.Get("brandColorsTolerance"); tolerance.Type() == js.TypeNumber {
+		options.BrandColorsTolerance = tolerance.Float()
+	}
+	if maxWidth := value.Get("maxWidth"); maxWidth.Type() == js.TypeNumber {
+		options.MaxWidth = maxWidth.Int()
+	}
+	if inlinedHtml := value.Get("inlinedHtml"); inlinedHtml.Type() == js.TypeString {
+		options.InlinedHTML = []byte(inlinedHtml.String())
+	}
+	// partial html from larger file
+	options.Fragment = value.Get("fragment").Truthy()
+	if fileName := value.Get("fileName"); fileName.Type() == js.TypeString {
+		options.FileName = fileName.String()
+	}
+	if baseLine := value.Get("baseLine"); baseLine.Type() == js.TypeNumber {
+		options.BaseLine = baseLine.Int()
+	}
+	if baseColumn := value.Get("baseColumn"); baseColumn.Type() == js.TypeNumber {
+		options.BaseColumn = baseColumn.Int()
+	}
+	// partials for include directives: {"header.html": "<table>..."}
+	if partials := value.Get("includes"); partials.Type() == js.TypeObject {
+		files := make(includes.MapFS)
+		keys := js.Global().Get("Object").Call("keys", partials)
+		for i := 0; i < keys.Length(); i++ {
+			name := keys.Index(i).String()
+			files[name] = []byte(partials.Get(name).String())
+		}
+		options.Includes = files
+	}
+	// debug events printed to browser console
+	if value.Get("trace").Truthy() {
+		options.Trace = parser.NewTraceWriter(os.Stdout)
+	}
+	return options
+}
+
+func intsToInterfaces(items []int) []interface{} {
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		values[i] = item
+	}
+	return values
+}
+
+func collectTemplateReport(report *parser.TemplateReport) map[string]interface{} {
+	variants := make([]interface{}, len(report.Variants))
+	for i, variant := range report.Variants {
+		variantObj := map[string]interface{}{
+			"fixture": variant.Fixture,
+			"error":   variant.Error,
+		}
+		if variant.Report != nil {
+			variantObj["report"] = normalizeReportForPromise(variant.Report)
+		}
+		variants[i] = variantObj
+	}
+
+	findings := make([]interface{}, len(report.Findings))
+	for i, finding := range report.Findings {
+		lines := make(map[string]interface{}, len(finding.Lines))
+		for fixture, fixtureLines := range finding.Lines {
+			lines[fixture] = intsToInterfaces(fixtureLines)
+		}
+		findings[i] = map[string]interface{}{
+			"section":  finding.Section,
+			"key":      finding.Key,
+			"value":    finding.Value,
+			"rules":    finding.Rules,
+			"fixtures": stringsToInterfaces(finding.Fixtures),
+			"lines":    lines,
+		}
+	}
+
+	undefinedVariables := make([]interface{}, len(report.UndefinedVariables))
+	for i, variable := range report.UndefinedVariables {
+		undefinedVariables[i] = map[string]interface{}{
+			"name":     variable.Name,
+			"line":     variable.Line,
+			"fixtures": stringsToInterfaces(variable.Fixtures),
+		}
+	}
+
+	return map[string]interface{}{
+		"variants":            variants,
+		"findings":            findings,
+		"undefined_variables": undefinedVariables,
+	}
+}
+
+// parseRenderOptions read options object for templates rendering: engine, fixtures list ({name, data}) and parser options
+func parseRenderOptions(value js.Value) parser.RenderOptions {
+	options := parser.RenderOptions{
+		Parser: parseParserOptions(value),
+	}
+	if value.Type() != js.TypeObject {
+		return options
+	}
+
+	if engine := value.Get("engine"); engine.Type() == js.TypeString {
+		options.Engine = engine.String()
+	}
+	if fixtures := value.Get("fixtures"); fixtures.Type() == js.TypeObject {
+		for i := 0; i < fixtures.Length(); i++ {
+			fixture := fixtures.Index(i)
+			options.Fixtures = append(options.Fixtures, parser.TemplateFixture{
+				Name: fixture.Get("name").String(),
+				Data: []byte(fixture.Get("data").String()),
+			})
+		}
+	}
+	return options
+}
+
 func normalizeReportForPromise(report *parser.ParseReport) map[string]interface{} {
 	var (
 		wg sync.WaitGroup
@@ -99,6 +558,14 @@ func normalizeReportForPromise(report *parser.ParseReport) map[string]interface{
 			Data:    report.AtRuleCssStatements,
 			JsonKey: "at_rule_css_statements",
 		},
+		ReportNestedLevelMap{
+			Data:    report.MsoMarkup,
+			JsonKey: "mso_markup",
+		},
+		ReportNestedLevelMap{
+			Data:    report.UnknownNames,
+			JsonKey: "unknown_names",
+		},
 	}
 
 	for _, k := range nestedLevelKeys {
@@ -140,6 +607,30 @@ func normalizeReportForPromise(report *parser.ParseReport) map[string]interface{
 			Data:    report.LinkTypes,
 			JsonKey: "link_types",
 		},
+		ReportOneLevelMap{
+			Data:    report.HeadAudit,
+			JsonKey: "head_audit",
+		},
+		ReportOneLevelMap{
+			Data:    report.DarkMode,
+			JsonKey: "dark_mode",
+		},
+		ReportOneLevelMap{
+			Data:    report.Accessibility,
+			JsonKey: "accessibility",
+		},
+		ReportOneLevelMap{
+			Data:    report.Responsive,
+			JsonKey: "responsive",
+		},
+		ReportOneLevelMap{
+			Data:    report.TableIssues,
+			JsonKey: "table_issues",
+		},
+		ReportOneLevelMap{
+			Data:    report.GmailIssues,
+			JsonKey: "gmail_issues",
+		},
 	}
 
 	for _, k := range oneLevelKeys {
@@ -187,6 +678,51 @@ func normalizeReportForPromise(report *parser.ParseReport) map[string]interface{
 
 	wg.Wait()
 
+	if len(report.HtmlDiagnostics) > 0 {
+		newReport["html_diagnostics"] = collectHtmlDiagnosticsReport(report.HtmlDiagnostics)
+		newReport["html_diagnostics_more"] = report.HtmlDiagnosticsMore
+	}
+
+	if len(report.StyleBlocks) > 0 {
+		newReport["style_blocks"] = collectStyleBlocksReport(report.StyleBlocks)
+	}
+
+	if len(report.Images) > 0 {
+		newReport["images"] = collectImagesReport(report.Images)
+		newReport["images_more"] = report.ImagesMore
+	}
+
+	if len(report.CssVariablesGraph.Definitions) > 0 || len(report.CssVariablesGraph.Usages) > 0 {
+		newReport["css_variables_graph"] = collectCssVariablesGraphReport(report.CssVariablesGraph)
+	}
+
+	if len(report.Fonts.Stacks) > 0 || len(report.Fonts.FontFaces) > 0 || len(report.Fonts.Links) > 0 {
+		newReport["fonts"] = collectFontsReport(report.Fonts)
+	}
+
+	if len(report.SourceMap) > 0 {
+		newReport["source_map"] = collectSourceMapReport(report.SourceMap)
+	}
+
+	if len(report.FileName) > 0 || report.Fragment {
+		newReport["file_name"] = report.FileName
+		newReport["fragment"] = report.Fragment
+	}
+
+	if len(report.ColorPalette.Colors) > 0 {
+		newReport["color_palette"] = collectColorPaletteReport(report.ColorPalette)
+	}
+
+	newReport["gmail"] = collectGmailReport(report.Gmail)
+
+	if len(report.TableLayout.Tables) > 0 {
+		newReport["table_layout"] = collectTableLayoutReport(report.TableLayout)
+	}
+
+	if len(report.Contrast.Issues) > 0 {
+		newReport["contrast"] = collectContrastReport(report.Contrast)
+	}
+
 	return newReport
 }
 
@@ -196,6 +732,11 @@ func VMailParser() js.Func {
 		// Get the HTML as argument
 		// args[0] is a js.Value, so we need to get a string out of it
 		htmlBody := args[0].String()
+		// args[1] is optional object with parser options
+		options := parser.ParserOptions{}
+		if len(args) > 1 {
+			options = parseParserOptions(args[1])
+		}
 		// Handler for the Promise: this is a JS function
 		// It receives two arguments, which are JS functions themselves: resolve and reject
 		handler := js.FuncOf(func(promiseThis js.Value, promiseArgs []js.Value) interface{} {
@@ -205,7 +746,7 @@ func VMailParser() js.Func {
 			// This way, we don't block the event loop and avoid a deadlock
 			go func() {
 
-				report, err := parser.ReportFromHTML([]byte(htmlBody))
+				report, err := parser.ReportFromHTMLWithOptions([]byte(htmlBody), options)
 				if err != nil {
 					rejectWithError(reject, err.Error())
 					return
@@ -226,10 +767,41 @@ func VMailParser() js.Func {
 	})
 }
 
+// VMailTemplateParser returns a JavaScript function, which render template with fixtures and analyse each variant
+func VMailTemplateParser() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		templateBody := args[0].String()
+		// args[1] is optional object with engine, fixtures and parser options
+		options := parser.RenderOptions{}
+		if len(args) > 1 {
+			options = parseRenderOptions(args[1])
+		}
+		handler := js.FuncOf(func(promiseThis js.Value, promiseArgs []js.Value) interface{} {
+			resolve := promiseArgs[0]
+			reject := promiseArgs[1]
+			go func() {
+				report, err := parser.ReportFromTemplate([]byte(templateBody), options)
+				if err != nil {
+					rejectWithError(reject, err.Error())
+					return
+				}
+
+				resolve.Invoke(collectTemplateReport(report))
+			}()
+
+			return nil
+		})
+
+		promiseConstructor := js.Global().Get("Promise")
+		return promiseConstructor.New(handler)
+	})
+}
+
 // Main function: it sets up our Wasm application
 func main() {
 	// Define the function "VMailParser" in the JavaScript scope
 	js.Global().Set("VMailParser", VMailParser())
+	js.Global().Set("VMailTemplateParser", VMailTemplateParser())
 	// Prevent the function from returning, which is required in a wasm module
 	select {}
 }
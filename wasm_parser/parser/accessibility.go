@@ -0,0 +1,400 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+const (
+	// smallest readable font size in px
+	ACCESSIBILITY_MIN_FONT_SIZE = 12
+	// default font size for em, rem and percent values
+	ACCESSIBILITY_BASE_FONT_SIZE = 16
+)
+
+// accessibility issues
+const (
+	ACCESSIBILITY_IMAGE_MISSING_ALT       = "image_missing_alt"
+	ACCESSIBILITY_LAYOUT_TABLE_ROLE       = "layout_table_without_role"
+	ACCESSIBILITY_MISSING_HTML_DIR        = "missing_html_dir"
+	ACCESSIBILITY_HEADING_SKIPPED_LEVEL   = "heading_skipped_level"
+	ACCESSIBILITY_NON_DESCRIPTIVE_LINK    = "non_descriptive_link_text"
+	ACCESSIBILITY_LINK_WITHOUT_TEXT       = "link_without_text"
+	ACCESSIBILITY_TINY_FONT_SIZE          = "tiny_font_size"
+	ACCESSIBILITY_UNKNOWN_ARIA_ATTRIBUTE  = "unknown_aria_attribute"
+	ACCESSIBILITY_INVALID_ROLE            = "invalid_role"
+	ACCESSIBILITY_ARIA_HIDDEN_FOCUSABLE   = "aria_hidden_focusable"
+	ACCESSIBILITY_ARIA_REFERENCE_MISSING  = "aria_reference_missing_id"
+	ACCESSIBILITY_PRESENTATION_TABLE_DATA = "presentation_table_with_headers"
+)
+
+var (
+	nonDescriptiveLinkTexts = map[string]bool{
+		"click here": true, "click": true, "here": true, "read more": true, "more": true, "learn more": true,
+		"this link": true, "link": true, "go": true, "details": true, "more info": true, "continue": true,
+	}
+	// scripts written from right to left
+	rtlScripts = []*unicode.RangeTable{unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko}
+
+	knownAriaAttributes = map[string]bool{
+		"aria-activedescendant": true, "aria-atomic": true, "aria-autocomplete": true, "aria-braillelabel": true,
+		"aria-brailleroledescription": true, "aria-busy": true, "aria-checked": true, "aria-colcount": true,
+		"aria-colindex": true, "aria-colindextext": true, "aria-colspan": true, "aria-controls": true,
+		"aria-current": true, "aria-describedby": true, "aria-description": true, "aria-details": true,
+		"aria-disabled": true, "aria-dropeffect": true, "aria-errormessage": true, "aria-expanded": true,
+		"aria-flowto": true, "aria-grabbed": true, "aria-haspopup": true, "aria-hidden": true,
+		"aria-invalid": true, "aria-keyshortcuts": true, "aria-label": true, "aria-labelledby": true,
+		"aria-level": true, "aria-live": true, "aria-modal": true, "aria-multiline": true,
+		"aria-multiselectable": true, "aria-orientation": true, "aria-owns": true, "aria-placeholder": true,
+		"aria-posinset": true, "aria-pressed": true, "aria-readonly": true, "aria-relevant": true,
+		"aria-required": true, "aria-roledescription": true, "aria-rowcount": true, "aria-rowindex": true,
+		"aria-rowindextext": true, "aria-rowspan": true, "aria-selected": true, "aria-setsize": true,
+		"aria-sort": true, "aria-valuemax": true, "aria-valuemin": true, "aria-valuenow": true,
+		"aria-valuetext": true,
+	}
+
+	knownAriaRoles = map[string]bool{
+		"alert": true, "alertdialog": true, "application": true, "article": true, "banner": true,
+		"blockquote": true, "button": true, "caption": true, "cell": true, "checkbox": true, "code": true,
+		"columnheader": true, "combobox": true, "complementary": true, "contentinfo": true, "definition": true,
+		"deletion": true, "dialog": true, "document": true, "emphasis": true, "feed": true, "figure": true,
+		"form": true, "generic": true, "grid": true, "gridcell": true, "group": true, "heading": true,
+		"img": true, "insertion": true, "link": true, "list": true, "listbox": true, "listitem": true,
+		"log": true, "main": true, "marquee": true, "math": true, "menu": true, "menubar": true,
+		"menuitem": true, "menuitemcheckbox": true, "menuitemradio": true, "meter": true, "navigation": true,
+		"none": true, "note": true, "option": true, "paragraph": true, "presentation": true,
+		"progressbar": true, "radio": true, "radiogroup": true, "region": true, "row": true, "rowgroup": true,
+		"rowheader": true, "scrollbar": true, "search": true, "searchbox": true, "separator": true,
+		"slider": true, "spinbutton": true, "status": true, "strong": true, "subscript": true,
+		"superscript": true, "switch": true, "tab": true, "table": true, "tablist": true, "tabpanel": true,
+		"term": true, "textbox": true, "time": true, "timer": true, "toolbar": true, "tooltip": true,
+		"tree": true, "treegrid": true, "treeitem": true,
+	}
+
+	// font size attribute of <font> in px
+	htmlFontSizes = map[string]float64{"1": 10, "2": 13, "3": 16, "4": 18, "5": 24, "6": 32, "7": 48}
+
+	accessibilityRulesDB = map[string]map[string]interface{}{}
+)
+
+// initAccessibilityRules use stats of role and aria attributes, missing lang on <html> reported by head audit
+func initAccessibilityRules() {
+	roleRule := rulesDB.HtmlAttributes["role"][""]
+	ariaLabelRule := rulesDB.HtmlAttributes["aria-label"][""]
+
+	accessibilityRulesDB = map[string]map[string]interface{}{
+		ACCESSIBILITY_IMAGE_MISSING_ALT:       makeClientsIssueRule("Image without alt", "Screen readers announce file name of image without alt attribute. Use alt=\"\" for decorative images and spacers.", SEVERITY_ERROR, nil),
+		ACCESSIBILITY_LAYOUT_TABLE_ROLE:       makeClientsIssueRule("Layout table without role=\"presentation\"", "Screen readers announce rows and columns of layout tables. role=\"presentation\" tells them, what table used only for layout.", SEVERITY_WARNING, roleRule),
+		ACCESSIBILITY_MISSING_HTML_DIR:        makeClientsIssueRule("Missing dir on <html>", "Email contains right-to-left text, but <html> has no dir attribute, so clients can show it with wrong direction and alignment, when they wrap email in own markup.", SEVERITY_WARNING, nil),
+		ACCESSIBILITY_HEADING_SKIPPED_LEVEL:   makeClientsIssueRule("Heading level skipped", "Headings should not skip levels (<h1> followed by <h3>), screen readers users navigate email by headings structure.", SEVERITY_WARNING, nil),
+		ACCESSIBILITY_NON_DESCRIPTIVE_LINK:    makeClientsIssueRule("Non-descriptive link text", "Link text like \"click here\" or \"read more\" does not explain destination, when screen reader lists links out of context. Describe destination in text or aria-label.", SEVERITY_WARNING, ariaLabelRule),
+		ACCESSIBILITY_LINK_WITHOUT_TEXT:       makeClientsIssueRule("Link without text", "Link has no text, alt of image or aria-label, so screen readers announce only its url.", SEVERITY_ERROR, ariaLabelRule),
+		ACCESSIBILITY_TINY_FONT_SIZE:          makeClientsIssueRule("Tiny font size", "Text smaller than 12px is hard to read, some mobile clients also enlarge it automatically and break layout.", SEVERITY_WARNING, nil),
+		ACCESSIBILITY_UNKNOWN_ARIA_ATTRIBUTE:  makeClientsIssueRule("Unknown aria attribute", "Attribute is not defined in WAI-ARIA specification and ignored by assistive technologies.", SEVERITY_WARNING, nil),
+		ACCESSIBILITY_INVALID_ROLE:            makeClientsIssueRule("Invalid role", "role value is not defined in WAI-ARIA specification and ignored by assistive technologies.", SEVERITY_WARNING, roleRule),
+		ACCESSIBILITY_ARIA_HIDDEN_FOCUSABLE:   makeClientsIssueRule("aria-hidden on focusable element", "Element is hidden from screen readers, but still reachable by keyboard, so users focus element without name.", SEVERITY_ERROR, rulesDB.HtmlAttributes["aria-hidden"][""]),
+		ACCESSIBILITY_ARIA_REFERENCE_MISSING:  makeClientsIssueRule("aria reference to missing id", "aria-labelledby or aria-describedby reference id, which does not exist in email (clients also can rename or remove ids).", SEVERITY_WARNING, rulesDB.HtmlAttributes["aria-labelledby"][""]),
+		ACCESSIBILITY_PRESENTATION_TABLE_DATA: makeClientsIssueRule("Data table with role=\"presentation\"", "Table has headers or caption, but role=\"presentation\" removes its semantics for screen readers.", SEVERITY_INFO, roleRule),
+	}
+}
+
+type accessibilityTable struct {
+	position       SourcePosition
+	isPresentation bool
+	hasHeaders     bool
+}
+
+type accessibilityLink struct {
+	position SourcePosition
+	text     strings.Builder
+	hasName  bool // aria-label or aria-labelledby
+}
+
+type accessibilityReference struct {
+	ids      []string
+	position SourcePosition
+}
+
+type accessibilityState struct {
+	tables      []accessibilityTable
+	link        *accessibilityLink
+	lastHeading int
+	htmlNoDir   *SourcePosition // <html> without dir attribute
+	hasRtlText  bool
+	ids         map[string]bool
+	references  []accessibilityReference
+}
+
+func (prs *ParserEngine) saveToReportAccessibility(issue string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.Accessibility, accessibilityRulesDB, issue, position)
+}
+
+func hasHtmlAttribute(attrs []html.Attribute, attrKey string) bool {
+	for _, att := range attrs {
+		if strings.ToLower(att.Key) == attrKey {
+			return true
+		}
+	}
+	return false
+}
+
+func isFocusableHtmlElement(token html.Token) bool {
+	switch token.DataAtom {
+	case a.A:
+		return hasHtmlAttribute(token.Attr, "href")
+	case a.Button, a.Select, a.Textarea:
+		return true
+	case a.Input:
+		return strings.ToLower(getHtmlAttributeValue(token.Attr, "type")) != "hidden"
+	}
+	return hasHtmlAttribute(token.Attr, "tabindex") && getHtmlAttributeValue(token.Attr, "tabindex") != "-1"
+}
+
+func headingLevel(atom a.Atom) int {
+	switch atom {
+	case a.H1:
+		return 1
+	case a.H2:
+		return 2
+	case a.H3:
+		return 3
+	case a.H4:
+		return 4
+	case a.H5:
+		return 5
+	case a.H6:
+		return 6
+	}
+	return 0
+}
+
+func hasRtlText(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, rtlScripts...) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLinkText lowercase link text and strip decorations like arrows and punctuation
+func normalizeLinkText(text string) string {
+	text = strings.ToLower(collapseWhitespace(text))
+	return strings.Trim(text, " .,!:;>»›→…-")
+}
+
+// collectAccessibility check html tokens for accessibility issues
+func (prs *ParserEngine) collectAccessibility(token html.Token, position SourcePosition) {
+	switch token.Type {
+	case html.TextToken:
+		if prs.isStyleTagOpen {
+			return
+		}
+		if prs.accessibility.link != nil {
+			prs.accessibility.link.text.WriteString(token.Data)
+		}
+		if !prs.accessibility.hasRtlText {
+			prs.accessibility.hasRtlText = hasRtlText(token.Data)
+		}
+	case html.StartTagToken, html.SelfClosingTagToken:
+		prs.checkAccessibilityAttributes(token, position)
+		prs.checkAccessibilityElement(token, position)
+	case html.EndTagToken:
+		switch token.DataAtom {
+		case a.A:
+			prs.closeAccessibilityLink()
+		case a.Table:
+			prs.closeAccessibilityTable()
+		}
+	}
+}
+
+func (prs *ParserEngine) checkAccessibilityAttributes(token html.Token, position SourcePosition) {
+	for _, att := range token.Attr {
+		attrKey := strings.ToLower(att.Key)
+		attrVal := strings.TrimSpace(att.Val)
+
+		switch {
+		case attrKey == "id" && len(attrVal) > 0:
+			prs.accessibility.ids[attrVal] = true
+		case attrKey == "role":
+			for _, role := range strings.Fields(strings.ToLower(attrVal)) {
+				if !knownAriaRoles[role] {
+					prs.saveToReportAccessibility(ACCESSIBILITY_INVALID_ROLE, position)
+					break
+				}
+			}
+		case strings.HasPrefix(attrKey, "aria-"):
+			if !knownAriaAttributes[attrKey] {
+				prs.saveToReportAccessibility(ACCESSIBILITY_UNKNOWN_ARIA_ATTRIBUTE, position)
+				continue
+			}
+			switch attrKey {
+			case "aria-hidden":
+				if strings.ToLower(attrVal) == "true" && isFocusableHtmlElement(token) {
+					prs.saveToReportAccessibility(ACCESSIBILITY_ARIA_HIDDEN_FOCUSABLE, position)
+				}
+			case "aria-labelledby", "aria-describedby":
+				prs.accessibility.references = append(prs.accessibility.references, accessibilityReference{
+					ids:      strings.Fields(attrVal),
+					position: position,
+				})
+			}
+		}
+	}
+}
+
+func (prs *ParserEngine) checkAccessibilityElement(token html.Token, position SourcePosition) {
+	switch token.DataAtom {
+	case a.Html:
+		if len(getHtmlAttributeValue(token.Attr, "dir")) == 0 {
+			prs.accessibility.htmlNoDir = &position
+		}
+	case a.Img:
+		if !hasHtmlAttribute(token.Attr, "alt") {
+			prs.saveToReportAccessibility(ACCESSIBILITY_IMAGE_MISSING_ALT, position)
+		}
+		if prs.accessibility.link != nil && len(getHtmlAttributeValue(token.Attr, "alt")) > 0 {
+			prs.accessibility.link.text.WriteString(" " + getHtmlAttributeValue(token.Attr, "alt") + " ")
+		}
+	case a.A:
+		prs.closeAccessibilityLink() // nested links are not allowed
+		if token.Type == html.StartTagToken && hasHtmlAttribute(token.Attr, "href") {
+			prs.accessibility.link = &accessibilityLink{
+				position: position,
+				hasName:  len(getHtmlAttributeValue(token.Attr, "aria-label")) > 0 || len(getHtmlAttributeValue(token.Attr, "aria-labelledby")) > 0,
+			}
+		}
+	case a.Table:
+		role := strings.ToLower(getHtmlAttributeValue(token.Attr, "role"))
+		prs.accessibility.tables = append(prs.accessibility.tables, accessibilityTable{
+			position:       position,
+			isPresentation: role == "presentation" || role == "none",
+		})
+	case a.Th, a.Caption, a.Thead:
+		if len(prs.accessibility.tables) > 0 {
+			prs.accessibility.tables[len(prs.accessibility.tables)-1].hasHeaders = true
+		}
+	case a.Font:
+		if size, ok := htmlFontSizes[strings.TrimPrefix(getHtmlAttributeValue(token.Attr, "size"), "+")]; ok && size < ACCESSIBILITY_MIN_FONT_SIZE {
+			prs.saveToReportAccessibility(ACCESSIBILITY_TINY_FONT_SIZE, position)
+		}
+	}
+
+	if level := headingLevel(token.DataAtom); level > 0 {
+		if prs.accessibility.lastHeading > 0 && level > prs.accessibility.lastHeading+1 {
+			prs.saveToReportAccessibility(ACCESSIBILITY_HEADING_SKIPPED_LEVEL, position)
+		}
+		prs.accessibility.lastHeading = level
+	}
+}
+
+func (prs *ParserEngine) closeAccessibilityLink() {
+	link := prs.accessibility.link
+	if link == nil {
+		return
+	}
+	prs.accessibility.link = nil
+
+	if link.hasName {
+		return
+	}
+	text := normalizeLinkText(link.text.String())
+	if len(text) == 0 {
+		prs.saveToReportAccessibility(ACCESSIBILITY_LINK_WITHOUT_TEXT, link.position)
+	} else if nonDescriptiveLinkTexts[text] {
+		prs.saveToReportAccessibility(ACCESSIBILITY_NON_DESCRIPTIVE_LINK, link.position)
+	}
+}
+
+func (prs *ParserEngine) closeAccessibilityTable() {
+	if len(prs.accessibility.tables) == 0 {
+		return
+	}
+	table := prs.accessibility.tables[len(prs.accessibility.tables)-1]
+	prs.accessibility.tables = prs.accessibility.tables[:len(prs.accessibility.tables)-1]
+
+	switch {
+	case !table.hasHeaders && !table.isPresentation:
+		prs.saveToReportAccessibility(ACCESSIBILITY_LAYOUT_TABLE_ROLE, table.position)
+	case table.hasHeaders && table.isPresentation:
+		prs.saveToReportAccessibility(ACCESSIBILITY_PRESENTATION_TABLE_DATA, table.position)
+	}
+}
+
+// cssFontSizeInPx convert font size to px, false for keywords and unknown units
+func cssFontSizeInPx(value css.Token) (float64, bool) {
+	if value.TokenType != css.DimensionToken && value.TokenType != css.PercentageToken {
+		return 0, false
+	}
+	number := strings.TrimLeft(string(value.Data), "+")
+	unit := strings.TrimLeft(number, "0123456789.-")
+	size, err := strconv.ParseFloat(strings.TrimSuffix(number, unit), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch strings.ToLower(unit) {
+	case "px":
+		return size, true
+	case "pt":
+		return size * 4 / 3, true
+	case "em", "rem":
+		return size * ACCESSIBILITY_BASE_FONT_SIZE, true
+	case "%":
+		return size * ACCESSIBILITY_BASE_FONT_SIZE / 100, true
+	}
+	return 0, false
+}
+
+// checkAccessibilityDeclaration find tiny font sizes in style blocks and inline styles
+func (prs *ParserEngine) checkAccessibilityDeclaration(propertyKey string, values []css.Token, position SourcePosition) {
+	propertyKey = strings.ToLower(propertyKey)
+	if propertyKey != "font-size" && propertyKey != "font" {
+		return
+	}
+
+	for _, value := range values {
+		if value.TokenType == css.DelimToken && string(value.Data) == "/" {
+			return // line height in font shorthand
+		}
+		if size, ok := cssFontSizeInPx(value); ok {
+			// font-size: 0 used to hide whitespace between inline blocks
+			if size > 0 && size < ACCESSIBILITY_MIN_FONT_SIZE {
+				prs.saveToReportAccessibility(ACCESSIBILITY_TINY_FONT_SIZE, position)
+			}
+			return
+		}
+	}
+}
+
+// checkAccessibility report issues, which known only after whole document processed
+func (prs *ParserEngine) checkAccessibility() {
+	prs.closeAccessibilityLink()
+	for len(prs.accessibility.tables) > 0 {
+		prs.closeAccessibilityTable()
+	}
+
+	if prs.options.Fragment {
+		return // ids and <html> are outside of fragment
+	}
+
+	for _, reference := range prs.accessibility.references {
+		for _, id := range reference.ids {
+			if !prs.accessibility.ids[id] {
+				prs.saveToReportAccessibility(ACCESSIBILITY_ARIA_REFERENCE_MISSING, reference.position)
+				break
+			}
+		}
+	}
+
+	// left-to-right is default direction, dir needed only for right-to-left text
+	if prs.accessibility.hasRtlText && prs.accessibility.htmlNoDir != nil {
+		prs.saveToReportAccessibility(ACCESSIBILITY_MISSING_HTML_DIR, *prs.accessibility.htmlNoDir)
+	}
+}
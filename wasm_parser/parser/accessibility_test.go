@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLAccessibility(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want map[string]map[int]bool
+	}{
+		{
+			"accessible email",
+			`<html lang="en" dir="ltr">
+<body>
+	<table role="presentation"><tr><td>
+		<h1>Title</h1>
+		<h2>Subtitle</h2>
+		<img src="logo.png" alt="">
+		<a href="https://example.com">Open your dashboard</a>
+		<a href="https://example.com"><img src="icon.png" alt="Settings"></a>
+		<p id="desc" style="font-size: 14px">Text</p>
+		<button aria-describedby="desc">Buy</button>
+	</td></tr></table>
+	<table><caption>Prices</caption><tr><th>Plan</th></tr></table>
+</body></html>`,
+			map[string]map[int]bool{},
+		},
+		{
+			"right-to-left text",
+			`<html lang="he">
+<body>
+	<p>שלום</p>
+</body></html>`,
+			map[string]map[int]bool{
+				ACCESSIBILITY_MISSING_HTML_DIR: {1: true},
+			},
+		},
+		{
+			"right-to-left text with dir",
+			`<html lang="he" dir="rtl">
+<body>
+	<p>שלום</p>
+</body></html>`,
+			map[string]map[int]bool{},
+		},
+		{
+			"issues",
+			`<html>
+<head><style>.small { font-size: 9px; } .zero { font-size: 0; } .nav { font: 10pt/1.2 Arial; }</style></head>
+<body>
+	<table><tr><td>
+		<h1>Title</h1>
+		<h3>Skipped</h3>
+		<img src="logo.png">
+		<a href="https://example.com">Click here!</a>
+		<a href="https://example.com"><img src="icon.png"></a>
+		<a href="#top" aria-hidden="true" aria-lable="Top">Top of page</a>
+		<div role="buton" aria-labelledby="missing">Text</div>
+		<font size="1">Legal</font>
+	</td></tr></table>
+	<table role="presentation"><tr><th>Header</th></tr></table>
+</body></html>`,
+			map[string]map[int]bool{
+				ACCESSIBILITY_TINY_FONT_SIZE:          {2: true, 12: true},
+				ACCESSIBILITY_LAYOUT_TABLE_ROLE:       {4: true},
+				ACCESSIBILITY_HEADING_SKIPPED_LEVEL:   {6: true},
+				ACCESSIBILITY_IMAGE_MISSING_ALT:       {7: true, 9: true},
+				ACCESSIBILITY_NON_DESCRIPTIVE_LINK:    {8: true},
+				ACCESSIBILITY_LINK_WITHOUT_TEXT:       {9: true},
+				ACCESSIBILITY_ARIA_HIDDEN_FOCUSABLE:   {10: true},
+				ACCESSIBILITY_UNKNOWN_ARIA_ATTRIBUTE:  {10: true},
+				ACCESSIBILITY_INVALID_ROLE:            {11: true},
+				ACCESSIBILITY_ARIA_REFERENCE_MISSING:  {11: true},
+				ACCESSIBILITY_PRESENTATION_TABLE_DATA: {14: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTML([]byte(tt.html))
+			if err != nil {
+				t.Fatalf("ReportFromHTML() error = %v", err)
+			}
+
+			got := make(map[string]map[int]bool)
+			for issue, item := range report.Accessibility {
+				got[issue] = item.Lines
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// support levels of caniemail stats
+const (
+	CLIENT_SUPPORT_YES     = "y"
+	CLIENT_SUPPORT_NO      = "n"
+	CLIENT_SUPPORT_PARTIAL = "a"
+	CLIENT_SUPPORT_UNKNOWN = "u"
+)
+
+// compareClientVersions compare versions like "16.80", "2019-08" or "2013" by numeric parts
+func compareClientVersions(v1, v2 string) int {
+	isSeparator := func(r rune) bool { return !unicode.IsDigit(r) }
+	parts1, parts2 := strings.FieldsFunc(v1, isSeparator), strings.FieldsFunc(v2, isSeparator)
+
+	for i := 0; i < len(parts1) && i < len(parts2); i++ {
+		n1, _ := strconv.Atoi(parts1[i])
+		n2, _ := strconv.Atoi(parts2[i])
+		if n1 != n2 {
+			return n1 - n2
+		}
+	}
+	return len(parts1) - len(parts2)
+}
+
+// clientsSupport return support level of the latest version for each "client platform" from rule stats
+func clientsSupport(rule interface{}) map[string]string {
+	support := make(map[string]string)
+
+	ruleData, ok := rule.(map[string]interface{})
+	if !ok {
+		return support
+	}
+	stats, ok := ruleData["stats"].(map[string]interface{})
+	if !ok {
+		return support
+	}
+
+	for client, platforms := range stats {
+		platformsData, ok := platforms.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for platform, versions := range platformsData {
+			versionsData, ok := versions.(map[string]interface{})
+			if !ok || len(versionsData) == 0 {
+				continue
+			}
+
+			latestVersion := ""
+			for version := range versionsData {
+				if len(latestVersion) == 0 || compareClientVersions(version, latestVersion) > 0 {
+					latestVersion = version
+				}
+			}
+
+			level := CLIENT_SUPPORT_UNKNOWN
+			if values, ok := versionsData[latestVersion].([]interface{}); ok && len(values) > 0 {
+				if value, ok := values[0].(string); ok {
+					level = value
+				}
+			}
+			support[client+" "+platform] = level
+		}
+	}
+	return support
+}
+
+// clientFamiliesSupport merge support levels of client platforms: "y" or "n" if all platforms agree, otherwise "a"
+func clientFamiliesSupport(rule interface{}) map[string]interface{} {
+	families := make(map[string]interface{})
+	for client, level := range clientsSupport(rule) {
+		family, _, _ := strings.Cut(client, " ")
+		if familyLevel, ok := families[family]; ok && familyLevel != level {
+			families[family] = CLIENT_SUPPORT_PARTIAL
+			continue
+		}
+		families[family] = level
+	}
+	return families
+}
+
+// makeClientsIssueRule describe issue with support of its fix per client family (rule is nil if fix has no stats)
+func makeClientsIssueRule(title, description, severity string, rule interface{}) map[string]interface{} {
+	issueRule := makeIssueRule(title, description, severity)
+	issueRule["clients"] = clientFamiliesSupport(rule)
+	return issueRule
+}
+
+// clientsWithSupport return sorted "client platform" list with requested support level
+func clientsWithSupport(rule interface{}, level string) []string {
+	var clients []string
+	for client, clientLevel := range clientsSupport(rule) {
+		if clientLevel == level {
+			clients = append(clients, client)
+		}
+	}
+	sort.Strings(clients)
+	return clients
+}
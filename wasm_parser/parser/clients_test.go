@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareClientVersions(t *testing.T) {
+	var tests = []struct {
+		v1   string
+		v2   string
+		want int
+	}{
+		{"2019-08", "2020-02", -1},
+		{"16.80", "16.9", 1},
+		{"2013", "2013", 0},
+		{"12.2", "12", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.v1+" "+tt.v2, func(t *testing.T) {
+			got := compareClientVersions(tt.v1, tt.v2)
+			if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+				t.Errorf("compareClientVersions(%s, %s): got %d, want sign of %d", tt.v1, tt.v2, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientsWithSupport(t *testing.T) {
+	rule := map[string]interface{}{
+		"stats": map[string]interface{}{
+			"gmail": map[string]interface{}{
+				"android": map[string]interface{}{"2019-08": []interface{}{"y"}, "2023-01": []interface{}{"n"}},
+			},
+			"apple-mail": map[string]interface{}{
+				"ios": map[string]interface{}{"12.2": []interface{}{"y"}},
+			},
+		},
+	}
+
+	if got := clientsWithSupport(rule, CLIENT_SUPPORT_NO); len(got) != 1 || got[0] != "gmail android" {
+		t.Errorf("clientsWithSupport(no): got %v, want [gmail android]", got)
+	}
+	if got := clientsWithSupport(rule, CLIENT_SUPPORT_YES); len(got) != 1 || got[0] != "apple-mail ios" {
+		t.Errorf("clientsWithSupport(yes): got %v, want [apple-mail ios]", got)
+	}
+}
+
+func TestClientFamiliesSupport(t *testing.T) {
+	rule := map[string]interface{}{
+		"stats": map[string]interface{}{
+			"apple-mail": map[string]interface{}{
+				"macos": map[string]interface{}{"12.4": []interface{}{"y"}},
+				"ios":   map[string]interface{}{"13.0": []interface{}{"y"}},
+			},
+			"gmail": map[string]interface{}{
+				"desktop-webmail": map[string]interface{}{"2022-12": []interface{}{"n"}},
+				"android":         map[string]interface{}{"2022-12": []interface{}{"y"}},
+			},
+		},
+	}
+
+	want := map[string]interface{}{"apple-mail": CLIENT_SUPPORT_YES, "gmail": CLIENT_SUPPORT_PARTIAL}
+	if got := clientFamiliesSupport(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("clientFamiliesSupport: got %v, want %v", got, want)
+	}
+}
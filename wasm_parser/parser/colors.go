@@ -0,0 +1,490 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+)
+
+const (
+	LIMIT_REPORT_COLORS        = 500
+	LIMIT_COLOR_VALUES         = 10
+	DEFAULT_BRAND_COLOR_DELTAE = 10.0 // CIE76 distance, below it colour looks like brand one
+)
+
+// palette colours issues
+const (
+	COLOR_ISSUE_OUTSIDE_BRAND = "outside_brand_palette"
+	COLOR_ISSUE_NEAR_BRAND    = "near_brand_color"
+)
+
+var (
+	// html attributes with colour values
+	htmlColorAttributes = map[string]bool{
+		"bgcolor": true, "color": true, "text": true, "link": true, "vlink": true, "alink": true, "bordercolor": true,
+	}
+
+	// css named colours
+	namedCssColors = map[string]string{
+		"aliceblue": "#f0f8ff", "antiquewhite": "#faebd7", "aqua": "#00ffff", "aquamarine": "#7fffd4",
+		"azure": "#f0ffff", "beige": "#f5f5dc", "bisque": "#ffe4c4", "black": "#000000",
+		"blanchedalmond": "#ffebcd", "blue": "#0000ff", "blueviolet": "#8a2be2", "brown": "#a52a2a",
+		"burlywood": "#deb887", "cadetblue": "#5f9ea0", "chartreuse": "#7fff00", "chocolate": "#d2691e",
+		"coral": "#ff7f50", "cornflowerblue": "#6495ed", "cornsilk": "#fff8dc", "crimson": "#dc143c",
+		"cyan": "#00ffff", "darkblue": "#00008b", "darkcyan": "#008b8b", "darkgoldenrod": "#b8860b",
+		"darkgray": "#a9a9a9", "darkgreen": "#006400", "darkgrey": "#a9a9a9", "darkkhaki": "#bdb76b",
+		"darkmagenta": "#8b008b", "darkolivegreen": "#556b2f", "darkorange": "#ff8c00", "darkorchid": "#9932cc",
+		"darkred": "#8b0000", "darksalmon": "#e9967a", "darkseagreen": "#8fbc8f", "darkslateblue": "#483d8b",
+		"darkslategray": "#2f4f4f", "darkslategrey": "#2f4f4f", "darkturquoise": "#00ced1", "darkviolet": "#9400d3",
+		"deeppink": "#ff1493", "deepskyblue": "#00bfff", "dimgray": "#696969", "dimgrey": "#696969",
+		"dodgerblue": "#1e90ff", "firebrick": "#b22222", "floralwhite": "#fffaf0", "forestgreen": "#228b22",
+		"fuchsia": "#ff00ff", "gainsboro": "#dcdcdc", "ghostwhite": "#f8f8ff", "gold": "#ffd700",
+		"goldenrod": "#daa520", "gray": "#808080", "green": "#008000", "greenyellow": "#adff2f",
+		"grey": "#808080", "honeydew": "#f0fff0", "hotpink": "#ff69b4", "indianred": "#cd5c5c",
+		"indigo": "#4b0082", "ivory": "#fffff0", "khaki": "#f0e68c", "lavender": "#e6e6fa",
+		"lavenderblush": "#fff0f5", "lawngreen": "#7cfc00", "lemonchiffon": "#fffacd", "lightblue": "#add8e6",
+		"lightcoral": "#f08080", "lightcyan": "#e0ffff", "lightgoldenrodyellow": "#fafad2", "lightgray": "#d3d3d3",
+		"lightgreen": "#90ee90", "lightgrey": "#d3d3d3", "lightpink": "#ffb6c1", "lightsalmon": "#ffa07a",
+		"lightseagreen": "#20b2aa", "lightskyblue": "#87cefa", "lightslategray": "#778899", "lightslategrey": "#778899",
+		"lightsteelblue": "#b0c4de", "lightyellow": "#ffffe0", "lime": "#00ff00", "limegreen": "#32cd32",
+		"linen": "#faf0e6", "magenta": "#ff00ff", "maroon": "#800000", "mediumaquamarine": "#66cdaa",
+		"mediumblue": "#0000cd", "mediumorchid": "#ba55d3", "mediumpurple": "#9370db", "mediumseagreen": "#3cb371",
+		"mediumslateblue": "#7b68ee", "mediumspringgreen": "#00fa9a", "mediumturquoise": "#48d1cc", "mediumvioletred": "#c71585",
+		"midnightblue": "#191970", "mintcream": "#f5fffa", "mistyrose": "#ffe4e1", "moccasin": "#ffe4b5",
+		"navajowhite": "#ffdead", "navy": "#000080", "oldlace": "#fdf5e6", "olive": "#808000",
+		"olivedrab": "#6b8e23", "orange": "#ffa500", "orangered": "#ff4500", "orchid": "#da70d6",
+		"palegoldenrod": "#eee8aa", "palegreen": "#98fb98", "paleturquoise": "#afeeee", "palevioletred": "#db7093",
+		"papayawhip": "#ffefd5", "peachpuff": "#ffdab9", "peru": "#cd853f", "pink": "#ffc0cb",
+		"plum": "#dda0dd", "powderblue": "#b0e0e6", "purple": "#800080", "rebeccapurple": "#663399",
+		"red": "#ff0000", "rosybrown": "#bc8f8f", "royalblue": "#4169e1", "saddlebrown": "#8b4513",
+		"salmon": "#fa8072", "sandybrown": "#f4a460", "seagreen": "#2e8b57", "seashell": "#fff5ee",
+		"sienna": "#a0522d", "silver": "#c0c0c0", "skyblue": "#87ceeb", "slateblue": "#6a5acd",
+		"slategray": "#708090", "slategrey": "#708090", "snow": "#fffafa", "springgreen": "#00ff7f",
+		"steelblue": "#4682b4", "tan": "#d2b48c", "teal": "#008080", "thistle": "#d8bfd8",
+		"tomato": "#ff6347", "turquoise": "#40e0d0", "violet": "#ee82ee", "wheat": "#f5deb3",
+		"white": "#ffffff", "whitesmoke": "#f5f5f5", "yellow": "#ffff00", "yellowgreen": "#9acd32",
+	}
+)
+
+type PaletteColor struct {
+	Hex               string   `json:"hex"`
+	Values            []string `json:"values"`  // original spellings of colour
+	Sources           []string `json:"sources"` // css properties and html attributes
+	Count             int      `json:"count"`
+	Lines             []int    `json:"lines"`
+	NearestBrandColor string   `json:"nearest_brand_color"`
+	BrandDistance     float64  `json:"brand_distance"`
+	Issues            []string `json:"issues"`
+	Line              int      `json:"line"`
+	File              string   `json:"file,omitempty"`
+	Snippet           string   `json:"snippet"`
+	Context           string   `json:"context"`
+}
+
+type ColorPalette struct {
+	Colors      []PaletteColor `json:"colors"`
+	BrandColors []string       `json:"brand_colors"`
+	More        bool           `json:"more"`
+}
+
+type rgbaColor struct {
+	r, g, b uint8
+	a       float64
+}
+
+func (c rgbaColor) hex() string {
+	if c.a < 1 {
+		return fmt.Sprintf("#%02x%02x%02x%02x", c.r, c.g, c.b, uint8(math.Round(c.a*255)))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+}
+
+// lab convert colour to CIE L*a*b* (D65) to compare colours as human see them
+func (c rgbaColor) lab() [3]float64 {
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.r), linear(c.g), linear(c.b)
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 0.008856 {
+			return math.Cbrt(t)
+		}
+		return 7.787*t + 16.0/116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// luminance return relative luminance (WCAG) of colour
+func (c rgbaColor) luminance() float64 {
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.r) + 0.7152*linear(c.g) + 0.0722*linear(c.b)
+}
+
+func colorsDistance(c1, c2 rgbaColor) float64 {
+	lab1, lab2 := c1.lab(), c2.lab()
+	return math.Sqrt(math.Pow(lab1[0]-lab2[0], 2) + math.Pow(lab1[1]-lab2[1], 2) + math.Pow(lab1[2]-lab2[2], 2))
+}
+
+func parseHexColor(value string) (rgbaColor, bool) {
+	value = strings.TrimPrefix(value, "#")
+	switch len(value) {
+	case 3, 4:
+		expanded := make([]byte, 0, len(value)*2)
+		for i := 0; i < len(value); i++ {
+			expanded = append(expanded, value[i], value[i])
+		}
+		value = string(expanded)
+	case 6, 8:
+	default:
+		return rgbaColor{}, false
+	}
+
+	number, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return rgbaColor{}, false
+	}
+	if len(value) == 6 {
+		return rgbaColor{uint8(number >> 16), uint8(number >> 8), uint8(number), 1}, true
+	}
+	return rgbaColor{uint8(number >> 24), uint8(number >> 16), uint8(number >> 8), float64(uint8(number)) / 255}, true
+}
+
+func clampColorChannel(value float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, value))))
+}
+
+// colorFunctionArgs return numeric arguments of rgb()/hsl(), where percentages are in 0..1
+func colorFunctionArgs(values []css.Token) ([]float64, []bool, bool) {
+	var (
+		args        []float64
+		percentages []bool
+	)
+	for _, val := range values {
+		data := strings.ToLower(string(val.Data))
+		switch val.TokenType {
+		case css.NumberToken:
+			number, err := strconv.ParseFloat(data, 64)
+			if err != nil {
+				return nil, nil, false
+			}
+			args, percentages = append(args, number), append(percentages, false)
+		case css.PercentageToken:
+			number, err := strconv.ParseFloat(strings.TrimSuffix(data, "%"), 64)
+			if err != nil {
+				return nil, nil, false
+			}
+			args, percentages = append(args, number/100), append(percentages, true)
+		case css.DimensionToken:
+			unit := strings.TrimLeft(data, "+-.0123456789")
+			number, err := strconv.ParseFloat(strings.TrimSuffix(data, unit), 64)
+			if err != nil {
+				return nil, nil, false
+			}
+			switch unit {
+			case "deg":
+			case "turn":
+				number *= 360
+			case "rad":
+				number *= 180 / math.Pi
+			case "grad":
+				number *= 0.9
+			default:
+				return nil, nil, false
+			}
+			args, percentages = append(args, number), append(percentages, false)
+		case css.CommaToken, css.WhitespaceToken:
+		case css.DelimToken:
+			if data != "/" {
+				return nil, nil, false
+			}
+		default:
+			return nil, nil, false // var(), calc() and keywords
+		}
+	}
+	return args, percentages, len(args) == 3 || len(args) == 4
+}
+
+func hslToRgb(h, s, l float64) (float64, float64, float64) {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 360
+	channel := func(t float64) float64 {
+		t = math.Mod(t+1, 1)
+		q := l + s - l*s
+		if l < 0.5 {
+			q = l * (1 + s)
+		}
+		p := 2*l - q
+		switch {
+		case t < 1.0/6:
+			return p + (q-p)*6*t
+		case t < 0.5:
+			return q
+		case t < 2.0/3:
+			return p + (q-p)*(2.0/3-t)*6
+		}
+		return p
+	}
+	return channel(h+1.0/3) * 255, channel(h) * 255, channel(h-1.0/3) * 255
+}
+
+// parseColorFunction convert rgb(), rgba(), hsl() and hsla() arguments to colour
+func parseColorFunction(name string, values []css.Token) (rgbaColor, bool) {
+	args, percentages, ok := colorFunctionArgs(values)
+	if !ok {
+		return rgbaColor{}, false
+	}
+
+	color := rgbaColor{a: 1}
+	if len(args) == 4 {
+		color.a = math.Max(0, math.Min(1, args[3]))
+	}
+
+	switch strings.TrimSuffix(name, "a") {
+	case "rgb":
+		channels := [3]float64{}
+		for i := 0; i < 3; i++ {
+			channels[i] = args[i]
+			if percentages[i] {
+				channels[i] = args[i] * 255
+			}
+		}
+		color.r, color.g, color.b = clampColorChannel(channels[0]), clampColorChannel(channels[1]), clampColorChannel(channels[2])
+	case "hsl":
+		s, l := args[1], args[2]
+		if !percentages[1] {
+			s /= 100
+		}
+		if !percentages[2] {
+			l /= 100
+		}
+		r, g, b := hslToRgb(args[0], math.Max(0, math.Min(1, s)), math.Max(0, math.Min(1, l)))
+		color.r, color.g, color.b = clampColorChannel(r), clampColorChannel(g), clampColorChannel(b)
+	default:
+		return rgbaColor{}, false
+	}
+	return color, true
+}
+
+// isCssColorProperty detect properties, where idents can be named colours
+func isCssColorProperty(propertyKey string) bool {
+	for _, prefix := range []string{"background", "border", "outline", "column-rule", "text-decoration", "text-emphasis"} {
+		if strings.HasPrefix(propertyKey, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(propertyKey, "color") || strings.Contains(propertyKey, "shadow") ||
+		propertyKey == "fill" || propertyKey == "stroke"
+}
+
+type cssColorValue struct {
+	value string
+	color rgbaColor
+}
+
+// parseCssColors find hex, named and functional colours in css value
+func parseCssColors(propertyKey string, values []css.Token) []cssColorValue {
+	var colors []cssColorValue
+	namedAllowed := isCssColorProperty(strings.ToLower(propertyKey))
+
+	for i := 0; i < len(values); i++ {
+		val := values[i]
+		data := strings.ToLower(string(val.Data))
+
+		switch val.TokenType {
+		case css.HashToken:
+			if color, ok := parseHexColor(data); ok {
+				colors = append(colors, cssColorValue{data, color})
+			}
+		case css.IdentToken:
+			if hex, ok := namedCssColors[data]; namedAllowed && ok {
+				color, _ := parseHexColor(hex)
+				colors = append(colors, cssColorValue{data, color})
+			}
+		case css.FunctionToken:
+			name := strings.TrimSuffix(data, "(")
+			if name != "rgb" && name != "rgba" && name != "hsl" && name != "hsla" {
+				continue
+			}
+			depth, j := 1, i+1
+			for ; j < len(values) && depth > 0; j++ {
+				switch values[j].TokenType {
+				case css.FunctionToken, css.LeftParenthesisToken:
+					depth += 1
+				case css.RightParenthesisToken:
+					depth -= 1
+				}
+			}
+			argsEnd := j
+			if depth == 0 {
+				argsEnd = j - 1
+			}
+			if color, ok := parseColorFunction(name, values[i+1:argsEnd]); ok {
+				colors = append(colors, cssColorValue{limitSnippet(cssTokensToValue(values[i:j])), color})
+			}
+			i = j - 1
+		}
+	}
+
+	return colors
+}
+
+// parseHtmlColor parse colour attributes, legacy values can be without "#"
+func parseHtmlColor(value string) (rgbaColor, bool) {
+	value = strings.ToLower(strings.Trim(value, WHITESPACE))
+	if hex, ok := namedCssColors[value]; ok {
+		value = hex
+	}
+	if color, ok := parseHexColor(value); ok && color.a == 1 {
+		return color, true
+	}
+	for _, val := range parseCssColors("color", tokenizeCssValue(value)) {
+		return val.color, true
+	}
+	return rgbaColor{}, false
+}
+
+func (prs *ParserEngine) saveToReportColor(value, source string, color rgbaColor, position SourcePosition) {
+	hex := color.hex()
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if index, ok := prs.colorPaletteIndex[hex]; ok {
+		paletteColor := &prs.pr.ColorPalette.Colors[index]
+		paletteColor.Count += 1
+		if len(paletteColor.Lines) < LIMIT_REPORT_LINES && !slices.Contains(paletteColor.Lines, position.Line) {
+			paletteColor.Lines = append(paletteColor.Lines, position.Line)
+		}
+		if len(paletteColor.Values) < LIMIT_COLOR_VALUES && !slices.Contains(paletteColor.Values, value) {
+			paletteColor.Values = append(paletteColor.Values, value)
+		}
+		if len(paletteColor.Sources) < LIMIT_COLOR_VALUES && !slices.Contains(paletteColor.Sources, source) {
+			paletteColor.Sources = append(paletteColor.Sources, source)
+		}
+		if position.Line < paletteColor.Line {
+			paletteColor.Line, paletteColor.Snippet = position.Line, position.Snippet
+		}
+		return
+	}
+
+	if len(prs.pr.ColorPalette.Colors) >= LIMIT_REPORT_COLORS {
+		prs.pr.ColorPalette.More = true
+		return
+	}
+
+	prs.colorPaletteIndex[hex] = len(prs.pr.ColorPalette.Colors)
+	prs.pr.ColorPalette.Colors = append(prs.pr.ColorPalette.Colors, PaletteColor{
+		Hex:     hex,
+		Values:  []string{value},
+		Sources: []string{source},
+		Count:   1,
+		Lines:   []int{position.Line},
+		Line:    position.Line,
+		Snippet: position.Snippet,
+	})
+}
+
+// checkCssColors collect colours from css declaration
+func (prs *ParserEngine) checkCssColors(propertyKey string, values []css.Token, position SourcePosition) {
+	for _, val := range parseCssColors(propertyKey, values) {
+		prs.saveToReportColor(val.value, "css "+strings.ToLower(propertyKey), val.color, position)
+	}
+}
+
+// checkHtmlColors collect colours from legacy html attributes
+func (prs *ParserEngine) checkHtmlColors(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	for _, att := range attrs {
+		attrKey := strings.ToLower(att.Key)
+		if !htmlColorAttributes[attrKey] {
+			continue
+		}
+		if color, ok := parseHtmlColor(att.Val); ok {
+			prs.saveToReportColor(strings.ToLower(strings.Trim(att.Val, WHITESPACE)), tagName+"["+attrKey+"]", color, attributePosition(attrsPositions, attrKey, position))
+		}
+	}
+}
+
+// parseBrandColors normalize colours from parser options, invalid colours are ignored
+func parseBrandColors(values []string) map[string]rgbaColor {
+	brandColors := make(map[string]rgbaColor)
+	for _, value := range values {
+		if color, ok := parseHtmlColor(value); ok {
+			color.a = 1
+			brandColors[color.hex()] = color
+		}
+	}
+	return brandColors
+}
+
+// checkColorPalette compare colours with brand palette, should be called after all css processed
+func (prs *ParserEngine) checkColorPalette() {
+	palette := &prs.pr.ColorPalette
+
+	sort.SliceStable(palette.Colors, func(i, j int) bool {
+		if palette.Colors[i].Count != palette.Colors[j].Count {
+			return palette.Colors[i].Count > palette.Colors[j].Count
+		}
+		return palette.Colors[i].Line < palette.Colors[j].Line
+	})
+	for i := range palette.Colors {
+		sort.Ints(palette.Colors[i].Lines)
+		prs.colorPaletteIndex[palette.Colors[i].Hex] = i // keep index valid for later lookups
+	}
+
+	brandColors := parseBrandColors(prs.options.BrandColors)
+	if len(brandColors) == 0 {
+		return
+	}
+	for hex := range brandColors {
+		palette.BrandColors = append(palette.BrandColors, hex)
+	}
+	sort.Strings(palette.BrandColors)
+
+	tolerance := prs.options.BrandColorsTolerance
+	if tolerance <= 0 {
+		tolerance = DEFAULT_BRAND_COLOR_DELTAE
+	}
+
+	for i := range palette.Colors {
+		paletteColor := &palette.Colors[i]
+		color, _ := parseHexColor(paletteColor.Hex)
+		color.a = 1
+
+		paletteColor.BrandDistance = math.Inf(1)
+		for _, hex := range palette.BrandColors {
+			if distance := colorsDistance(color, brandColors[hex]); distance < paletteColor.BrandDistance {
+				paletteColor.NearestBrandColor, paletteColor.BrandDistance = hex, distance
+			}
+		}
+		paletteColor.BrandDistance = math.Round(paletteColor.BrandDistance*100) / 100
+
+		switch {
+		case paletteColor.BrandDistance == 0:
+			continue
+		case paletteColor.BrandDistance <= tolerance:
+			paletteColor.Issues = append(paletteColor.Issues, COLOR_ISSUE_NEAR_BRAND)
+		default:
+			paletteColor.Issues = append(paletteColor.Issues, COLOR_ISSUE_OUTSIDE_BRAND)
+		}
+	}
+}
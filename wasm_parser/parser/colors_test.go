@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+func TestParseCssColors(t *testing.T) {
+	tests := []struct {
+		property string
+		value    string
+		want     []string
+	}{
+		{"color", "#FFF", []string{"#ffffff"}},
+		{"color", "#ff000080", []string{"#ff000080"}},
+		{"background", "linear-gradient(90deg, Red 0%, rgb(0 128 0 / 50%) 100%)", []string{"#ff0000", "#00800080"}},
+		{"border", "1px solid rgba(0,0,255,1)", []string{"#0000ff"}},
+		{"background-color", "hsl(120deg, 100%, 25%)", []string{"#008000"}},
+		{"color", "rgb(100%, 0%, 0%)", []string{"#ff0000"}},
+		{"color", "var(--brand, #123456)", []string{"#123456"}},
+		{"color", "rgb(var(--r), 0, 0)", nil},
+		{"color", "transparent", nil},
+		{"font-family", "Red Hat", nil},
+		{"--accent", "navy", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, val := range parseCssColors(tt.property, tokenizeCssValue(tt.value)) {
+			got = append(got, val.color.hex())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCssColors(%q, %q): got %v, want %v", tt.property, tt.value, got, tt.want)
+		}
+	}
+
+	if colors := parseCssColors("color", []css.Token{}); len(colors) != 0 {
+		t.Errorf("parseCssColors with empty value: got %v", colors)
+	}
+}
+
+func TestReportFromHTMLColorPalette(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.title { color: #FF0000; }
+		.link { color: #fe0101; border-bottom: 1px solid red; }
+	</style>
+</head>
+<body bgcolor="ffffff">
+	<div style="background-color: #00f">Content</div>
+</body></html>`
+	report, err := ReportFromHTMLWithOptions([]byte(html), ParserOptions{
+		BrandColors: []string{"#ff0000", "white"},
+	})
+	if err != nil {
+		t.Fatalf(`ReportFromHTMLWithOptions("%s"), %v`, html, err)
+	}
+
+	type colorSummary struct {
+		Hex    string
+		Count  int
+		Lines  []int
+		Brand  string
+		Issues []string
+	}
+
+	got := make([]colorSummary, 0, len(report.ColorPalette.Colors))
+	for _, color := range report.ColorPalette.Colors {
+		got = append(got, colorSummary{color.Hex, color.Count, color.Lines, color.NearestBrandColor, color.Issues})
+	}
+
+	want := []colorSummary{
+		{"#ff0000", 2, []int{4, 5}, "#ff0000", nil},
+		{"#fe0101", 1, []int{5}, "#ff0000", []string{COLOR_ISSUE_NEAR_BRAND}},
+		{"#ffffff", 1, []int{8}, "#ffffff", nil},
+		{"#0000ff", 1, []int{9}, "#ffffff", []string{COLOR_ISSUE_OUTSIDE_BRAND}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ColorPalette Colors: got %v, want %v", got, want)
+	}
+
+	wantBrand := []string{"#ff0000", "#ffffff"}
+	if !reflect.DeepEqual(report.ColorPalette.BrandColors, wantBrand) {
+		t.Errorf("ColorPalette BrandColors: got %v, want %v", report.ColorPalette.BrandColors, wantBrand)
+	}
+}
+
+func TestColorPaletteIndexAfterSort(t *testing.T) {
+	prs := InitParserWithOptions(ParserOptions{})
+	red, _ := parseHexColor("#ff0000")
+	blue, _ := parseHexColor("#0000ff")
+
+	prs.saveToReportColor("red", "color", red, SourcePosition{Line: 1})
+	prs.saveToReportColor("blue", "color", blue, SourcePosition{Line: 2})
+	prs.saveToReportColor("blue", "color", blue, SourcePosition{Line: 3})
+	prs.checkColorPalette() // blue is used more and moved first
+
+	prs.saveToReportColor("red", "color", red, SourcePosition{Line: 4})
+
+	got := make(map[string]int)
+	for _, color := range prs.pr.ColorPalette.Colors {
+		got[color.Hex] = color.Count
+	}
+	want := map[string]int{red.hex(): 2, blue.hex(): 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ColorPalette counts: got %v, want %v", got, want)
+	}
+}
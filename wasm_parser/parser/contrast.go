@@ -0,0 +1,648 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	parse "github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+// WCAG 2.x contrast requirements
+const (
+	WCAG_AA_NORMAL_TEXT       = 4.5
+	WCAG_AA_LARGE_TEXT        = 3.0
+	WCAG_AAA_NORMAL_TEXT      = 7.0
+	WCAG_AAA_LARGE_TEXT       = 4.5
+	WCAG_LARGE_TEXT_SIZE      = 24.0  // 18pt in px
+	WCAG_LARGE_BOLD_TEXT_SIZE = 18.66 // 14pt in px
+
+	CONTRAST_SCHEME_LIGHT = "light"
+	CONTRAST_SCHEME_DARK  = "dark"
+	CONTRAST_LEVEL_AA     = "aa"
+	CONTRAST_LEVEL_AAA    = "aaa"
+
+	LIMIT_CONTRAST_ISSUES = 100
+	// attribute, which link parsed elements with tokens of document
+	CONTRAST_TOKEN_ATTRIBUTE = "data-vmail-token"
+)
+
+var (
+	defaultTextColor       = rgbaColor{0, 0, 0, 1}
+	defaultBackgroundColor = rgbaColor{255, 255, 255, 1}
+	defaultLinkColor       = rgbaColor{0, 0, 238, 1}
+
+	// font size keywords in px
+	cssFontSizeKeywords = map[string]float64{
+		"xx-small": 9, "x-small": 10, "small": 13, "medium": 16, "large": 18, "x-large": 24, "xx-large": 32, "xxx-large": 48,
+	}
+	// user agent font sizes of elements relative to parent
+	htmlElementFontScale = map[a.Atom]float64{
+		a.H1: 2, a.H2: 1.5, a.H3: 1.17, a.H5: 0.83, a.H6: 0.67, a.Small: 0.83,
+	}
+	htmlBoldElements = map[a.Atom]bool{
+		a.B: true, a.Strong: true, a.Th: true, a.H1: true, a.H2: true, a.H3: true, a.H4: true, a.H5: true, a.H6: true,
+	}
+	// elements without rendered text
+	htmlSkippedContrastElements = map[a.Atom]bool{
+		a.Head: true, a.Style: true, a.Script: true, a.Template: true, a.Title: true, a.Noscript: true,
+	}
+	// elements with legacy bgcolor attribute
+	htmlBgcolorElements = map[a.Atom]bool{
+		a.Body: true, a.Table: true, a.Tr: true, a.Td: true, a.Th: true,
+	}
+)
+
+// ContrastIssue is text element, which colours contrast lower than WCAG requirement
+type ContrastIssue struct {
+	Scheme     string  `json:"scheme"` // light or dark (prefers-color-scheme: dark)
+	Level      string  `json:"level"`  // failed level: aa or aaa
+	Ratio      float64 `json:"ratio"`
+	Required   float64 `json:"required"`
+	Foreground string  `json:"foreground"`
+	Background string  `json:"background"`
+	LargeText  bool    `json:"large_text"`
+	Text       string  `json:"text"`
+	Line       int     `json:"line"`
+	File       string  `json:"file,omitempty"`
+	Snippet    string  `json:"snippet"`
+	Context    string  `json:"context"`
+}
+
+type ContrastReport struct {
+	Issues []ContrastIssue `json:"issues"`
+	More   bool            `json:"more"`
+}
+
+type contrastDeclaration struct {
+	values    []css.Token
+	important bool
+	inline    bool
+	hint      bool // presentational html attribute
+	specifity cascadia.Specificity
+	order     int
+}
+
+// isHigherThan compare declarations by cascade: importance, inline style, specificity and order
+func (decl contrastDeclaration) isHigherThan(other contrastDeclaration) bool {
+	if decl.important != other.important {
+		return decl.important
+	}
+	if decl.inline != other.inline {
+		return decl.inline
+	}
+	if decl.hint != other.hint {
+		return other.hint
+	}
+	if decl.specifity != other.specifity {
+		return other.specifity.Less(decl.specifity)
+	}
+	return decl.order > other.order
+}
+
+type contrastRule struct {
+	selector     cascadia.Sel
+	dark         bool
+	declarations map[string]contrastDeclaration
+}
+
+type contrastTokenRef struct {
+	line int
+	raw  string
+}
+
+// contrastStyle is computed style of element, which matters for contrast
+type contrastStyle struct {
+	color             rgbaColor // can be translucent, blended with background on check like in browsers
+	background        rgbaColor
+	backgroundUnknown bool // image or gradient under text
+	fontSize          float64
+	bold              bool
+	hidden            bool
+}
+
+type contrastChecker struct {
+	prs    *ParserEngine
+	rules  []contrastRule
+	tokens []contrastTokenRef
+	order  int
+	seen   map[string]bool
+}
+
+// isContrastApplicableAtRule return applicability of rules inside at-rule and is it dark mode only
+func isContrastApplicableAtRule(atRule string) (applicable bool, dark bool) {
+	atRule = strings.ToLower(atRule)
+	switch {
+	case isDarkModeMedia(atRule):
+		return true, true
+	case strings.HasPrefix(atRule, "@supports"):
+		return true, false
+	case strings.HasPrefix(atRule, "@media"):
+		// screen size and print styles are not applied on desktop
+		return !strings.Contains(atRule, "width") && !strings.Contains(atRule, "height") && !strings.Contains(atRule, "print"), false
+	}
+	return false, false
+}
+
+// splitImportant remove "!important" from declaration values
+func splitImportant(values []css.Token) ([]css.Token, bool) {
+	for i := 0; i+1 < len(values); i++ {
+		if values[i].TokenType == css.DelimToken && string(values[i].Data) == "!" {
+			for j := i + 1; j < len(values); j++ {
+				if values[j].TokenType == css.WhitespaceToken {
+					continue
+				}
+				if values[j].TokenType == css.IdentToken && strings.EqualFold(string(values[j].Data), "important") {
+					return values[:i], true
+				}
+				break
+			}
+		}
+	}
+	return values, false
+}
+
+func copyCssTokens(values []css.Token) []css.Token {
+	copied := make([]css.Token, len(values))
+	for i, val := range values {
+		copied[i] = css.Token{TokenType: val.TokenType, Data: bytes.Clone(val.Data)}
+	}
+	return copied
+}
+
+// collectStyleRules parse style element content into rules with selectors
+func (cc *contrastChecker) collectStyleRules(content string, media string) {
+	styleApplicable, styleDark := true, false
+	if len(media) > 0 {
+		styleApplicable, styleDark = isContrastApplicableAtRule("@media " + media)
+		if strings.ToLower(strings.TrimSpace(media)) == "all" || strings.ToLower(strings.TrimSpace(media)) == "screen" {
+			styleApplicable = true
+		}
+	}
+
+	var (
+		atRules      []string
+		selector     string
+		declarations map[string]contrastDeclaration
+	)
+
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(content)), false)
+	for {
+		gt, _, data := p.Next()
+		switch gt {
+		case css.ErrorGrammar:
+			return
+		case css.BeginAtRuleGrammar:
+			atRules = append(atRules, cssGrammarSnippet(gt, data, p.Values()))
+		case css.EndAtRuleGrammar:
+			if len(atRules) > 0 {
+				atRules = atRules[:len(atRules)-1]
+			}
+		case css.QualifiedRuleGrammar:
+			selector += cssGrammarSnippet(gt, data, p.Values()) + ", "
+		case css.BeginRulesetGrammar:
+			selector += cssGrammarSnippet(gt, data, p.Values())
+			declarations = make(map[string]contrastDeclaration)
+		case css.DeclarationGrammar:
+			if declarations == nil {
+				continue
+			}
+			values, important := splitImportant(p.Values())
+			cc.order += 1
+			declarations[strings.ToLower(string(data))] = contrastDeclaration{
+				values:    copyCssTokens(values),
+				important: important,
+				order:     cc.order,
+			}
+		case css.EndRulesetGrammar:
+			applicable, dark := styleApplicable, styleDark
+			for _, atRule := range atRules {
+				ruleApplicable, ruleDark := isContrastApplicableAtRule(atRule)
+				applicable, dark = applicable && ruleApplicable, dark || ruleDark
+			}
+			if group, err := cascadia.ParseGroup(selector); applicable && err == nil && len(declarations) > 0 {
+				for _, sel := range group {
+					cc.rules = append(cc.rules, contrastRule{selector: sel, dark: dark, declarations: declarations})
+				}
+			}
+			selector, declarations = "", nil
+		}
+	}
+}
+
+// annotateDocument add token index attribute to each start tag, so parsed nodes can be mapped to lines
+func (cc *contrastChecker) annotateDocument(document []byte) []byte {
+	var (
+		out    bytes.Buffer
+		offset int
+		line   int
+	)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(document))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				break
+			}
+			return nil
+		}
+		raw := tokenizer.Raw()
+		line = cc.prs.getLineFromOffset(line, offset)
+		offset += len(raw)
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+		token := tokenizer.Token()
+		token.Attr = append(token.Attr, html.Attribute{Key: CONTRAST_TOKEN_ATTRIBUTE, Val: strconv.Itoa(len(cc.tokens))})
+		cc.tokens = append(cc.tokens, contrastTokenRef{line: line, raw: string(raw)})
+		out.WriteString(token.String())
+	}
+	return out.Bytes()
+}
+
+func (cc *contrastChecker) tokenRef(node *html.Node) (contrastTokenRef, bool) {
+	index, err := strconv.Atoi(getHtmlAttributeValue(node.Attr, CONTRAST_TOKEN_ATTRIBUTE))
+	if err != nil || index < 0 || index >= len(cc.tokens) {
+		return contrastTokenRef{}, false
+	}
+	return cc.tokens[index], true
+}
+
+func colorHint(value string) (contrastDeclaration, bool) {
+	color, ok := parseHtmlColor(value)
+	if !ok {
+		return contrastDeclaration{}, false
+	}
+	return contrastDeclaration{values: tokenizeCssValue(color.hex()), hint: true}, true
+}
+
+// cascade find winning declarations for element
+func (cc *contrastChecker) cascade(node *html.Node, dark bool) map[string]contrastDeclaration {
+	winners := make(map[string]contrastDeclaration)
+	apply := func(property string, decl contrastDeclaration) {
+		if current, ok := winners[property]; !ok || decl.isHigherThan(current) {
+			winners[property] = decl
+		}
+	}
+
+	// presentational attributes
+	if htmlBgcolorElements[node.DataAtom] {
+		if decl, ok := colorHint(getHtmlAttributeValue(node.Attr, "bgcolor")); ok {
+			apply("background-color", decl)
+		}
+	}
+	switch node.DataAtom {
+	case a.Font:
+		if decl, ok := colorHint(getHtmlAttributeValue(node.Attr, "color")); ok {
+			apply("color", decl)
+		}
+	case a.Body:
+		if decl, ok := colorHint(getHtmlAttributeValue(node.Attr, "text")); ok {
+			apply("color", decl)
+		}
+	}
+
+	for _, rule := range cc.rules {
+		if rule.dark && !dark {
+			continue
+		}
+		if !rule.selector.Match(node) {
+			continue
+		}
+		for property, decl := range rule.declarations {
+			decl.specifity = rule.selector.Specificity()
+			apply(property, decl)
+		}
+	}
+
+	if inlineStyle := getHtmlAttributeValue(node.Attr, "style"); len(inlineStyle) > 0 {
+		p := css.NewParser(parse.NewInput(bytes.NewBufferString(inlineStyle)), true)
+		for order := 0; ; order++ {
+			gt, _, data := p.Next()
+			if gt == css.ErrorGrammar {
+				break
+			}
+			if gt != css.DeclarationGrammar {
+				continue
+			}
+			values, important := splitImportant(p.Values())
+			apply(strings.ToLower(string(data)), contrastDeclaration{
+				values:    copyCssTokens(values),
+				important: important,
+				inline:    true,
+				order:     order,
+			})
+		}
+	}
+
+	// background shorthand reset background colour
+	if background, ok := winners["background"]; ok {
+		if backgroundColor, ok := winners["background-color"]; !ok || background.isHigherThan(backgroundColor) {
+			winners["background-color"] = background
+		}
+	}
+	return winners
+}
+
+func firstCssIdent(values []css.Token) string {
+	for _, val := range values {
+		if val.TokenType == css.IdentToken || val.TokenType == css.NumberToken {
+			return strings.ToLower(string(val.Data))
+		}
+	}
+	return ""
+}
+
+func hasCssUrlOrGradient(values []css.Token) bool {
+	for _, val := range values {
+		data := strings.ToLower(string(val.Data))
+		if val.TokenType == css.URLToken || (val.TokenType == css.FunctionToken && (data == "url(" || strings.Contains(data, "gradient("))) {
+			return true
+		}
+	}
+	return false
+}
+
+// cssFontSize compute font size in px relative to parent size
+func cssFontSize(values []css.Token, parentSize float64) (float64, bool) {
+	for _, val := range values {
+		data := strings.ToLower(string(val.Data))
+		switch val.TokenType {
+		case css.IdentToken:
+			if size, ok := cssFontSizeKeywords[data]; ok {
+				return size, true
+			}
+			switch data {
+			case "smaller":
+				return parentSize / 1.2, true
+			case "larger":
+				return parentSize * 1.2, true
+			}
+		case css.NumberToken:
+			if size, err := strconv.ParseFloat(data, 64); err == nil && size == 0 {
+				return 0, true
+			}
+		case css.DimensionToken, css.PercentageToken:
+			unit := strings.TrimLeft(data, "+-0123456789.")
+			size, err := strconv.ParseFloat(strings.TrimSuffix(data, unit), 64)
+			if err != nil {
+				return 0, false
+			}
+			switch unit {
+			case "px":
+				return size, true
+			case "pt":
+				return size * 4 / 3, true
+			case "em":
+				return size * parentSize, true
+			case "rem":
+				return size * ACCESSIBILITY_BASE_FONT_SIZE, true
+			case "%":
+				return size * parentSize / 100, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// blendColor put colour with alpha over opaque background
+func blendColor(color, background rgbaColor) rgbaColor {
+	if color.a >= 1 {
+		return color
+	}
+	mix := func(c, b uint8) uint8 {
+		return clampColorChannel(float64(c)*color.a + float64(b)*(1-color.a))
+	}
+	return rgbaColor{mix(color.r, background.r), mix(color.g, background.g), mix(color.b, background.b), 1}
+}
+
+// contrastRatio is WCAG 2.x contrast ratio of two opaque colours
+func contrastRatio(first, second rgbaColor) float64 {
+	l1, l2 := first.luminance(), second.luminance()
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return math.Round((l1+0.05)/(l2+0.05)*100) / 100
+}
+
+func cssColorFromValues(property string, values []css.Token) (rgbaColor, bool) {
+	if firstCssIdent(values) == "transparent" {
+		return rgbaColor{}, true
+	}
+	for _, val := range parseCssColors(property, values) {
+		return val.color, true
+	}
+	return rgbaColor{}, false
+}
+
+// computeStyle apply winning declarations of element on inherited style
+func (cc *contrastChecker) computeStyle(node *html.Node, parent contrastStyle, dark bool) contrastStyle {
+	style := parent
+	if scale, ok := htmlElementFontScale[node.DataAtom]; ok {
+		style.fontSize = parent.fontSize * scale
+	}
+	if htmlBoldElements[node.DataAtom] {
+		style.bold = true
+	}
+
+	declarations := cc.cascade(node, dark)
+
+	if decl, ok := declarations["background-color"]; ok {
+		if color, ok := cssColorFromValues("background-color", decl.values); ok && color.a > 0 {
+			style.background = blendColor(color, parent.background)
+			style.backgroundUnknown = false
+		}
+		if hasCssUrlOrGradient(decl.values) {
+			style.backgroundUnknown = true
+		}
+	}
+	if decl, ok := declarations["background-image"]; ok && hasCssUrlOrGradient(decl.values) {
+		style.backgroundUnknown = true
+	}
+	if node.DataAtom == a.Td || node.DataAtom == a.Table {
+		if len(getHtmlAttributeValue(node.Attr, "background")) > 0 {
+			style.backgroundUnknown = true
+		}
+	}
+
+	if decl, ok := declarations["color"]; ok {
+		if color, ok := cssColorFromValues("color", decl.values); ok {
+			style.color = color
+		}
+	} else if node.DataAtom == a.A && hasHtmlAttribute(node.Attr, "href") {
+		style.color = defaultLinkColor
+	}
+
+	if decl, ok := declarations["font-size"]; ok {
+		if size, ok := cssFontSize(decl.values, parent.fontSize); ok {
+			style.fontSize = size
+		}
+	}
+	if decl, ok := declarations["font-weight"]; ok {
+		switch weight := firstCssIdent(decl.values); weight {
+		case "bold", "bolder":
+			style.bold = true
+		case "normal", "lighter":
+			style.bold = false
+		default:
+			if value, err := strconv.Atoi(weight); err == nil {
+				style.bold = value >= 700
+			}
+		}
+	}
+
+	if decl, ok := declarations["display"]; ok && firstCssIdent(decl.values) == "none" {
+		style.hidden = true
+	}
+	if decl, ok := declarations["visibility"]; ok && firstCssIdent(decl.values) == "hidden" {
+		style.hidden = true
+	}
+	if decl, ok := declarations["opacity"]; ok && firstCssIdent(decl.values) == "0" {
+		style.hidden = true
+	}
+	if style.fontSize == 0 {
+		style.hidden = true
+	}
+	return style
+}
+
+// directText return text of element, which is not wrapped by children elements
+func directText(node *html.Node) string {
+	var buf strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			buf.WriteString(child.Data)
+		}
+	}
+	return collapseWhitespace(buf.String())
+}
+
+func (cc *contrastChecker) checkText(node *html.Node, style contrastStyle, scheme string) {
+	if style.hidden || style.backgroundUnknown {
+		return
+	}
+	text := directText(node)
+	if len(text) == 0 {
+		return
+	}
+	ref, ok := cc.tokenRef(node)
+	if !ok {
+		return
+	}
+
+	largeText := style.fontSize >= WCAG_LARGE_TEXT_SIZE || (style.bold && style.fontSize >= WCAG_LARGE_BOLD_TEXT_SIZE)
+	requiredAA, requiredAAA := WCAG_AA_NORMAL_TEXT, WCAG_AAA_NORMAL_TEXT
+	if largeText {
+		requiredAA, requiredAAA = WCAG_AA_LARGE_TEXT, WCAG_AAA_LARGE_TEXT
+	}
+
+	foreground := blendColor(style.color, style.background)
+	ratio := contrastRatio(foreground, style.background)
+	if ratio >= requiredAAA {
+		return
+	}
+	level, required := CONTRAST_LEVEL_AAA, requiredAAA
+	if ratio < requiredAA {
+		level, required = CONTRAST_LEVEL_AA, requiredAA
+	}
+
+	key := scheme + "||" + strconv.Itoa(ref.line) + "||" + ref.raw
+	if cc.seen[key] {
+		return
+	}
+	cc.seen[key] = true
+
+	if len(cc.prs.pr.Contrast.Issues) >= LIMIT_CONTRAST_ISSUES {
+		cc.prs.pr.Contrast.More = true
+		return
+	}
+	cc.prs.pr.Contrast.Issues = append(cc.prs.pr.Contrast.Issues, ContrastIssue{
+		Scheme:     scheme,
+		Level:      level,
+		Ratio:      ratio,
+		Required:   required,
+		Foreground: foreground.hex(),
+		Background: style.background.hex(),
+		LargeText:  largeText,
+		Text:       limitSnippet(text),
+		Line:       ref.line,
+		Snippet:    limitSnippet(ref.raw),
+	})
+}
+
+func (cc *contrastChecker) walk(node *html.Node, parent contrastStyle, dark bool) {
+	style := parent
+	if node.Type == html.ElementNode {
+		if htmlSkippedContrastElements[node.DataAtom] {
+			return
+		}
+		style = cc.computeStyle(node, parent, dark)
+		scheme := CONTRAST_SCHEME_LIGHT
+		if dark {
+			scheme = CONTRAST_SCHEME_DARK
+		}
+		cc.checkText(node, style, scheme)
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		cc.walk(child, style, dark)
+	}
+}
+
+func collectStyleElements(node *html.Node, cc *contrastChecker) {
+	if node.Type == html.ElementNode && node.DataAtom == a.Style {
+		var content strings.Builder
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			content.WriteString(child.Data)
+		}
+		cc.collectStyleRules(content.String(), collapseWhitespace(getHtmlAttributeValue(node.Attr, "media")))
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		collectStyleElements(child, cc)
+	}
+}
+
+// checkContrast compute text and background colours of elements and report WCAG contrast issues
+// in light mode and, if email has dark styles, in dark mode
+func (prs *ParserEngine) checkContrast(document []byte) {
+	cc := &contrastChecker{prs: prs, seen: make(map[string]bool)}
+
+	annotated := cc.annotateDocument(document)
+	if annotated == nil {
+		return
+	}
+	doc, err := html.Parse(bytes.NewReader(annotated))
+	if err != nil {
+		return
+	}
+	collectStyleElements(doc, cc)
+
+	root := contrastStyle{
+		color:      defaultTextColor,
+		background: defaultBackgroundColor,
+		fontSize:   ACCESSIBILITY_BASE_FONT_SIZE,
+	}
+	cc.walk(doc, root, false)
+
+	for _, rule := range cc.rules {
+		if rule.dark {
+			cc.walk(doc, root, true)
+			break
+		}
+	}
+
+	sort.SliceStable(prs.pr.Contrast.Issues, func(i, j int) bool {
+		return prs.pr.Contrast.Issues[i].Line < prs.pr.Contrast.Issues[j].Line
+	})
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLContrast(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want []ContrastIssue
+	}{
+		{
+			"readable email",
+			`<html><body style="background: #ffffff; color: #222222">
+	<p>Text</p>
+	<p style="color: #ffffff; background-color: #000000">Inverted</p>
+	<div style="background: url(bg.png)"><p style="color: #ffffff">On image</p></div>
+	<p style="display: none; color: #eeeeee">Preheader</p>
+</body></html>`,
+			nil,
+		},
+		{
+			"light and dark issues",
+			`<html>
+<head><style>
+	.muted { color: #999999; }
+	.title { color: #767676 !important; }
+	@media (prefers-color-scheme: dark) {
+		body { background-color: #111111 !important; }
+	}
+</style></head>
+<body>
+	<p class="muted">Muted</p>
+	<h1 class="title" style="color: #000000">Title</h1>
+	<table bgcolor="#333333"><tr><td><font color="#555555">Legal</font></td></tr></table>
+	<p>Default</p>
+</body></html>`,
+			[]ContrastIssue{
+				{Scheme: CONTRAST_SCHEME_LIGHT, Level: CONTRAST_LEVEL_AA, Ratio: 2.85, Required: 4.5, Foreground: "#999999", Background: "#ffffff", Text: "Muted", Line: 10},
+				{Scheme: CONTRAST_SCHEME_DARK, Level: CONTRAST_LEVEL_AAA, Ratio: 6.63, Required: 7, Foreground: "#999999", Background: "#111111", Text: "Muted", Line: 10},
+				{Scheme: CONTRAST_SCHEME_DARK, Level: CONTRAST_LEVEL_AAA, Ratio: 4.16, Required: 4.5, Foreground: "#767676", Background: "#111111", LargeText: true, Text: "Title", Line: 11},
+				{Scheme: CONTRAST_SCHEME_LIGHT, Level: CONTRAST_LEVEL_AA, Ratio: 1.69, Required: 4.5, Foreground: "#555555", Background: "#333333", Text: "Legal", Line: 12},
+				{Scheme: CONTRAST_SCHEME_DARK, Level: CONTRAST_LEVEL_AA, Ratio: 1.69, Required: 4.5, Foreground: "#555555", Background: "#333333", Text: "Legal", Line: 12},
+				{Scheme: CONTRAST_SCHEME_DARK, Level: CONTRAST_LEVEL_AA, Ratio: 1.11, Required: 4.5, Foreground: "#000000", Background: "#111111", Text: "Default", Line: 13},
+			},
+		},
+		{
+			"translucent text over own background",
+			`<html><body style="background: #ffffff">
+	<p style="color: rgba(0, 0, 0, .5); background-color: #000000">Faded</p>
+	<div style="color: rgba(255, 255, 255, .5)"><p style="background-color: #ffffff">Inherited</p></div>
+</body></html>`,
+			[]ContrastIssue{
+				{Scheme: CONTRAST_SCHEME_LIGHT, Level: CONTRAST_LEVEL_AA, Ratio: 1, Required: 4.5, Foreground: "#000000", Background: "#000000", Text: "Faded", Line: 2},
+				{Scheme: CONTRAST_SCHEME_LIGHT, Level: CONTRAST_LEVEL_AA, Ratio: 1, Required: 4.5, Foreground: "#ffffff", Background: "#ffffff", Text: "Inherited", Line: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTML([]byte(tt.html))
+			if err != nil {
+				t.Fatalf("ReportFromHTML() error = %v", err)
+			}
+
+			var got []ContrastIssue
+			for _, issue := range report.Contrast.Issues {
+				issue.Snippet, issue.Context = "", ""
+				got = append(got, issue)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+)
+
+const (
+	// relative luminance, from which background considered light
+	DARK_MODE_LIGHT_LUMINANCE = 0.7
+)
+
+// dark mode readiness items
+const (
+	DARK_MODE_COLOR_SCHEME_META            = "color_scheme_meta"
+	DARK_MODE_MISSING_COLOR_SCHEME_META    = "missing_color_scheme_meta"
+	DARK_MODE_COLOR_SCHEME_CSS             = "color_scheme_css"
+	DARK_MODE_MISSING_COLOR_SCHEME_CSS     = "missing_color_scheme_css"
+	DARK_MODE_PREFERS_COLOR_SCHEME         = "prefers_color_scheme"
+	DARK_MODE_MISSING_PREFERS_COLOR_SCHEME = "missing_prefers_color_scheme"
+	DARK_MODE_OUTLOOK_SELECTORS            = "outlook_dark_selectors"
+	DARK_MODE_MISSING_OUTLOOK_SELECTORS    = "missing_outlook_dark_selectors"
+	DARK_MODE_TRANSPARENT_IMAGE            = "transparent_image"
+	DARK_MODE_LIGHT_BACKGROUND             = "light_background_without_override"
+)
+
+var (
+	// image formats, which can have transparent background
+	transparentImageFormats = map[string]bool{
+		"png": true, "gif": true, "svg": true, "webp": true, "apng": true, "avif": true,
+	}
+
+	cssSelectorAttributesRe = regexp.MustCompile(`\[[^\]]*\]`)
+	cssSelectorPseudoRe     = regexp.MustCompile(`::?[\w-]+(\([^)]*\))?`)
+	cssSelectorKeyRe        = regexp.MustCompile(`[.#]?-?[_a-zA-Z][\w-]*`)
+
+	darkModeRulesDB = map[string]map[string]interface{}{}
+)
+
+// initDarkModeRules use stats of color-scheme and prefers-color-scheme
+func initDarkModeRules() {
+	colorSchemeRule := rulesDB.CssProperties["color-scheme"][""]
+	prefersColorSchemeRule := rulesDB.AtRuleCssStatements["@media"]["prefers-color-scheme"]
+
+	darkModeRulesDB = map[string]map[string]interface{}{
+		DARK_MODE_COLOR_SCHEME_META:            makeClientsIssueRule("color-scheme meta", "Email declares supported color schemes, so clients with support of it do not invert colors automatically.", SEVERITY_INFO, colorSchemeRule),
+		DARK_MODE_MISSING_COLOR_SCHEME_META:    makeClientsIssueRule("Missing color-scheme meta", "Without <meta name=\"color-scheme\" content=\"light dark\"> clients decide by themselves, how to show email in dark mode.", SEVERITY_INFO, colorSchemeRule),
+		DARK_MODE_COLOR_SCHEME_CSS:             makeClientsIssueRule("color-scheme in CSS", "color-scheme property tells clients, which color schemes email supports.", SEVERITY_INFO, colorSchemeRule),
+		DARK_MODE_MISSING_COLOR_SCHEME_CSS:     makeClientsIssueRule("Missing color-scheme in CSS", "Email has no \"color-scheme: light dark\" declaration (usually for :root), some clients read only CSS property instead of meta.", SEVERITY_INFO, colorSchemeRule),
+		DARK_MODE_PREFERS_COLOR_SCHEME:         makeClientsIssueRule("Dark mode media query", "@media (prefers-color-scheme: dark) provides dark styles in clients with its support. Other clients ignore these rules and may invert colors by themselves.", SEVERITY_INFO, prefersColorSchemeRule),
+		DARK_MODE_MISSING_PREFERS_COLOR_SCHEME: makeClientsIssueRule("Missing dark mode media query", "Email has no @media (prefers-color-scheme: dark) rules, so in dark mode clients show it as is or invert colors automatically.", SEVERITY_INFO, prefersColorSchemeRule),
+		DARK_MODE_OUTLOOK_SELECTORS:            makeClientsIssueRule("Outlook.com dark mode selectors", "[data-ogsc] and [data-ogsb] selectors provide dark styles for Outlook.com and Outlook apps, which do not support prefers-color-scheme.", SEVERITY_INFO, prefersColorSchemeRule),
+		DARK_MODE_MISSING_OUTLOOK_SELECTORS:    makeClientsIssueRule("Missing Outlook.com dark mode selectors", "Outlook.com and Outlook apps ignore prefers-color-scheme, dark styles for them should be duplicated with [data-ogsc] (text) and [data-ogsb] (background) selectors.", SEVERITY_INFO, prefersColorSchemeRule),
+		DARK_MODE_TRANSPARENT_IMAGE:            makeClientsIssueRule("Image can have transparent background", "Dark parts of transparent images (like logos and icons) become invisible on dark backgrounds. Clients without prefers-color-scheme support change background, but not images.", SEVERITY_WARNING, prefersColorSchemeRule),
+		DARK_MODE_LIGHT_BACKGROUND:             makeClientsIssueRule("Light background without dark override", "Hard-coded light background has no override for dark mode, so it stay light in clients with prefers-color-scheme support or get inverted by other clients.", SEVERITY_WARNING, prefersColorSchemeRule),
+	}
+}
+
+type darkModeBackground struct {
+	keys     []string // selectors parts or element class, id and tag
+	position SourcePosition
+}
+
+type darkModeState struct {
+	// selectors parts with backgrounds for dark mode
+	overrides        map[string]bool
+	lightBackgrounds []darkModeBackground
+}
+
+func (prs *ParserEngine) saveToReportDarkMode(item string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.DarkMode, darkModeRulesDB, item, position)
+}
+
+// isDarkModeMedia detect "(prefers-color-scheme: dark)" condition
+func isDarkModeMedia(media string) bool {
+	return strings.Contains(strings.ReplaceAll(strings.ToLower(media), " ", ""), "prefers-color-scheme:dark")
+}
+
+// isDarkModePosition check, what declaration applies only in dark mode
+func isDarkModePosition(position SourcePosition) bool {
+	if isDarkModeMedia(position.Media) {
+		return true
+	}
+	for _, atRule := range position.AtRules {
+		if isDarkModeMedia(atRule) {
+			return true
+		}
+	}
+	selector := strings.ToLower(position.Selector)
+	return strings.Contains(selector, "[data-ogsc") || strings.Contains(selector, "[data-ogsb")
+}
+
+// cssSelectorKeys return tags, classes and ids from selector
+func cssSelectorKeys(selector string) []string {
+	selector = cssSelectorAttributesRe.ReplaceAllString(selector, " ")
+	selector = cssSelectorPseudoRe.ReplaceAllString(selector, " ")
+	return cssSelectorKeyRe.FindAllString(strings.ToLower(selector), -1)
+}
+
+func htmlElementKeys(tagName string, attrs []html.Attribute) []string {
+	keys := []string{strings.ToLower(tagName)}
+	for _, class := range strings.Fields(getHtmlAttributeValue(attrs, "class")) {
+		keys = append(keys, "."+strings.ToLower(class))
+	}
+	if id := getHtmlAttributeValue(attrs, "id"); len(id) > 0 {
+		keys = append(keys, "#"+strings.ToLower(id))
+	}
+	return keys
+}
+
+func isCssBackgroundProperty(propertyKey string) bool {
+	propertyKey = strings.ToLower(propertyKey)
+	return propertyKey == "background" || propertyKey == "background-color"
+}
+
+func hasLightColor(colors []cssColorValue) bool {
+	for _, val := range colors {
+		if val.color.a == 1 && val.color.luminance() >= DARK_MODE_LIGHT_LUMINANCE {
+			return true
+		}
+	}
+	return false
+}
+
+func (prs *ParserEngine) addDarkModeBackground(keys []string, position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.darkMode.lightBackgrounds = append(prs.darkMode.lightBackgrounds, darkModeBackground{keys, position})
+}
+
+func (prs *ParserEngine) addDarkModeOverride(keys []string) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	for _, key := range keys {
+		prs.darkMode.overrides[key] = true
+	}
+}
+
+// checkDarkModeAtRule find "@media (prefers-color-scheme: dark)"
+func (prs *ParserEngine) checkDarkModeAtRule(atRule string, values []css.Token, position SourcePosition) {
+	if strings.ToLower(atRule) == "@media" && isDarkModeMedia(cssTokensToString(values)) {
+		prs.saveToReportDarkMode(DARK_MODE_PREFERS_COLOR_SCHEME, position)
+	}
+}
+
+// checkDarkModeSelector find outlook.com dark mode attributes in selector
+func (prs *ParserEngine) checkDarkModeSelector(values []css.Token, position SourcePosition) {
+	for i := 1; i < len(values); i++ {
+		if values[i-1].TokenType != css.LeftBracketToken || values[i].TokenType != css.IdentToken {
+			continue
+		}
+		switch strings.ToLower(string(values[i].Data)) {
+		case "data-ogsc", "data-ogsb":
+			prs.saveToReportDarkMode(DARK_MODE_OUTLOOK_SELECTORS, position)
+			return
+		}
+	}
+}
+
+// checkDarkModeDeclaration find color-scheme and backgrounds in style blocks
+func (prs *ParserEngine) checkDarkModeDeclaration(propertyKey string, values []css.Token, position SourcePosition) {
+	switch strings.ToLower(propertyKey) {
+	case "color-scheme", "supported-color-schemes":
+		prs.saveToReportDarkMode(DARK_MODE_COLOR_SCHEME_CSS, position)
+		return
+	}
+
+	// inline styles checked with element attributes in checkDarkModeHtml
+	if len(position.Selector) == 0 || !isCssBackgroundProperty(propertyKey) {
+		return
+	}
+
+	if isDarkModePosition(position) {
+		prs.addDarkModeOverride(cssSelectorKeys(position.Selector))
+		return
+	}
+	if hasLightColor(parseCssColors(propertyKey, values)) {
+		prs.addDarkModeBackground(cssSelectorKeys(position.Selector), position)
+	}
+}
+
+// checkDarkModeHtml find light backgrounds in attributes and inline styles of element
+func (prs *ParserEngine) checkDarkModeHtml(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	if bgcolor := getHtmlAttributeValue(attrs, "bgcolor"); len(bgcolor) > 0 {
+		if color, ok := parseHtmlColor(bgcolor); ok && color.luminance() >= DARK_MODE_LIGHT_LUMINANCE {
+			prs.addDarkModeBackground(htmlElementKeys(tagName, attrs), attributePosition(attrsPositions, "bgcolor", position))
+		}
+	}
+
+	inlineStyle := getHtmlAttributeValue(attrs, "style")
+	if !strings.Contains(strings.ToLower(inlineStyle), "background") {
+		return
+	}
+
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(inlineStyle)), true)
+	for {
+		gt, _, data := p.Next()
+		if gt == css.ErrorGrammar {
+			return
+		}
+		if gt == css.DeclarationGrammar && isCssBackgroundProperty(string(data)) && hasLightColor(parseCssColors(string(data), p.Values())) {
+			prs.addDarkModeBackground(htmlElementKeys(tagName, attrs), attributePosition(attrsPositions, "style", position))
+			return
+		}
+	}
+}
+
+// checkDarkMode build dark mode readiness report, should be called after all css and images processed
+func (prs *ParserEngine) checkDarkMode() {
+	prs.checkDarkModeImagesAndBackgrounds()
+	if prs.options.Fragment {
+		return // missing items are checked for whole document
+	}
+
+	missingPosition := prs.headAuditPosition()
+
+	colorSchemes := prs.headAudit.metas[HEAD_META_COLOR_SCHEME]
+	colorSchemeMetas := append(colorSchemes[:len(colorSchemes):len(colorSchemes)], prs.headAudit.metas[HEAD_META_SUPPORTED_COLOR_SCHEMES]...)
+	for _, entry := range colorSchemeMetas {
+		prs.saveToReportDarkMode(DARK_MODE_COLOR_SCHEME_META, entry.position)
+	}
+	if len(colorSchemeMetas) == 0 {
+		prs.saveToReportDarkMode(DARK_MODE_MISSING_COLOR_SCHEME_META, missingPosition)
+	}
+
+	if _, ok := prs.pr.DarkMode[DARK_MODE_COLOR_SCHEME_CSS]; !ok {
+		prs.saveToReportDarkMode(DARK_MODE_MISSING_COLOR_SCHEME_CSS, missingPosition)
+	}
+	if _, ok := prs.pr.DarkMode[DARK_MODE_PREFERS_COLOR_SCHEME]; !ok {
+		prs.saveToReportDarkMode(DARK_MODE_MISSING_PREFERS_COLOR_SCHEME, missingPosition)
+	}
+	if _, ok := prs.pr.DarkMode[DARK_MODE_OUTLOOK_SELECTORS]; !ok {
+		prs.saveToReportDarkMode(DARK_MODE_MISSING_OUTLOOK_SELECTORS, missingPosition)
+	}
+}
+
+// checkDarkModeImagesAndBackgrounds report elements, which can look broken in dark mode
+func (prs *ParserEngine) checkDarkModeImagesAndBackgrounds() {
+	for _, image := range prs.pr.Images {
+		if transparentImageFormats[image.Format] {
+			prs.saveToReportDarkMode(DARK_MODE_TRANSPARENT_IMAGE, SourcePosition{Line: image.Line, Snippet: image.Snippet})
+		}
+	}
+
+	for _, background := range prs.darkMode.lightBackgrounds {
+		isOverridden := false
+		for _, key := range background.keys {
+			if prs.darkMode.overrides[key] {
+				isOverridden = true
+				break
+			}
+		}
+		if !isOverridden {
+			prs.saveToReportDarkMode(DARK_MODE_LIGHT_BACKGROUND, background.position)
+		}
+	}
+}
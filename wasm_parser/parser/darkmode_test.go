@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLDarkMode(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want map[string]map[int]bool
+	}{
+		{
+			"dark mode ready",
+			`<html>
+<head>
+	<meta name="color-scheme" content="light dark">
+	<style>
+		:root { color-scheme: light dark; }
+		.card { background-color: #ffffff; }
+		@media (prefers-color-scheme: dark) {
+			.card { background-color: #111111 !important; }
+		}
+		[data-ogsb] .card { background-color: #111111 !important; }
+	</style>
+</head>
+<body>
+	<table class="card" bgcolor="#ffffff"><tr><td>Content</td></tr></table>
+	<img src="https://example.com/photo.jpg">
+</body></html>`,
+			map[string]map[int]bool{
+				DARK_MODE_COLOR_SCHEME_META:    {3: true},
+				DARK_MODE_COLOR_SCHEME_CSS:     {5: true},
+				DARK_MODE_PREFERS_COLOR_SCHEME: {7: true},
+				DARK_MODE_OUTLOOK_SELECTORS:    {10: true},
+			},
+		},
+		{
+			"without dark mode styles",
+			`<html>
+<head>
+	<style>
+		.header { background: #f4f4f4 url(bg.png) no-repeat; }
+		.footer { background-color: #333333; }
+	</style>
+</head>
+<body>
+	<div style="background-color: white">
+		<img src="https://example.com/logo.png">
+	</div>
+</body></html>`,
+			map[string]map[int]bool{
+				DARK_MODE_MISSING_COLOR_SCHEME_META:    {2: true},
+				DARK_MODE_MISSING_COLOR_SCHEME_CSS:     {2: true},
+				DARK_MODE_MISSING_PREFERS_COLOR_SCHEME: {2: true},
+				DARK_MODE_MISSING_OUTLOOK_SELECTORS:    {2: true},
+				DARK_MODE_TRANSPARENT_IMAGE:            {4: true, 10: true},
+				DARK_MODE_LIGHT_BACKGROUND:             {4: true, 9: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTML([]byte(tt.html))
+			if err != nil {
+				t.Fatalf(`ReportFromHTML("%s"), %v`, tt.html, err)
+			}
+
+			got := make(map[string]map[int]bool)
+			for item, container := range report.DarkMode {
+				got[item] = container.Lines
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DarkMode: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+package parser
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+)
+
+const (
+	LIMIT_REPORT_FONTS = 500
+)
+
+// font stack issues
+const (
+	FONT_ISSUE_MISSING_GENERIC = "missing_generic_fallback"
+	FONT_ISSUE_ONLY_WEB_FONTS  = "relies_on_web_font"
+)
+
+// font references sources
+const (
+	FONT_LINK_SOURCE_LINK   = "link"
+	FONT_LINK_SOURCE_IMPORT = "@import"
+)
+
+var (
+	genericFontFamilies = map[string]bool{
+		"serif": true, "sans-serif": true, "monospace": true, "cursive": true, "fantasy": true,
+		"system-ui": true, "ui-serif": true, "ui-sans-serif": true, "ui-monospace": true, "ui-rounded": true,
+		"emoji": true, "math": true, "fangsong": true,
+	}
+
+	globalFontKeywords = map[string]bool{
+		"inherit": true, "initial": true, "unset": true, "revert": true, "revert-layer": true,
+	}
+
+	fontHosts = map[string]bool{
+		"fonts.googleapis.com": true,
+		"fonts.bunny.net":      true,
+		"use.typekit.net":      true,
+		"p.typekit.net":        true,
+		"fast.fonts.net":       true,
+		"cloud.typography.com": true,
+		"fonts.cdnfonts.com":   true,
+		"api.fontshare.com":    true,
+		"use.fontawesome.com":  true,
+	}
+
+	fontFormatsByExtension = map[string]string{
+		"woff2": "woff2",
+		"woff":  "woff",
+		"ttf":   "truetype",
+		"otf":   "opentype",
+		"eot":   "embedded-opentype",
+		"svg":   "svg",
+	}
+)
+
+type FontStack struct {
+	Stack    string   `json:"stack"`
+	Families []string `json:"families"`
+	Generic  string   `json:"generic"`
+	WebFonts []string `json:"web_fonts"`
+	Issues   []string `json:"issues"`
+	Count    int      `json:"count"`
+	Lines    []int    `json:"lines"`
+	Line     int      `json:"line"`
+	File     string   `json:"file,omitempty"`
+	Snippet  string   `json:"snippet"`
+	Context  string   `json:"context"`
+	generics []bool   // families, which are generic keywords
+}
+
+type FontFaceSource struct {
+	Url     string `json:"url"`
+	Format  string `json:"format"`
+	IsLocal bool   `json:"is_local"`
+}
+
+type FontFace struct {
+	Family  string           `json:"family"`
+	Weight  string           `json:"weight"`
+	Style   string           `json:"style"`
+	Sources []FontFaceSource `json:"sources"`
+	Formats []string         `json:"formats"`
+	Line    int              `json:"line"`
+	File    string           `json:"file,omitempty"`
+	Snippet string           `json:"snippet"`
+	Context string           `json:"context"`
+}
+
+type FontLink struct {
+	Url      string   `json:"url"`
+	Host     string   `json:"host"`
+	Source   string   `json:"source"`
+	Families []string `json:"families"`
+	Line     int      `json:"line"`
+	File     string   `json:"file,omitempty"`
+	Snippet  string   `json:"snippet"`
+	Context  string   `json:"context"`
+}
+
+type FontsReport struct {
+	Stacks    []FontStack `json:"stacks"`
+	FontFaces []FontFace  `json:"font_faces"`
+	Links     []FontLink  `json:"links"`
+	// clients, which do not load web fonts
+	WebFontsUnsupportedClients []string `json:"web_fonts_unsupported_clients"`
+	More                       bool     `json:"more"`
+}
+
+// cssFontFamily is family name from font-family value, quoted names are never generic families
+type cssFontFamily struct {
+	name   string
+	quoted bool
+}
+
+func (family cssFontFamily) isGeneric() bool {
+	return !family.quoted && genericFontFamilies[strings.ToLower(family.name)]
+}
+
+func fontFamilyNames(families []cssFontFamily) []string {
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.name)
+	}
+	return names
+}
+
+// fontFaceCollector gather descriptors of @font-face in style tag
+type fontFaceCollector struct {
+	fontFace *FontFace
+}
+
+func unquoteCssString(value string) string {
+	value = strings.Trim(value, WHITESPACE)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseFontFamilies split font-family value to families
+func parseFontFamilies(values []css.Token) []cssFontFamily {
+	var (
+		families []cssFontFamily
+		parts    []string
+		quoted   bool
+	)
+
+	flush := func() {
+		if len(parts) > 0 {
+			families = append(families, cssFontFamily{name: strings.Join(parts, " "), quoted: quoted})
+			parts = nil
+			quoted = false
+		}
+	}
+
+	for _, val := range values {
+		switch val.TokenType {
+		case css.CommaToken:
+			flush()
+		case css.StringToken:
+			parts = append(parts, unquoteCssString(string(val.Data)))
+			quoted = true
+		case css.IdentToken:
+			parts = append(parts, string(val.Data))
+		case css.DelimToken:
+			if string(val.Data) == "!" {
+				flush()
+				return families // !important
+			}
+		case css.FunctionToken:
+			return nil // var() and other dynamic values
+		}
+	}
+	flush()
+
+	return families
+}
+
+// fontShorthandFamilies return families part of "font" shorthand (after size and line height)
+func fontShorthandFamilies(values []css.Token) []cssFontFamily {
+	lastSize := -1
+	for i, val := range values {
+		switch val.TokenType {
+		case css.DimensionToken, css.PercentageToken, css.NumberToken:
+			lastSize = i
+		case css.CommaToken, css.StringToken:
+			if lastSize >= 0 {
+				return parseFontFamilies(values[lastSize+1:])
+			}
+		}
+	}
+	if lastSize < 0 {
+		return nil // system font keywords, like "caption"
+	}
+	return parseFontFamilies(values[lastSize+1:])
+}
+
+func (prs *ParserEngine) saveFontStack(fontFamilies []cssFontFamily, position SourcePosition) {
+	if len(fontFamilies) == 0 || (len(fontFamilies) == 1 && !fontFamilies[0].quoted && globalFontKeywords[strings.ToLower(fontFamilies[0].name)]) {
+		return
+	}
+
+	var (
+		families = fontFamilyNames(fontFamilies)
+		generics []bool
+		keys     []string
+	)
+	for _, family := range fontFamilies {
+		generics = append(generics, family.isGeneric())
+		if family.quoted {
+			keys = append(keys, strconv.Quote(family.name))
+		} else {
+			keys = append(keys, family.name)
+		}
+	}
+
+	stack := strings.Join(families, ", ")
+	stackKey := strings.ToLower(strings.Join(keys, ", "))
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if index, ok := prs.fontStacksIndex[stackKey]; ok {
+		fontStack := &prs.pr.Fonts.Stacks[index]
+		fontStack.Count += 1
+		// style blocks processed in parallel, so lines come unordered
+		if i, found := slices.BinarySearch(fontStack.Lines, position.Line); !found && len(fontStack.Lines) < LIMIT_REPORT_LINES {
+			fontStack.Lines = slices.Insert(fontStack.Lines, i, position.Line)
+		}
+		if position.Line < fontStack.Line {
+			fontStack.Line, fontStack.Snippet = position.Line, position.Snippet
+		}
+		return
+	}
+
+	if len(prs.pr.Fonts.Stacks) >= LIMIT_REPORT_FONTS {
+		prs.pr.Fonts.More = true
+		return
+	}
+
+	prs.fontStacksIndex[stackKey] = len(prs.pr.Fonts.Stacks)
+	prs.pr.Fonts.Stacks = append(prs.pr.Fonts.Stacks, FontStack{
+		Stack:    stack,
+		Families: families,
+		generics: generics,
+		Count:    1,
+		Lines:    []int{position.Line},
+		Line:     position.Line,
+		Snippet:  position.Snippet,
+	})
+}
+
+// checkFontDeclaration collect font stacks from font-family and font properties
+func (prs *ParserEngine) checkFontDeclaration(propertyKey string, values []css.Token, position SourcePosition) {
+	if len(position.AtRules) > 0 && strings.HasPrefix(strings.ToLower(position.AtRules[len(position.AtRules)-1]), "@font-face") {
+		return // descriptor of @font-face
+	}
+
+	switch strings.ToLower(propertyKey) {
+	case "font-family":
+		prs.saveFontStack(parseFontFamilies(values), position)
+	case "font":
+		prs.saveFontStack(fontShorthandFamilies(values), position)
+	}
+}
+
+func parseFontFaceSources(values []css.Token) []FontFaceSource {
+	var sources []FontFaceSource
+
+	for i := 0; i < len(values); i++ {
+		val := values[i]
+		switch {
+		case val.TokenType == css.URLToken:
+			fontUrl := string(val.Data)
+			if cssUrlRe.MatchString(fontUrl) {
+				fontUrl = cssUrlRe.FindStringSubmatch(fontUrl)[1]
+			}
+			sources = append(sources, FontFaceSource{Url: unquoteCssString(fontUrl)})
+		case val.TokenType == css.FunctionToken && strings.ToLower(string(val.Data)) == "url(" && i+1 < len(values):
+			sources = append(sources, FontFaceSource{Url: unquoteCssString(string(values[i+1].Data))})
+		case val.TokenType == css.FunctionToken && strings.ToLower(string(val.Data)) == "local(" && i+1 < len(values):
+			sources = append(sources, FontFaceSource{Url: unquoteCssString(string(values[i+1].Data)), IsLocal: true})
+		case val.TokenType == css.FunctionToken && strings.ToLower(string(val.Data)) == "format(" && len(sources) > 0 && i+1 < len(values):
+			sources[len(sources)-1].Format = strings.ToLower(unquoteCssString(string(values[i+1].Data)))
+		}
+	}
+
+	for i := range sources {
+		if len(sources[i].Format) > 0 || sources[i].IsLocal {
+			continue
+		}
+		if urlData, err := url.Parse(sources[i].Url); err == nil {
+			sources[i].Format = fontFormatsByExtension[strings.TrimPrefix(strings.ToLower(path.Ext(urlData.Path)), ".")]
+		}
+	}
+
+	return sources
+}
+
+// track collect @font-face declarations, should be called for each css grammar item in style tag
+func (collector *fontFaceCollector) track(prs *ParserEngine, gt css.GrammarType, data []byte, values []css.Token, position SourcePosition) {
+	switch gt {
+	case css.BeginAtRuleGrammar:
+		if strings.ToLower(string(data)) == "@font-face" {
+			collector.fontFace = &FontFace{Line: position.Line, Snippet: position.Snippet}
+		}
+	case css.DeclarationGrammar:
+		if collector.fontFace == nil {
+			return
+		}
+		switch strings.ToLower(string(data)) {
+		case "font-family":
+			collector.fontFace.Family = strings.Join(fontFamilyNames(parseFontFamilies(values)), ", ")
+		case "font-weight":
+			collector.fontFace.Weight = cssTokensToValue(values)
+		case "font-style":
+			collector.fontFace.Style = cssTokensToValue(values)
+		case "src":
+			collector.fontFace.Sources = append(collector.fontFace.Sources, parseFontFaceSources(values)...)
+		}
+	case css.EndAtRuleGrammar, css.BeginRulesetGrammar:
+		collector.flush(prs)
+	}
+}
+
+func (collector *fontFaceCollector) flush(prs *ParserEngine) {
+	if collector.fontFace != nil {
+		prs.saveFontFace(*collector.fontFace)
+		collector.fontFace = nil
+	}
+}
+
+func (prs *ParserEngine) saveFontFace(fontFace FontFace) {
+	formats := make(map[string]bool)
+	for _, source := range fontFace.Sources {
+		if len(source.Format) > 0 && !formats[source.Format] {
+			formats[source.Format] = true
+			fontFace.Formats = append(fontFace.Formats, source.Format)
+		}
+	}
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if len(prs.pr.Fonts.FontFaces) >= LIMIT_REPORT_FONTS {
+		prs.pr.Fonts.More = true
+		return
+	}
+	prs.pr.Fonts.FontFaces = append(prs.pr.Fonts.FontFaces, fontFace)
+}
+
+// fontFamiliesFromUrl return families requested from font service (google fonts and compatible)
+func fontFamiliesFromUrl(urlData *url.URL) []string {
+	var families []string
+	// url.Query drop pairs with semicolon, which google fonts use in axes ("Roboto:wght@400;700")
+	for _, pair := range strings.Split(urlData.RawQuery, "&") {
+		key, familyParam, _ := strings.Cut(pair, "=")
+		if key != "family" {
+			continue
+		}
+		familyParam, err := url.QueryUnescape(familyParam)
+		if err != nil {
+			continue
+		}
+		for _, family := range strings.Split(familyParam, "|") {
+			family, _, _ = strings.Cut(family, ":")
+			if family = strings.Trim(family, WHITESPACE); len(family) > 0 {
+				families = append(families, family)
+			}
+		}
+	}
+	return families
+}
+
+func isFontUrl(urlData *url.URL) bool {
+	host := strings.ToLower(urlData.Hostname())
+	if fontHosts[host] {
+		return true
+	}
+	_, isFontFile := fontFormatsByExtension[strings.TrimPrefix(strings.ToLower(path.Ext(urlData.Path)), ".")]
+	return (isFontFile && path.Ext(urlData.Path) != ".svg") || strings.Contains(host, "font")
+}
+
+func (prs *ParserEngine) saveFontLink(fontUrl, source string, position SourcePosition) {
+	urlData, err := url.Parse(strings.Trim(fontUrl, WHITESPACE))
+	if err != nil || !isFontUrl(urlData) {
+		return
+	}
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if len(prs.pr.Fonts.Links) >= LIMIT_REPORT_FONTS {
+		prs.pr.Fonts.More = true
+		return
+	}
+	prs.pr.Fonts.Links = append(prs.pr.Fonts.Links, FontLink{
+		Url:      limitSnippet(fontUrl),
+		Host:     strings.ToLower(urlData.Hostname()),
+		Source:   source,
+		Families: fontFamiliesFromUrl(urlData),
+		Line:     position.Line,
+		Snippet:  position.Snippet,
+	})
+}
+
+// checkFontLink find <link> elements with font css
+func (prs *ParserEngine) checkFontLink(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	if tagName != "link" {
+		return
+	}
+	href := getHtmlAttributeValue(attrs, "href")
+	if len(href) == 0 {
+		return
+	}
+	prs.saveFontLink(href, FONT_LINK_SOURCE_LINK, attributePosition(attrsPositions, "href", position))
+}
+
+// checkFontImport find @import of font css
+func (prs *ParserEngine) checkFontImport(values []css.Token, position SourcePosition) {
+	for i, val := range values {
+		switch val.TokenType {
+		case css.URLToken:
+			fontUrl := string(val.Data)
+			if cssUrlRe.MatchString(fontUrl) {
+				fontUrl = cssUrlRe.FindStringSubmatch(fontUrl)[1]
+			}
+			prs.saveFontLink(unquoteCssString(fontUrl), FONT_LINK_SOURCE_IMPORT, position)
+			return
+		case css.StringToken:
+			prs.saveFontLink(unquoteCssString(string(val.Data)), FONT_LINK_SOURCE_IMPORT, position)
+			return
+		case css.FunctionToken:
+			if strings.ToLower(string(val.Data)) == "url(" && i+1 < len(values) {
+				prs.saveFontLink(unquoteCssString(string(values[i+1].Data)), FONT_LINK_SOURCE_IMPORT, position)
+				return
+			}
+		}
+	}
+}
+
+// checkFonts detect web fonts in stacks, should be called after all css processed
+func (prs *ParserEngine) checkFonts() {
+	fonts := &prs.pr.Fonts
+
+	webFonts := make(map[string]bool)
+	for _, fontFace := range fonts.FontFaces {
+		for _, family := range strings.Split(fontFace.Family, ",") {
+			webFonts[strings.ToLower(strings.Trim(family, WHITESPACE))] = true
+		}
+	}
+	for _, link := range fonts.Links {
+		for _, family := range link.Families {
+			webFonts[strings.ToLower(family)] = true
+		}
+	}
+
+	// style tags processed in parallel
+	sort.SliceStable(fonts.Stacks, func(i, j int) bool {
+		return fonts.Stacks[i].Line < fonts.Stacks[j].Line
+	})
+	sort.SliceStable(fonts.FontFaces, func(i, j int) bool {
+		return fonts.FontFaces[i].Line < fonts.FontFaces[j].Line
+	})
+	sort.SliceStable(fonts.Links, func(i, j int) bool {
+		return fonts.Links[i].Line < fonts.Links[j].Line
+	})
+
+	hasWebFonts := false
+	for i := range fonts.Stacks {
+		fontStack := &fonts.Stacks[i]
+		sort.Ints(fontStack.Lines)
+
+		namedFamilies := 0
+		for j, family := range fontStack.Families {
+			familyKey := strings.ToLower(family)
+			switch {
+			case fontStack.generics[j]:
+				if len(fontStack.Generic) == 0 {
+					fontStack.Generic = family
+				}
+			case webFonts[familyKey]:
+				fontStack.WebFonts = append(fontStack.WebFonts, family)
+				namedFamilies += 1
+			default:
+				namedFamilies += 1
+			}
+		}
+
+		if len(fontStack.Generic) == 0 {
+			fontStack.Issues = append(fontStack.Issues, FONT_ISSUE_MISSING_GENERIC)
+		}
+		if len(fontStack.WebFonts) > 0 {
+			hasWebFonts = true
+			if len(fontStack.WebFonts) == namedFamilies {
+				fontStack.Issues = append(fontStack.Issues, FONT_ISSUE_ONLY_WEB_FONTS)
+			}
+		}
+	}
+
+	if hasWebFonts || len(fonts.FontFaces) > 0 || len(fonts.Links) > 0 {
+		if ruleData, ok := rulesDB.AtRuleCssStatements["@font-face"][""]; ok {
+			fonts.WebFontsUnsupportedClients = clientsWithSupport(ruleData, CLIENT_SUPPORT_NO)
+		}
+	}
+}
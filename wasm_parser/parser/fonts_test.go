@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLFonts(t *testing.T) {
+	html := `<html>
+<head>
+	<link href="https://fonts.googleapis.com/css2?family=Roboto:wght@400;700&display=swap" rel="stylesheet">
+	<style>
+		@font-face {
+			font-family: 'Brand';
+			font-weight: 700;
+			src: url('https://example.com/brand.woff2') format('woff2'), url(https://example.com/brand.woff);
+		}
+		.title { font-family: Roboto, Arial, sans-serif; }
+		.brand { font-family: "Brand"; }
+	</style>
+</head>
+<body>
+	<p style="font: bold 14px/20px Georgia, serif">Content</p>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	gotLinks := make([][3]interface{}, 0, len(report.Fonts.Links))
+	for _, link := range report.Fonts.Links {
+		gotLinks = append(gotLinks, [3]interface{}{link.Line, link.Host, link.Families})
+	}
+	wantLinks := [][3]interface{}{
+		{3, "fonts.googleapis.com", []string{"Roboto"}},
+	}
+	if !reflect.DeepEqual(gotLinks, wantLinks) {
+		t.Errorf("Fonts Links: got %v, want %v", gotLinks, wantLinks)
+	}
+
+	if len(report.Fonts.FontFaces) != 1 {
+		t.Fatalf("Fonts FontFaces: got %d, want 1", len(report.Fonts.FontFaces))
+	}
+	fontFace := report.Fonts.FontFaces[0]
+	wantSources := []FontFaceSource{
+		{Url: "https://example.com/brand.woff2", Format: "woff2"},
+		{Url: "https://example.com/brand.woff", Format: "woff"},
+	}
+	if fontFace.Family != "Brand" || fontFace.Weight != "700" || !reflect.DeepEqual(fontFace.Sources, wantSources) {
+		t.Errorf("Fonts FontFaces: got %v", fontFace)
+	}
+
+	type stackSummary struct {
+		Line     int
+		Stack    string
+		Generic  string
+		WebFonts []string
+		Issues   []string
+	}
+
+	gotStacks := make([]stackSummary, 0, len(report.Fonts.Stacks))
+	for _, stack := range report.Fonts.Stacks {
+		gotStacks = append(gotStacks, stackSummary{stack.Line, stack.Stack, stack.Generic, stack.WebFonts, stack.Issues})
+	}
+	wantStacks := []stackSummary{
+		{10, "Roboto, Arial, sans-serif", "sans-serif", []string{"Roboto"}, nil},
+		{11, "Brand", "", []string{"Brand"}, []string{FONT_ISSUE_MISSING_GENERIC, FONT_ISSUE_ONLY_WEB_FONTS}},
+		{15, "georgia, serif", "serif", nil, nil}, // inline styles are lowercased
+	}
+	if !reflect.DeepEqual(gotStacks, wantStacks) {
+		t.Errorf("Fonts Stacks: got %v, want %v", gotStacks, wantStacks)
+	}
+}
+
+func TestReportFromHTMLQuotedGenericFontFamily(t *testing.T) {
+	html := `<style>
+	.quoted { font-family: Arial, "sans-serif"; }
+	.ident { font-family: Arial, sans-serif; }
+</style>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	got := make([][3]interface{}, 0, len(report.Fonts.Stacks))
+	for _, stack := range report.Fonts.Stacks {
+		got = append(got, [3]interface{}{stack.Line, stack.Generic, stack.Issues})
+	}
+	want := [][3]interface{}{
+		{2, "", []string{FONT_ISSUE_MISSING_GENERIC}},
+		{3, "sans-serif", []string(nil)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fonts Stacks: got %v, want %v", got, want)
+	}
+}
+
+func TestReportFromHTMLFontStackLines(t *testing.T) {
+	html := `<style>.a { font-family: Arial, sans-serif; }</style><style>.b { font-family: Arial, sans-serif; }</style>
+<p style="font-family: Arial, sans-serif">Content</p>
+<style>.c { font-family: Arial, sans-serif; }</style>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	if len(report.Fonts.Stacks) != 1 {
+		t.Fatalf("Fonts Stacks: got %v, want one stack", report.Fonts.Stacks)
+	}
+	stack := report.Fonts.Stacks[0]
+	if stack.Count != 4 || !reflect.DeepEqual(stack.Lines, []int{1, 2, 3}) {
+		t.Errorf("Fonts Stacks: got count %d and lines %v, want 4 and [1 2 3]", stack.Count, stack.Lines)
+	}
+}
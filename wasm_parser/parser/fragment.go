@@ -0,0 +1,183 @@
+package parser
+
+// originLine map line of parsed html to line in original file or included partial
+func (prs *ParserEngine) originLine(line int) int {
+	if line > 0 && line <= len(prs.includeLines) {
+		origin := prs.includeLines[line-1]
+		if len(origin.file) > 0 {
+			return origin.line
+		}
+		line = origin.line
+	}
+	if prs.options.BaseLine > 1 && line > 0 {
+		return line + prs.options.BaseLine - 1
+	}
+	return line
+}
+
+// originColumn map column of parsed html to column in original file, only first line is shifted
+func (prs *ParserEngine) originColumn(line, column int) int {
+	if line > 0 && line <= len(prs.includeLines) {
+		if len(prs.includeLines[line-1].file) > 0 {
+			return column
+		}
+		line = prs.includeLines[line-1].line
+	}
+	if prs.options.BaseColumn > 1 && line == 1 {
+		return column + prs.options.BaseColumn - 1
+	}
+	return column
+}
+
+func (prs *ParserEngine) shiftContainerLines(container ReportContainer) {
+	lines := make([]int, 0, len(container.Lines))
+	for line := range container.Lines {
+		lines = append(lines, line)
+	}
+	clear(container.Lines)
+	for _, line := range lines {
+		container.Lines[prs.originLine(line)] = true
+	}
+
+	for i := range container.Occurrences {
+		container.Occurrences[i].File = prs.originFile(container.Occurrences[i].Line)
+		container.Occurrences[i].Line = prs.originLine(container.Occurrences[i].Line)
+	}
+}
+
+func (prs *ParserEngine) shiftLinesList(lines []int) {
+	for i := range lines {
+		lines[i] = prs.originLine(lines[i])
+	}
+}
+
+// applyOriginOffsets move all report lines to position of fragment in original file,
+// should be called after contexts filled (they use lines of parsed html)
+func (prs *ParserEngine) applyOriginOffsets() {
+	prs.pr.FileName = prs.options.FileName
+	prs.pr.Fragment = prs.options.Fragment
+
+	if prs.options.BaseLine <= 1 && prs.options.BaseColumn <= 1 && len(prs.includeLines) == 0 {
+		return
+	}
+	prs.pr.SourceMap = buildSourceMap(prs.includeLines)
+	for i := range prs.pr.SourceMap {
+		segment := &prs.pr.SourceMap[i]
+		segment.File = prs.originFile(segment.FlatLine)
+		segment.Line = prs.originLine(segment.FlatLine)
+	}
+
+	for _, nestedData := range []map[string]map[string]ReportContainer{
+		prs.pr.HtmlTags,
+		prs.pr.HtmlAttributes,
+		prs.pr.CssProperties,
+		prs.pr.AtRuleCssStatements,
+		prs.pr.MsoMarkup,
+		prs.pr.UnknownNames,
+	} {
+		for _, items := range nestedData {
+			for _, item := range items {
+				prs.shiftContainerLines(item)
+			}
+		}
+	}
+
+	for _, items := range []map[string]ReportContainer{
+		prs.pr.CssSelectorTypes,
+		prs.pr.CssDimentions,
+		prs.pr.CssFunctions,
+		prs.pr.CssPseudoSelectors,
+		prs.pr.ImgFormats,
+		prs.pr.LinkTypes,
+		prs.pr.HeadAudit,
+		prs.pr.DarkMode,
+		prs.pr.Accessibility,
+		prs.pr.Responsive,
+		prs.pr.TableIssues,
+		prs.pr.GmailIssues,
+	} {
+		for _, item := range items {
+			prs.shiftContainerLines(item)
+		}
+	}
+
+	for _, item := range []ReportContainer{
+		prs.pr.CssVariables,
+		prs.pr.CssImportant,
+		prs.pr.Html5Doctype,
+	} {
+		prs.shiftContainerLines(item)
+	}
+
+	for i := range prs.pr.HtmlDiagnostics {
+		diagnostic := &prs.pr.HtmlDiagnostics[i]
+		diagnostic.File = prs.originFile(diagnostic.Line)
+		diagnostic.Column = prs.originColumn(diagnostic.Line, diagnostic.Column)
+		diagnostic.Line = prs.originLine(diagnostic.Line)
+	}
+
+	for i := range prs.pr.StyleBlocks {
+		prs.pr.StyleBlocks[i].File = prs.originFile(prs.pr.StyleBlocks[i].Line)
+		prs.pr.StyleBlocks[i].Line = prs.originLine(prs.pr.StyleBlocks[i].Line)
+	}
+
+	for i := range prs.pr.Images {
+		prs.pr.Images[i].File = prs.originFile(prs.pr.Images[i].Line)
+		prs.pr.Images[i].Line = prs.originLine(prs.pr.Images[i].Line)
+	}
+
+	for i := range prs.pr.CssVariablesGraph.Definitions {
+		prs.pr.CssVariablesGraph.Definitions[i].File = prs.originFile(prs.pr.CssVariablesGraph.Definitions[i].Line)
+		prs.pr.CssVariablesGraph.Definitions[i].Line = prs.originLine(prs.pr.CssVariablesGraph.Definitions[i].Line)
+	}
+
+	for i := range prs.pr.CssVariablesGraph.Usages {
+		prs.pr.CssVariablesGraph.Usages[i].File = prs.originFile(prs.pr.CssVariablesGraph.Usages[i].Line)
+		prs.pr.CssVariablesGraph.Usages[i].Line = prs.originLine(prs.pr.CssVariablesGraph.Usages[i].Line)
+	}
+
+	for i := range prs.pr.Fonts.Stacks {
+		prs.pr.Fonts.Stacks[i].File = prs.originFile(prs.pr.Fonts.Stacks[i].Line)
+		prs.pr.Fonts.Stacks[i].Line = prs.originLine(prs.pr.Fonts.Stacks[i].Line)
+		prs.shiftLinesList(prs.pr.Fonts.Stacks[i].Lines)
+	}
+
+	for i := range prs.pr.Fonts.FontFaces {
+		prs.pr.Fonts.FontFaces[i].File = prs.originFile(prs.pr.Fonts.FontFaces[i].Line)
+		prs.pr.Fonts.FontFaces[i].Line = prs.originLine(prs.pr.Fonts.FontFaces[i].Line)
+	}
+
+	for i := range prs.pr.Fonts.Links {
+		prs.pr.Fonts.Links[i].File = prs.originFile(prs.pr.Fonts.Links[i].Line)
+		prs.pr.Fonts.Links[i].Line = prs.originLine(prs.pr.Fonts.Links[i].Line)
+	}
+
+	for i := range prs.pr.ColorPalette.Colors {
+		prs.pr.ColorPalette.Colors[i].File = prs.originFile(prs.pr.ColorPalette.Colors[i].Line)
+		prs.pr.ColorPalette.Colors[i].Line = prs.originLine(prs.pr.ColorPalette.Colors[i].Line)
+		prs.shiftLinesList(prs.pr.ColorPalette.Colors[i].Lines)
+	}
+
+	for i := range prs.pr.TableLayout.Tables {
+		table := &prs.pr.TableLayout.Tables[i]
+		table.File = prs.originFile(table.Line)
+		table.Line = prs.originLine(table.Line)
+		for j := range table.Rows {
+			table.Rows[j].Line = prs.originLine(table.Rows[j].Line)
+		}
+	}
+
+	if prs.pr.Gmail.ClipLine > 0 {
+		prs.pr.Gmail.ClipFile = prs.originFile(prs.pr.Gmail.ClipLine)
+		prs.pr.Gmail.ClipLine = prs.originLine(prs.pr.Gmail.ClipLine)
+	}
+	for i := range prs.pr.Gmail.StyleBlocks {
+		prs.pr.Gmail.StyleBlocks[i].File = prs.originFile(prs.pr.Gmail.StyleBlocks[i].Line)
+		prs.pr.Gmail.StyleBlocks[i].Line = prs.originLine(prs.pr.Gmail.StyleBlocks[i].Line)
+	}
+
+	for i := range prs.pr.Contrast.Issues {
+		prs.pr.Contrast.Issues[i].File = prs.originFile(prs.pr.Contrast.Issues[i].Line)
+		prs.pr.Contrast.Issues[i].Line = prs.originLine(prs.pr.Contrast.Issues[i].Line)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestReportFromHTMLFragment(t *testing.T) {
+	// footer partial, which closes table from header partial
+	html := `<tr>
+	<td style="display: flex">
+		<a href="mailto:info@example.com">Contact</a>
+	</td>
+</tr>
+</table>`
+
+	report, err := ReportFromHTMLWithOptions([]byte(html), ParserOptions{
+		Fragment:   true,
+		FileName:   "partials/footer.yml",
+		BaseLine:   5,
+		BaseColumn: 3,
+	})
+	if err != nil {
+		t.Fatalf(`ReportFromHTMLWithOptions("%s"), %v`, html, err)
+	}
+
+	if report.FileName != "partials/footer.yml" || !report.Fragment {
+		t.Errorf("Fragment: got file name %q and fragment %v", report.FileName, report.Fragment)
+	}
+
+	if len(report.HeadAudit) > 0 {
+		t.Errorf("Fragment HeadAudit: document level checks should be skipped, got %v", report.HeadAudit)
+	}
+	if _, ok := report.DarkMode[DARK_MODE_MISSING_COLOR_SCHEME_META]; ok {
+		t.Errorf("Fragment DarkMode: document level checks should be skipped, got %v", report.DarkMode)
+	}
+	for _, diagnostic := range report.HtmlDiagnostics {
+		if diagnostic.Type == HTML_DIAGNOSTIC_STRAY_END_TAG || diagnostic.Type == HTML_DIAGNOSTIC_UNCLOSED_TAG {
+			t.Errorf("Fragment HtmlDiagnostics: partial structure should not be reported, got %v", diagnostic)
+		}
+	}
+
+	flexLines := report.CssProperties["display"]["flex"].Lines
+	if !flexLines[6] || len(flexLines) != 1 {
+		t.Errorf("Fragment CssProperties display flex lines: got %v, want line 6", flexLines)
+	}
+	occurrence := report.CssProperties["display"]["flex"].Occurrences[0]
+	if occurrence.Line != 6 || occurrence.Context == "" {
+		t.Errorf("Fragment CssProperties display flex occurrence: got %v", occurrence)
+	}
+
+	mailtoLines := report.LinkTypes["mailto"].Lines
+	if !mailtoLines[7] || len(mailtoLines) != 1 {
+		t.Errorf("Fragment LinkTypes mailto lines: got %v, want line 7", mailtoLines)
+	}
+}
+
+func TestReportFromHTMLFragmentColumns(t *testing.T) {
+	html := `<table><tr><p>Text</p></tr></table>
+<table><tr><p>Text</p></tr></table>`
+
+	report, err := ReportFromHTMLWithOptions([]byte(html), ParserOptions{Fragment: true, BaseLine: 10, BaseColumn: 5})
+	if err != nil {
+		t.Fatalf(`ReportFromHTMLWithOptions("%s"), %v`, html, err)
+	}
+
+	var got [][2]int
+	for _, diagnostic := range report.HtmlDiagnostics {
+		if diagnostic.Type == HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT {
+			got = append(got, [2]int{diagnostic.Line, diagnostic.Column})
+		}
+	}
+
+	want := [][2]int{{10, 16}, {11, 12}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Fragment HtmlDiagnostics positions: got %v, want %v", got, want)
+	}
+}
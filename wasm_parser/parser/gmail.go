@@ -0,0 +1,221 @@
+package parser
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	parse "github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+const (
+	// Gmail shows "[Message clipped]" for html larger than 102KB
+	GMAIL_CLIP_THRESHOLD = 102 * 1024
+	// Gmail removes <style> block larger than 16KB
+	GMAIL_STYLE_BLOCK_LIMIT = 16 * 1024
+	// client key of Gmail webmail in caniemail stats
+	GMAIL_WEBMAIL_CLIENT = "gmail desktop-webmail"
+)
+
+// gmail issues
+const (
+	GMAIL_CLIPPED               = "clipped"
+	GMAIL_STYLE_BLOCK_TOO_LARGE = "style_block_too_large"
+	GMAIL_STYLE_SYNTAX_ERROR    = "style_syntax_error"
+	GMAIL_NESTED_AT_RULE        = "nested_at_rule"
+	GMAIL_UNSUPPORTED_SELECTOR  = "unsupported_selector"
+)
+
+var gmailRulesDB = map[string]map[string]interface{}{
+	GMAIL_CLIPPED:               makeIssueRule("Message clipped", "Gmail clips html larger than 102KB and hides the rest behind \"View entire message\" link, tracking pixel and footer below the clipping point are not loaded.", SEVERITY_ERROR),
+	GMAIL_STYLE_BLOCK_TOO_LARGE: makeIssueRule("Style block too large", "Gmail removes whole <style> block larger than 16KB. Split styles into several blocks or remove unused rules.", SEVERITY_ERROR),
+	GMAIL_STYLE_SYNTAX_ERROR:    makeIssueRule("Syntax error in style block", "Gmail removes whole <style> block with unbalanced braces.", SEVERITY_ERROR),
+	GMAIL_NESTED_AT_RULE:        makeIssueRule("Nested at-rule", "Gmail removes whole <style> block with at-rule nested in another at-rule (like @media inside @supports).", SEVERITY_ERROR),
+	GMAIL_UNSUPPORTED_SELECTOR:  makeIssueRule("Selector unsupported in Gmail", "Gmail removes rules with selectors it does not support, other rules of block are kept.", SEVERITY_WARNING),
+}
+
+// GmailStyleBlock is size of <style> block and reasons, why Gmail removes it
+type GmailStyleBlock struct {
+	Index   int      `json:"index"`
+	Line    int      `json:"line"`
+	File    string   `json:"file,omitempty"`
+	Bytes   int      `json:"bytes"`
+	Dropped bool     `json:"dropped"`
+	Reasons []string `json:"reasons"` // gmail issues, which remove block
+}
+
+type GmailReport struct {
+	Bytes           int               `json:"bytes"`
+	InlinedBytes    int               `json:"inlined_bytes"` // 0 if inlined html was not provided
+	ClipThreshold   int               `json:"clip_threshold"`
+	Clipped         bool              `json:"clipped"`
+	ClipLine        int               `json:"clip_line"` // first clipped line
+	ClipFile        string            `json:"clip_file,omitempty"`
+	InlinedClipped  bool              `json:"inlined_clipped"`
+	InlinedClipLine int               `json:"inlined_clip_line"` // first clipped line of inlined html
+	StyleBlocks     []GmailStyleBlock `json:"style_blocks"`
+}
+
+func (prs *ParserEngine) saveToReportGmail(issue string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.GmailIssues, gmailRulesDB, issue, position)
+}
+
+// isGmailUnsupported check caniemail stats of Gmail webmail
+func isGmailUnsupported(rule interface{}) bool {
+	return clientsSupport(rule)[GMAIL_WEBMAIL_CLIENT] == CLIENT_SUPPORT_NO
+}
+
+// checkGmailSelector find pseudo selectors, which Gmail strips with their rules
+func (prs *ParserEngine) checkGmailSelector(values []css.Token, position SourcePosition) {
+	for i := 1; i < len(values); i++ {
+		if values[i-1].TokenType != css.ColonToken || (values[i].TokenType != css.IdentToken && values[i].TokenType != css.FunctionToken) {
+			continue
+		}
+		pseudoName := strings.ToLower(strings.TrimSuffix(string(values[i].Data), "("))
+		if rule, ok := rulesDB.CssPseudoSelectors[pseudoName]; ok && isGmailUnsupported(rule) {
+			prs.saveToReportGmail(GMAIL_UNSUPPORTED_SELECTOR, position)
+			return
+		}
+	}
+}
+
+// findCssBraceError return offset of unbalanced brace in css, -1 if braces balanced
+func findCssBraceError(content string) int {
+	var (
+		opened []int
+		quote  byte
+	)
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i += 1
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return i // unclosed comment
+			}
+			i += end + 3
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{':
+			opened = append(opened, i)
+		case ch == '}':
+			if len(opened) == 0 {
+				return i
+			}
+			opened = opened[:len(opened)-1]
+		}
+	}
+
+	if len(opened) > 0 {
+		return opened[len(opened)-1]
+	}
+	return -1
+}
+
+// checkGmailStyleBlock find size, syntax errors and nested at-rules, which make Gmail remove <style> block
+func (prs *ParserEngine) checkGmailStyleBlock(content string, position SourcePosition, blockIndex int) {
+	block := GmailStyleBlock{
+		Index:   blockIndex,
+		Line:    position.Line,
+		Bytes:   len(content),
+		Reasons: []string{},
+	}
+	lineAt := func(offset int) SourcePosition {
+		line := position.Line + strings.Count(content[:min(offset, len(content))], "\n")
+		return SourcePosition{Line: line}.
+			withSnippet(limitSnippet(collapseWhitespace(content[max(0, offset-40):min(len(content), offset+40)]))).
+			withTemplateConditions(prs.templateConditionsAtLine(line))
+	}
+	addReason := func(issue string, issuePosition SourcePosition) {
+		prs.saveToReportGmail(issue, issuePosition)
+		block.Dropped = true
+		for _, reason := range block.Reasons {
+			if reason == issue {
+				return
+			}
+		}
+		block.Reasons = append(block.Reasons, issue)
+	}
+
+	if len(content) > GMAIL_STYLE_BLOCK_LIMIT {
+		addReason(GMAIL_STYLE_BLOCK_TOO_LARGE, position)
+	}
+	if offset := findCssBraceError(content); offset >= 0 {
+		addReason(GMAIL_STYLE_SYNTAX_ERROR, lineAt(offset))
+	}
+
+	depth := 0
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(content)), false)
+	for {
+		gt, _, _ := p.Next()
+		if gt == css.ErrorGrammar {
+			break
+		}
+		switch gt {
+		case css.BeginAtRuleGrammar:
+			if depth > 0 {
+				addReason(GMAIL_NESTED_AT_RULE, lineAt(p.Offset()))
+			}
+			depth += 1
+		case css.EndAtRuleGrammar:
+			depth -= 1
+		}
+	}
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.pr.Gmail.StyleBlocks = append(prs.pr.Gmail.StyleBlocks, block)
+}
+
+// gmailClipLine return line of document, where byte at clipping threshold placed
+func gmailClipLine(document []byte) int {
+	if len(document) <= GMAIL_CLIP_THRESHOLD {
+		return 0
+	}
+	return bytes.Count(document[:GMAIL_CLIP_THRESHOLD], []byte("\n")) + 1
+}
+
+// lineContent return text of line in document
+func lineContent(document []byte, line int) string {
+	lines := bytes.SplitN(document, []byte("\n"), line+1)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return string(lines[line-1])
+}
+
+// checkGmail compare html size with clipping threshold, should be called after all style blocks processed
+func (prs *ParserEngine) checkGmail() {
+	report := &prs.pr.Gmail
+	report.Bytes = len(prs.document)
+	report.ClipThreshold = GMAIL_CLIP_THRESHOLD
+
+	if line := gmailClipLine(prs.document); line > 0 {
+		report.Clipped = true
+		report.ClipLine = line
+		prs.saveToReportGmail(GMAIL_CLIPPED, SourcePosition{Line: line}.withSnippet(limitSnippet(strings.TrimSpace(lineContent(prs.document, line)))))
+	}
+
+	if prs.options.InlinedHTML != nil {
+		report.InlinedBytes = len(prs.options.InlinedHTML)
+		if line := gmailClipLine(prs.options.InlinedHTML); line > 0 {
+			report.InlinedClipped = true
+			// inlined html lines do not map to source lines, so clipping point reported only in gmail report
+			report.InlinedClipLine = line
+		}
+	}
+
+	// blocks processed in parallel
+	sort.Slice(report.StyleBlocks, func(i, j int) bool {
+		return report.StyleBlocks[i].Index < report.StyleBlocks[j].Index
+	})
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportFromHTMLGmail(t *testing.T) {
+	largeStyle := ".a { color: red; }\n" + strings.Repeat("/* padding */", GMAIL_STYLE_BLOCK_LIMIT/13)
+	largeBody := strings.Repeat("<p>Text</p>\n", GMAIL_CLIP_THRESHOLD/12+1)
+
+	var tests = []struct {
+		name       string
+		html       string
+		options    ParserOptions
+		wantIssues map[string]map[int]bool
+		wantBlocks []GmailStyleBlock
+		wantReport GmailReport
+	}{
+		{
+			"valid email",
+			`<html><head><style>
+	.title { color: red; }
+	@media (max-width: 600px) { .title { font-size: 20px; } }
+</style></head><body><p class="title">Text</p></body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{},
+			[]GmailStyleBlock{{Index: 0, Line: 1, Bytes: 84, Reasons: []string{}}},
+			GmailReport{Bytes: 163, ClipThreshold: GMAIL_CLIP_THRESHOLD},
+		},
+		{
+			"dropped blocks",
+			`<html><head>
+<style>
+	@supports (display: grid) {
+		@media (max-width: 600px) { .a { color: red; } }
+	}
+	.b { color: blue; }}
+</style>
+<style>` + largeStyle + `</style>
+</head><body>
+<p class="a">Text</p>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{
+				GMAIL_NESTED_AT_RULE:        {4: true},
+				GMAIL_STYLE_SYNTAX_ERROR:    {6: true},
+				GMAIL_STYLE_BLOCK_TOO_LARGE: {8: true},
+			},
+			[]GmailStyleBlock{
+				{Index: 0, Line: 2, Bytes: 106, Dropped: true, Reasons: []string{GMAIL_STYLE_SYNTAX_ERROR, GMAIL_NESTED_AT_RULE}},
+				{Index: 1, Line: 8, Bytes: len(largeStyle), Dropped: true, Reasons: []string{GMAIL_STYLE_BLOCK_TOO_LARGE}},
+			},
+			GmailReport{},
+		},
+		{
+			"clipped",
+			largeBody,
+			ParserOptions{Fragment: true, InlinedHTML: []byte(largeBody + largeBody)},
+			map[string]map[int]bool{
+				GMAIL_CLIPPED: {GMAIL_CLIP_THRESHOLD/12 + 1: true},
+			},
+			nil,
+			GmailReport{
+				Bytes:           len(largeBody),
+				InlinedBytes:    2 * len(largeBody),
+				ClipThreshold:   GMAIL_CLIP_THRESHOLD,
+				Clipped:         true,
+				ClipLine:        GMAIL_CLIP_THRESHOLD/12 + 1,
+				InlinedClipped:  true,
+				InlinedClipLine: GMAIL_CLIP_THRESHOLD/12 + 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTMLWithOptions([]byte(tt.html), tt.options)
+			if err != nil {
+				t.Fatalf("ReportFromHTMLWithOptions() error = %v", err)
+			}
+
+			gotIssues := make(map[string]map[int]bool)
+			for issue, item := range report.GmailIssues {
+				gotIssues[issue] = item.Lines
+			}
+			if !reflect.DeepEqual(gotIssues, tt.wantIssues) {
+				t.Errorf("issues: got %v, want %v", gotIssues, tt.wantIssues)
+			}
+			if !reflect.DeepEqual(report.Gmail.StyleBlocks, tt.wantBlocks) {
+				t.Errorf("style blocks: got %+v, want %+v", report.Gmail.StyleBlocks, tt.wantBlocks)
+			}
+			if tt.wantReport.ClipThreshold > 0 {
+				gotReport := report.Gmail
+				gotReport.StyleBlocks = nil
+				if !reflect.DeepEqual(gotReport, tt.wantReport) {
+					t.Errorf("report: got %+v, want %+v", gotReport, tt.wantReport)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ module github.com/le0pard/vmail/wasm_parser/parser
 go 1.25.0
 
 require (
+	github.com/andybalholm/cascadia v1.3.3
 	github.com/tdewolff/parse/v2 v2.8.11
 	golang.org/x/net v0.55.0
 )
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"strings"
+
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+// guards, which make finding a progressive enhancement instead of breakage
+const (
+	GUARD_FALLBACK_DECLARATION = "fallback_declaration" // same block has earlier fallback declaration
+	GUARD_SUPPORTS_RULE        = "supports_rule"        // wrapped by @supports
+	GUARD_GHOST_STRUCTURE      = "ghost_structure"      // wrapped by table for outlook in conditional comments
+)
+
+var (
+	// properties, which earlier declarations work as fallback for later property
+	cssFallbackProperties = map[string][]string{
+		"background":       {"background-color", "background-image"},
+		"background-image": {"background", "background-color"},
+		"max-width":        {"width"},
+		"min-width":        {"width"},
+		"max-height":       {"height"},
+		"min-height":       {"height"},
+		"border-radius":    {"border"},
+		"box-shadow":       {"border"},
+		"gap":              {"margin", "padding"},
+	}
+	// ghost table for outlook limits width of wrapped element
+	ghostGuardedProperties = map[string]bool{
+		"width":     true,
+		"max-width": true,
+	}
+)
+
+func (pos SourcePosition) withGuard(guard string) SourcePosition {
+	if len(pos.Guard) == 0 {
+		pos.Guard = guard
+	}
+	return pos
+}
+
+func (pos SourcePosition) isGuarded() bool {
+	return len(pos.Guard) > 0
+}
+
+func cssDeclarationGuard(propertyKey string, declaredProperties map[string]bool) string {
+	if declaredProperties[propertyKey] {
+		return GUARD_FALLBACK_DECLARATION
+	}
+	for _, fallbackKey := range cssFallbackProperties[propertyKey] {
+		if declaredProperties[fallbackKey] {
+			return GUARD_FALLBACK_DECLARATION
+		}
+	}
+	return ""
+}
+
+// cssBlockGuard track declarations in the same block and return guard for current one
+func cssBlockGuard(gt css.GrammarType, data []byte, declaredProperties map[string]bool) string {
+	switch gt {
+	case css.DeclarationGrammar:
+		propertyKey := strings.ToLower(string(data))
+		guard := cssDeclarationGuard(propertyKey, declaredProperties)
+		declaredProperties[propertyKey] = true
+		return guard
+	case css.BeginRulesetGrammar, css.EndRulesetGrammar, css.AtRuleGrammar, css.BeginAtRuleGrammar, css.EndAtRuleGrammar:
+		clear(declaredProperties) // new block
+	}
+	return ""
+}
+
+func isCssSupportsGuarded(atRules []string) bool {
+	for _, atRule := range atRules {
+		if strings.HasPrefix(strings.ToLower(atRule), "@supports") {
+			return true
+		}
+	}
+	return false
+}
+
+// trackGhostStructure count tables, which opened for outlook only, and find elements wrapped by them
+func (prs *ParserEngine) trackGhostStructure(token html.Token) {
+	prs.isGhostWrapped = false
+
+	if prs.isHiddenConditional {
+		if !isMsoCondition(prs.conditionalComment) || token.DataAtom != a.Table {
+			return
+		}
+		switch token.Type {
+		case html.StartTagToken:
+			prs.ghostTablesDepth += 1
+		case html.EndTagToken:
+			if prs.ghostTablesDepth > 0 {
+				prs.ghostTablesDepth -= 1
+			}
+		}
+		prs.ghostContentDepth = 0
+		return
+	}
+
+	if prs.ghostTablesDepth == 0 {
+		return
+	}
+
+	switch token.Type {
+	case html.StartTagToken:
+		prs.isGhostWrapped = prs.ghostContentDepth == 0
+		if !voidHtmlElements[strings.ToLower(token.Data)] {
+			prs.ghostContentDepth += 1
+		}
+	case html.SelfClosingTagToken:
+		prs.isGhostWrapped = prs.ghostContentDepth == 0
+	case html.EndTagToken:
+		if prs.ghostContentDepth > 0 {
+			prs.ghostContentDepth -= 1
+		}
+	}
+}
+
+// ghostGuard return guard for width of element, which wrapped by ghost table
+func (prs *ParserEngine) ghostGuard(propertyKey string) string {
+	if prs.isGhostWrapped && ghostGuardedProperties[strings.ToLower(propertyKey)] {
+		return GUARD_GHOST_STRUCTURE
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLGuards(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.button { background: #f00; background: linear-gradient(#f00, #0f0); }
+		@supports (display: grid) {
+			.grid { display: grid; }
+		}
+		.flex { display: flex; }
+	</style>
+</head>
+<body>
+	<!--[if mso]><table role="presentation" width="600"><tr><td><![endif]-->
+	<div style="width: 600px; max-width: 100%;">Content</div>
+	<!--[if mso]></td></tr></table><![endif]-->
+	<div style="max-width: 100%;">Content</div>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	var tests = []struct {
+		checkType   string
+		container   ReportContainer
+		want        map[int]string
+		onlyGuarded bool
+	}{
+		{"CssFunctions linear-gradient", report.CssFunctions["linear-gradient"], map[int]string{4: GUARD_FALLBACK_DECLARATION}, true},
+		{"CssProperties display grid", report.CssProperties["display"]["grid"], map[int]string{6: GUARD_SUPPORTS_RULE}, true},
+		{"CssProperties display flex", report.CssProperties["display"]["flex"], map[int]string{8: ""}, false},
+		{"CssProperties max-width", report.CssProperties["max-width"][""], map[int]string{13: GUARD_GHOST_STRUCTURE, 15: ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			got := make(map[int]string)
+			for _, occurrence := range tt.container.Occurrences {
+				got[occurrence.Line] = occurrence.Guard
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, got, tt.want)
+			}
+			if tt.container.OnlyGuarded != tt.onlyGuarded {
+				t.Errorf("%s: OnlyGuarded got %v, want %v", tt.checkType, tt.container.OnlyGuarded, tt.onlyGuarded)
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLGuardsScope(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.button { background: #f00; /* fallback */ background: linear-gradient(#f00, #0f0); }
+	</style>
+</head>
+<body>
+	<!--[if mso]><table role="presentation" width="600"><tr><td><![endif]-->
+	<div style="max-width: 600px; display: flex;">
+		<video src="movie.mp4"></video>
+		<div style="max-width: 300px;">Nested</div>
+	</div>
+	<!--[if mso]></td></tr></table><![endif]-->
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	var tests = []struct {
+		checkType string
+		container ReportContainer
+		want      map[int]string
+	}{
+		{"CssProperties max-width", report.CssProperties["max-width"][""], map[int]string{9: GUARD_GHOST_STRUCTURE, 11: ""}},
+		{"CssProperties display flex", report.CssProperties["display"]["flex"], map[int]string{9: ""}},
+		{"HtmlTags video", report.HtmlTags["video"][""], map[int]string{10: ""}},
+		{"CssFunctions linear-gradient", report.CssFunctions["linear-gradient"], map[int]string{4: GUARD_FALLBACK_DECLARATION}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			got := make(map[int]string)
+			for _, occurrence := range tt.container.Occurrences {
+				got[occurrence.Line] = occurrence.Guard
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, got, tt.want)
+			}
+		})
+	}
+
+}
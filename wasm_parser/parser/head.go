@@ -0,0 +1,286 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+// email head audit issues
+const (
+	HEAD_ISSUE_MISSING_VIEWPORT                = "missing_viewport"
+	HEAD_ISSUE_INVALID_VIEWPORT                = "invalid_viewport"
+	HEAD_ISSUE_CONFLICTING_VIEWPORT            = "conflicting_viewport"
+	HEAD_ISSUE_MISSING_DISABLE_REFORMATTING    = "missing_disable_message_reformatting"
+	HEAD_ISSUE_MISSING_FORMAT_DETECTION        = "missing_format_detection"
+	HEAD_ISSUE_CONFLICTING_FORMAT_DETECTION    = "conflicting_format_detection"
+	HEAD_ISSUE_MISSING_COLOR_SCHEME            = "missing_color_scheme"
+	HEAD_ISSUE_MISSING_SUPPORTED_COLOR_SCHEMES = "missing_supported_color_schemes"
+	HEAD_ISSUE_CONFLICTING_COLOR_SCHEME        = "conflicting_color_scheme"
+	HEAD_ISSUE_MISSING_CHARSET                 = "missing_charset"
+	HEAD_ISSUE_CONFLICTING_CHARSET             = "conflicting_charset"
+	HEAD_ISSUE_MISSING_TITLE                   = "missing_title"
+	HEAD_ISSUE_EMPTY_TITLE                     = "empty_title"
+	HEAD_ISSUE_CONFLICTING_TITLE               = "conflicting_title"
+	HEAD_ISSUE_MISSING_HTML_LANG               = "missing_html_lang"
+	HEAD_ISSUE_EMPTY_HTML_LANG                 = "empty_html_lang"
+
+	SEVERITY_INFO = "info"
+)
+
+// email head meta tags
+const (
+	HEAD_META_VIEWPORT                = "viewport"
+	HEAD_META_DISABLE_REFORMATTING    = "x-apple-disable-message-reformatting"
+	HEAD_META_FORMAT_DETECTION        = "format-detection"
+	HEAD_META_COLOR_SCHEME            = "color-scheme"
+	HEAD_META_SUPPORTED_COLOR_SCHEMES = "supported-color-schemes"
+	HEAD_META_CHARSET                 = "charset"
+)
+
+var (
+	httpEquivCharsetRe = regexp.MustCompile(`(?i)charset\s*=\s*["']?([^\s;"']+)`)
+	viewportWidthRe    = regexp.MustCompile(`(?i)width\s*=\s*device-width`)
+)
+
+var headAuditRulesDB = map[string]map[string]interface{}{
+	HEAD_ISSUE_MISSING_VIEWPORT:                makeIssueRule("Missing viewport meta", "Without <meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"> mobile clients render email zoomed out as desktop page.", SEVERITY_WARNING),
+	HEAD_ISSUE_INVALID_VIEWPORT:                makeIssueRule("Viewport without device width", "Viewport meta does not contain width=device-width, so media queries for small screens may not match on mobile clients.", SEVERITY_WARNING),
+	HEAD_ISSUE_CONFLICTING_VIEWPORT:            makeIssueRule("Conflicting viewport meta", "Viewport meta declared several times with different content. Clients use only one of them.", SEVERITY_WARNING),
+	HEAD_ISSUE_MISSING_DISABLE_REFORMATTING:    makeIssueRule("Missing x-apple-disable-message-reformatting meta", "Without <meta name=\"x-apple-disable-message-reformatting\"> Apple Mail on iOS can scale email content.", SEVERITY_INFO),
+	HEAD_ISSUE_MISSING_FORMAT_DETECTION:        makeIssueRule("Missing format-detection meta", "Without <meta name=\"format-detection\" content=\"telephone=no, date=no, address=no, email=no\"> Apple Mail turns phone numbers, dates and addresses into blue links.", SEVERITY_INFO),
+	HEAD_ISSUE_CONFLICTING_FORMAT_DETECTION:    makeIssueRule("Conflicting format-detection meta", "Format detection for the same data type declared with different values.", SEVERITY_WARNING),
+	HEAD_ISSUE_MISSING_COLOR_SCHEME:            makeIssueRule("Missing color-scheme meta", "Without <meta name=\"color-scheme\" content=\"light dark\"> clients with dark mode may invert email colors automatically.", SEVERITY_INFO),
+	HEAD_ISSUE_MISSING_SUPPORTED_COLOR_SCHEMES: makeIssueRule("Missing supported-color-schemes meta", "Older Apple Mail versions read <meta name=\"supported-color-schemes\"> instead of color-scheme, it should be declared with the same content.", SEVERITY_INFO),
+	HEAD_ISSUE_CONFLICTING_COLOR_SCHEME:        makeIssueRule("Conflicting color-scheme meta", "color-scheme and supported-color-schemes meta (or several declarations of them) have different content, so clients apply different color schemes.", SEVERITY_WARNING),
+	HEAD_ISSUE_MISSING_CHARSET:                 makeIssueRule("Missing charset declaration", "Without <meta charset=\"utf-8\"> or Content-Type http-equiv meta, non ASCII characters can be broken in some clients.", SEVERITY_WARNING),
+	HEAD_ISSUE_CONFLICTING_CHARSET:             makeIssueRule("Conflicting charset declarations", "Charset declared several times with different values. Clients use only one of them.", SEVERITY_ERROR),
+	HEAD_ISSUE_MISSING_TITLE:                   makeIssueRule("Missing <title>", "Some clients and web versions of email show <title> in browser tab or preview.", SEVERITY_INFO),
+	HEAD_ISSUE_EMPTY_TITLE:                     makeIssueRule("Empty <title>", "<title> has no text, clients show file name or nothing instead.", SEVERITY_INFO),
+	HEAD_ISSUE_CONFLICTING_TITLE:               makeIssueRule("Several <title> elements", "Document should have only one <title>.", SEVERITY_WARNING),
+	HEAD_ISSUE_MISSING_HTML_LANG:               makeIssueRule("Missing lang on <html>", "Without lang attribute screen readers can read email content with wrong pronunciation.", SEVERITY_WARNING),
+	HEAD_ISSUE_EMPTY_HTML_LANG:                 makeIssueRule("Empty lang on <html>", "lang attribute on <html> has no value.", SEVERITY_WARNING),
+}
+
+type headMetaEntry struct {
+	value    string
+	position SourcePosition
+}
+
+type headAuditState struct {
+	metas       map[string][]headMetaEntry
+	titles      []headMetaEntry
+	isTitleOpen bool
+	html        *headMetaEntry
+	htmlLang    *headMetaEntry
+	head        *headMetaEntry
+}
+
+func (prs *ParserEngine) saveToReportHeadAudit(issue string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.HeadAudit, headAuditRulesDB, issue, position)
+}
+
+func (prs *ParserEngine) addHeadMeta(name, value string, position SourcePosition) {
+	prs.headAudit.metas[name] = append(prs.headAudit.metas[name], headMetaEntry{
+		value:    collapseWhitespace(value),
+		position: position,
+	})
+}
+
+// collectHeadAudit remember email related head elements
+func (prs *ParserEngine) collectHeadAudit(token html.Token, position SourcePosition) {
+	if prs.isHiddenConditional {
+		return
+	}
+
+	switch token.Type {
+	case html.TextToken:
+		if prs.headAudit.isTitleOpen && len(prs.headAudit.titles) > 0 {
+			prs.headAudit.titles[len(prs.headAudit.titles)-1].value += token.Data
+		}
+	case html.EndTagToken:
+		if token.DataAtom == a.Title {
+			prs.headAudit.isTitleOpen = false
+		}
+	case html.StartTagToken, html.SelfClosingTagToken:
+		switch token.DataAtom {
+		case a.Html:
+			if prs.headAudit.html == nil {
+				prs.headAudit.html = &headMetaEntry{position: position}
+				for _, att := range token.Attr {
+					if strings.ToLower(att.Key) == "lang" {
+						prs.headAudit.htmlLang = &headMetaEntry{value: strings.Trim(att.Val, WHITESPACE), position: position}
+					}
+				}
+			}
+		case a.Head:
+			if prs.headAudit.head == nil {
+				prs.headAudit.head = &headMetaEntry{position: position}
+			}
+		case a.Title:
+			if prs.isInsideForeignHtml() {
+				return // svg title
+			}
+			prs.headAudit.titles = append(prs.headAudit.titles, headMetaEntry{position: position})
+			prs.headAudit.isTitleOpen = token.Type == html.StartTagToken
+		case a.Meta:
+			name := strings.ToLower(getHtmlAttributeValue(token.Attr, "name"))
+			content := getHtmlAttributeValue(token.Attr, "content")
+
+			if charset := getHtmlAttributeValue(token.Attr, "charset"); len(charset) > 0 {
+				prs.addHeadMeta(HEAD_META_CHARSET, charset, position)
+			}
+			if strings.ToLower(getHtmlAttributeValue(token.Attr, "http-equiv")) == "content-type" {
+				if match := httpEquivCharsetRe.FindStringSubmatch(content); match != nil {
+					prs.addHeadMeta(HEAD_META_CHARSET, match[1], position)
+				}
+			}
+
+			switch name {
+			case HEAD_META_VIEWPORT, HEAD_META_DISABLE_REFORMATTING, HEAD_META_FORMAT_DETECTION, HEAD_META_COLOR_SCHEME, HEAD_META_SUPPORTED_COLOR_SCHEMES:
+				prs.addHeadMeta(name, content, position)
+			}
+		}
+	}
+}
+
+func normalizeCharset(charset string) string {
+	charset = strings.ToLower(charset)
+	if charset == "utf8" {
+		return "utf-8"
+	}
+	return charset
+}
+
+func normalizeColorSchemes(content string) string {
+	var schemes []string
+	for _, scheme := range strings.Fields(strings.ToLower(content)) {
+		if scheme != "only" {
+			schemes = append(schemes, scheme)
+		}
+	}
+	sort.Strings(schemes)
+	return strings.Join(schemes, " ")
+}
+
+func parseFormatDetection(content string) map[string]string {
+	formats := make(map[string]string)
+	for _, part := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool { return r == ',' || r == ';' }) {
+		if key, val, ok := strings.Cut(part, "="); ok {
+			formats[strings.Trim(key, WHITESPACE)] = strings.Trim(val, WHITESPACE)
+		}
+	}
+	return formats
+}
+
+// saveConflictingHeadEntries report all entries, if normalized values are different
+func (prs *ParserEngine) saveConflictingHeadEntries(issue string, entries []headMetaEntry, normalize func(string) string) {
+	values := make(map[string]bool)
+	for _, entry := range entries {
+		values[normalize(entry.value)] = true
+	}
+	if len(values) < 2 {
+		return
+	}
+	for _, entry := range entries {
+		prs.saveToReportHeadAudit(issue, entry.position)
+	}
+}
+
+func (prs *ParserEngine) headAuditPosition() SourcePosition {
+	if prs.headAudit.head != nil {
+		return prs.headAudit.head.position
+	}
+	if prs.headAudit.html != nil {
+		return prs.headAudit.html.position
+	}
+	return SourcePosition{Line: 1}
+}
+
+func (prs *ParserEngine) headAuditHtmlPosition() SourcePosition {
+	if prs.headAudit.html != nil {
+		return prs.headAudit.html.position
+	}
+	return SourcePosition{Line: 1}
+}
+
+// checkHeadAudit report missing and conflicting email head entries
+func (prs *ParserEngine) checkHeadAudit() {
+	metas := prs.headAudit.metas
+	missingPosition := prs.headAuditPosition()
+
+	// viewport
+	if len(metas[HEAD_META_VIEWPORT]) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_VIEWPORT, missingPosition)
+	}
+	for _, entry := range metas[HEAD_META_VIEWPORT] {
+		if !viewportWidthRe.MatchString(entry.value) {
+			prs.saveToReportHeadAudit(HEAD_ISSUE_INVALID_VIEWPORT, entry.position)
+		}
+	}
+	prs.saveConflictingHeadEntries(HEAD_ISSUE_CONFLICTING_VIEWPORT, metas[HEAD_META_VIEWPORT], func(value string) string {
+		return strings.ReplaceAll(strings.ToLower(value), " ", "")
+	})
+
+	// apple reformatting
+	if len(metas[HEAD_META_DISABLE_REFORMATTING]) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_DISABLE_REFORMATTING, missingPosition)
+	}
+
+	// format detection
+	if len(metas[HEAD_META_FORMAT_DETECTION]) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_FORMAT_DETECTION, missingPosition)
+	}
+	formatDetection := make(map[string][]headMetaEntry)
+	for _, entry := range metas[HEAD_META_FORMAT_DETECTION] {
+		for format, value := range parseFormatDetection(entry.value) {
+			formatDetection[format] = append(formatDetection[format], headMetaEntry{value: value, position: entry.position})
+		}
+	}
+	for _, entries := range formatDetection {
+		prs.saveConflictingHeadEntries(HEAD_ISSUE_CONFLICTING_FORMAT_DETECTION, entries, strings.ToLower)
+	}
+
+	// color schemes
+	colorSchemes := metas[HEAD_META_COLOR_SCHEME]
+	supportedColorSchemes := metas[HEAD_META_SUPPORTED_COLOR_SCHEMES]
+	if len(colorSchemes) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_COLOR_SCHEME, missingPosition)
+	}
+	if len(colorSchemes) > 0 && len(supportedColorSchemes) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_SUPPORTED_COLOR_SCHEMES, colorSchemes[0].position)
+	}
+	prs.saveConflictingHeadEntries(HEAD_ISSUE_CONFLICTING_COLOR_SCHEME, append(colorSchemes[:len(colorSchemes):len(colorSchemes)], supportedColorSchemes...), normalizeColorSchemes)
+
+	// charset
+	if len(metas[HEAD_META_CHARSET]) == 0 {
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_CHARSET, missingPosition)
+	}
+	prs.saveConflictingHeadEntries(HEAD_ISSUE_CONFLICTING_CHARSET, metas[HEAD_META_CHARSET], normalizeCharset)
+
+	// title
+	switch {
+	case len(prs.headAudit.titles) == 0:
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_TITLE, missingPosition)
+	case len(prs.headAudit.titles) > 1:
+		for _, entry := range prs.headAudit.titles {
+			prs.saveToReportHeadAudit(HEAD_ISSUE_CONFLICTING_TITLE, entry.position)
+		}
+	}
+	for _, entry := range prs.headAudit.titles {
+		if len(strings.Trim(entry.value, WHITESPACE)) == 0 {
+			prs.saveToReportHeadAudit(HEAD_ISSUE_EMPTY_TITLE, entry.position)
+		}
+	}
+
+	// html lang
+	switch {
+	case prs.headAudit.htmlLang == nil:
+		prs.saveToReportHeadAudit(HEAD_ISSUE_MISSING_HTML_LANG, prs.headAuditHtmlPosition())
+	case len(prs.headAudit.htmlLang.value) == 0:
+		prs.saveToReportHeadAudit(HEAD_ISSUE_EMPTY_HTML_LANG, prs.headAudit.htmlLang.position)
+	}
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLHeadAudit(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want map[string]map[int]bool
+	}{
+		{
+			"complete head",
+			`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta name="x-apple-disable-message-reformatting">
+	<meta name="format-detection" content="telephone=no, date=no, address=no, email=no">
+	<meta name="color-scheme" content="light dark">
+	<meta name="supported-color-schemes" content="light dark">
+	<title>Newsletter</title>
+</head>
+<body></body></html>`,
+			map[string]map[int]bool{},
+		},
+		{
+			"missing and conflicting entries",
+			`<html>
+<head>
+	<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+	<meta charset="iso-8859-1">
+	<meta name="viewport" content="initial-scale=1">
+	<meta name="format-detection" content="telephone=no">
+	<meta name="format-detection" content="telephone=yes">
+	<meta name="color-scheme" content="light dark">
+	<meta name="supported-color-schemes" content="light">
+	<title> </title>
+	<title>Second</title>
+</head>
+<body></body></html>`,
+			map[string]map[int]bool{
+				HEAD_ISSUE_INVALID_VIEWPORT:             {5: true},
+				HEAD_ISSUE_MISSING_DISABLE_REFORMATTING: {2: true},
+				HEAD_ISSUE_CONFLICTING_FORMAT_DETECTION: {6: true, 7: true},
+				HEAD_ISSUE_CONFLICTING_COLOR_SCHEME:     {8: true, 9: true},
+				HEAD_ISSUE_CONFLICTING_CHARSET:          {3: true, 4: true},
+				HEAD_ISSUE_EMPTY_TITLE:                  {10: true},
+				HEAD_ISSUE_CONFLICTING_TITLE:            {10: true, 11: true},
+				HEAD_ISSUE_MISSING_HTML_LANG:            {1: true},
+			},
+		},
+		{
+			"empty head",
+			`<html lang=""><head></head><body></body></html>`,
+			map[string]map[int]bool{
+				HEAD_ISSUE_MISSING_VIEWPORT:             {1: true},
+				HEAD_ISSUE_MISSING_DISABLE_REFORMATTING: {1: true},
+				HEAD_ISSUE_MISSING_FORMAT_DETECTION:     {1: true},
+				HEAD_ISSUE_MISSING_COLOR_SCHEME:         {1: true},
+				HEAD_ISSUE_MISSING_CHARSET:              {1: true},
+				HEAD_ISSUE_MISSING_TITLE:                {1: true},
+				HEAD_ISSUE_EMPTY_HTML_LANG:              {1: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTML([]byte(tt.html))
+			if err != nil {
+				t.Fatalf(`ReportFromHTML("%s"), %v`, tt.html, err)
+			}
+
+			got := make(map[string]map[int]bool)
+			for issue, container := range report.HeadAudit {
+				got[issue] = container.Lines
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HeadAudit: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
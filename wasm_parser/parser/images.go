@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	LIMIT_REPORT_IMAGES  = 500
+	LIMIT_DATA_URI_SHOWN = 40
+)
+
+// how format of image was detected, ordered by priority
+const (
+	IMAGE_FORMAT_SOURCE_MIME      = "mime"
+	IMAGE_FORMAT_SOURCE_TYPE      = "type_attribute"
+	IMAGE_FORMAT_SOURCE_QUERY     = "query"
+	IMAGE_FORMAT_SOURCE_EXTENSION = "extension"
+)
+
+var (
+	imageMimeFormats = map[string]string{
+		"image/apng":               "apng",
+		"image/avif":               "avif",
+		"image/bmp":                "bmp",
+		"image/gif":                "gif",
+		"image/heic":               "heif",
+		"image/heif":               "heif",
+		"image/jpeg":               "jpg",
+		"image/jpg":                "jpg",
+		"image/jxl":                "jxl",
+		"image/png":                "png",
+		"image/svg+xml":            "svg",
+		"image/tiff":               "tiff",
+		"image/vnd.microsoft.icon": "ico",
+		"image/webp":               "webp",
+		"image/x-icon":             "ico",
+		"video/mp4":                "mp4",
+	}
+
+	imageExtensionFormats = map[string]string{
+		"apng": "apng",
+		"avif": "avif",
+		"bmp":  "bmp",
+		"gif":  "gif",
+		"hdr":  "hdr",
+		"heic": "heif",
+		"heif": "heif",
+		"ico":  "ico",
+		"jfif": "jpg",
+		"jpe":  "jpg",
+		"jpeg": "jpg",
+		"jpg":  "jpg",
+		"jxl":  "jxl",
+		"mp4":  "mp4",
+		"png":  "png",
+		"svg":  "svg",
+		"svgz": "svg",
+		"tif":  "tiff",
+		"tiff": "tiff",
+		"webp": "webp",
+	}
+
+	// image CDN parameters with output format (imgix, contentful, sanity, shopify and others)
+	imageQueryFormatKeys = []string{"fm", "format", "output", "f"}
+	// cloudinary-like transformations in path: /f_webp,q_auto/
+	imagePathFormatRe = regexp.MustCompile(`(?:^|[/,])f_([a-z0-9]+)(?:[/,]|$)`)
+)
+
+type ImageReference struct {
+	Url          string `json:"url"`
+	Source       string `json:"source"` // element attribute or css property with image
+	Format       string `json:"format"`
+	FormatSource string `json:"format_source"`
+	Line         int    `json:"line"`
+	File         string `json:"file,omitempty"`
+	Snippet      string `json:"snippet"`
+	Context      string `json:"context"`
+}
+
+func parseDataUriMime(imgUrl string) string {
+	mime := strings.TrimPrefix(imgUrl, "data:")
+	if index := strings.IndexAny(mime, ";,"); index >= 0 {
+		mime = mime[:index]
+	}
+	return strings.Trim(mime, WHITESPACE)
+}
+
+// detectImageFormat use mime type, type attribute, query hints and extension (in this order)
+func detectImageFormat(imgUrl, typeAttr string) (string, string) {
+	imgUrl = strings.ToLower(imgUrl)
+
+	if strings.HasPrefix(imgUrl, "data:") {
+		if format, ok := imageMimeFormats[parseDataUriMime(imgUrl)]; ok {
+			return format, IMAGE_FORMAT_SOURCE_MIME
+		}
+		return "", ""
+	}
+
+	if format, ok := imageMimeFormats[strings.ToLower(strings.Trim(typeAttr, WHITESPACE))]; ok {
+		return format, IMAGE_FORMAT_SOURCE_TYPE
+	}
+
+	urlData, err := url.Parse(imgUrl)
+	if err != nil {
+		return "", ""
+	}
+
+	query := urlData.Query()
+	for _, key := range imageQueryFormatKeys {
+		if format, ok := imageExtensionFormats[query.Get(key)]; ok {
+			return format, IMAGE_FORMAT_SOURCE_QUERY
+		}
+	}
+	if match := imagePathFormatRe.FindStringSubmatch(urlData.Path); match != nil {
+		if format, ok := imageExtensionFormats[match[1]]; ok {
+			return format, IMAGE_FORMAT_SOURCE_QUERY
+		}
+	}
+
+	if format, ok := imageExtensionFormats[strings.TrimPrefix(path.Ext(urlData.Path), ".")]; ok {
+		return format, IMAGE_FORMAT_SOURCE_EXTENSION
+	}
+	return "", ""
+}
+
+// parseSrcset return urls from srcset, urls can contain commas (data uri, cdn transformations)
+func parseSrcset(srcset string) []string {
+	var (
+		urls []string
+		i    int
+	)
+
+	for i < len(srcset) {
+		for i < len(srcset) && (strings.IndexByte(WHITESPACE, srcset[i]) >= 0 || srcset[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(srcset) && strings.IndexByte(WHITESPACE, srcset[i]) < 0 {
+			i++
+		}
+		imgUrl := srcset[start:i]
+		if strings.HasSuffix(imgUrl, ",") { // candidate without descriptors
+			imgUrl = strings.TrimRight(imgUrl, ",")
+		} else {
+			for i < len(srcset) && srcset[i] != ',' { // skip descriptors
+				i++
+			}
+		}
+		if len(imgUrl) > 0 {
+			urls = append(urls, imgUrl)
+		}
+	}
+
+	return urls
+}
+
+func shortImageUrl(imgUrl string) string {
+	if strings.HasPrefix(strings.ToLower(imgUrl), "data:") && len(imgUrl) > LIMIT_DATA_URI_SHOWN {
+		return imgUrl[:LIMIT_DATA_URI_SHOWN] + "…"
+	}
+	return limitSnippet(imgUrl)
+}
+
+func (prs *ParserEngine) saveToReportImage(image ImageReference) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if len(prs.pr.Images) >= LIMIT_REPORT_IMAGES {
+		prs.pr.ImagesMore = true
+		return
+	}
+	prs.pr.Images = append(prs.pr.Images, image)
+}
+
+// checkImageReference detect format of image and report it
+func (prs *ParserEngine) checkImageReference(source, imgUrl, typeAttr string, position SourcePosition) {
+	imgUrl = strings.Trim(imgUrl, WHITESPACE)
+	if len(imgUrl) == 0 {
+		return
+	}
+
+	format, formatSource := detectImageFormat(imgUrl, typeAttr)
+
+	if strings.HasPrefix(strings.ToLower(imgUrl), "data:") && strings.Contains(strings.ToLower(imgUrl), "base64") {
+		if imgFormatsData, ok := rulesDB.ImgFormats["base64"]; ok {
+			prs.saveToReportImgFormats("base64", position, imgFormatsData)
+		}
+	}
+	if len(format) > 0 {
+		imgFormatsData, ok := rulesDB.ImgFormats[format]
+		prs.traceRuleLookup("img_formats", format, ok, position)
+		if ok {
+			prs.saveToReportImgFormats(format, position, imgFormatsData)
+		}
+	}
+
+	prs.saveToReportImage(ImageReference{
+		Url:          shortImageUrl(imgUrl),
+		Source:       source,
+		Format:       format,
+		FormatSource: formatSource,
+		Line:         position.Line,
+		Snippet:      position.Snippet,
+	})
+}
+
+func (prs *ParserEngine) checkImageAttribute(tagName, attrKey string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	attrVal := getHtmlAttributeValue(attrs, attrKey)
+	if len(attrVal) == 0 {
+		return
+	}
+
+	source := tagName + "[" + attrKey + "]"
+	typeAttr := ""
+	if tagName == "source" {
+		typeAttr = getHtmlAttributeValue(attrs, "type")
+	}
+	position = attributePosition(attrsPositions, attrKey, position)
+
+	if attrKey == "srcset" {
+		for _, imgUrl := range parseSrcset(attrVal) {
+			prs.checkImageReference(source, imgUrl, typeAttr, position)
+		}
+		return
+	}
+	prs.checkImageReference(source, attrVal, typeAttr, position)
+}
+
+// checkHtmlImages find images in html elements
+func (prs *ParserEngine) checkHtmlImages(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	switch tagName {
+	case "img":
+		prs.checkImageAttribute(tagName, "src", attrs, attrsPositions, position)
+		prs.checkImageAttribute(tagName, "srcset", attrs, attrsPositions, position)
+	case "source":
+		typeAttr := strings.ToLower(getHtmlAttributeValue(attrs, "type"))
+		if prs.htmlStackIndexOf("picture") >= 0 || strings.HasPrefix(typeAttr, "image/") {
+			prs.checkImageAttribute(tagName, "srcset", attrs, attrsPositions, position)
+			prs.checkImageAttribute(tagName, "src", attrs, attrsPositions, position)
+		}
+	case "input":
+		if strings.ToLower(getHtmlAttributeValue(attrs, "type")) == "image" {
+			prs.checkImageAttribute(tagName, "src", attrs, attrsPositions, position)
+		}
+	case "video":
+		prs.checkImageAttribute(tagName, "poster", attrs, attrsPositions, position)
+	case "body", "table", "thead", "tbody", "tfoot", "tr", "td", "th":
+		prs.checkImageAttribute(tagName, "background", attrs, attrsPositions, position)
+	case "v:fill", "v:image", "v:imagedata":
+		prs.checkImageAttribute(tagName, "src", attrs, attrsPositions, position)
+	case "image":
+		prs.checkImageAttribute(tagName, "href", attrs, attrsPositions, position)
+		prs.checkImageAttribute(tagName, "xlink:href", attrs, attrsPositions, position)
+	}
+}
+
+// checkCssImage find images in css url()
+func (prs *ParserEngine) checkCssImage(propertyKey, cssUrl string, position SourcePosition) {
+	propertyKey = strings.ToLower(propertyKey)
+	if propertyKey == "src" || propertyKey == "behavior" {
+		return // @font-face source and IE behaviors are not images
+	}
+
+	if cssUrlRe.MatchString(cssUrl) {
+		cssUrl = cssUrlRe.FindStringSubmatch(cssUrl)[1] // parse url from "url(img.path)"
+	}
+	prs.checkImageReference("css "+propertyKey, cssUrl, "", position)
+}
+
+func (prs *ParserEngine) sortImageReferences() {
+	// style tags processed in parallel
+	sort.SliceStable(prs.pr.Images, func(i, j int) bool {
+		return prs.pr.Images[i].Line < prs.pr.Images[j].Line
+	})
+}
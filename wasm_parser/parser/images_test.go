@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectImageFormat(t *testing.T) {
+	var tests = []struct {
+		imgUrl           string
+		typeAttr         string
+		wantFormat       string
+		wantFormatSource string
+	}{
+		{"data:image/webp;base64,UklGRhIAAABXRUJQVlA4", "", "webp", IMAGE_FORMAT_SOURCE_MIME},
+		{"data:image/svg+xml,%3Csvg%3E%3C/svg%3E", "image/png", "svg", IMAGE_FORMAT_SOURCE_MIME},
+		{"hero.jpg", "image/avif", "avif", IMAGE_FORMAT_SOURCE_TYPE},
+		{"https://images.example.com/hero.jpg?w=600&fm=webp", "", "webp", IMAGE_FORMAT_SOURCE_QUERY},
+		{"https://res.example.com/image/upload/f_avif,q_auto/hero.png", "", "avif", IMAGE_FORMAT_SOURCE_QUERY},
+		{"https://example.com/HERO.JPEG?v=2", "", "jpg", IMAGE_FORMAT_SOURCE_EXTENSION},
+		{"https://example.com/pixel", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.imgUrl, func(t *testing.T) {
+			format, formatSource := detectImageFormat(tt.imgUrl, tt.typeAttr)
+			if format != tt.wantFormat || formatSource != tt.wantFormatSource {
+				t.Errorf("detectImageFormat(%s, %s): got %s (%s), want %s (%s)", tt.imgUrl, tt.typeAttr, format, formatSource, tt.wantFormat, tt.wantFormatSource)
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLImageReferences(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.hero { background-image: url("https://cdn.example.com/hero.png?fm=avif"); }
+		@font-face { font-family: Brand; src: url("brand.woff2") format("woff2"); }
+	</style>
+</head>
+<body>
+	<table background="bg.gif"><tr><td background="cell.webp">
+		<picture>
+			<source type="image/avif" srcset="photo.jpg 1x, photo@2x.jpg 2x">
+			<img src="photo.jpg" srcset="https://res.example.com/f_webp,q_auto/photo.jpg 2x" alt="">
+		</picture>
+		<video poster="poster.apng"></video>
+		<img src="data:image/webp;base64,UklGRhIAAABXRUJQVlA4IAYAAAAwAQCdASoBAAEAAQAcJaQAA3AA/v3AgAA=" alt="">
+	</td></tr></table>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	got := make([][4]interface{}, 0, len(report.Images))
+	for _, image := range report.Images {
+		got = append(got, [4]interface{}{image.Line, image.Source, image.Format, image.Snippet})
+	}
+
+	want := [][4]interface{}{
+		{4, "css background-image", "avif", `background-image: url("https://cdn.example.com/hero.png?fm=avif")`},
+		{9, "table[background]", "gif", `background="bg.gif"`},
+		{9, "td[background]", "webp", `background="cell.webp"`},
+		{11, "source[srcset]", "avif", `srcset="photo.jpg 1x, photo@2x.jpg 2x"`},
+		{11, "source[srcset]", "avif", `srcset="photo.jpg 1x, photo@2x.jpg 2x"`},
+		{12, "img[src]", "jpg", `src="photo.jpg"`},
+		{12, "img[srcset]", "webp", `srcset="https://res.example.com/f_webp,q_auto/photo.jpg 2x"`},
+		{14, "video[poster]", "apng", `poster="poster.apng"`},
+		{15, "img[src]", "webp", `src="data:image/webp;base64,UklGRhIAAABXRUJQVlA4IAYAAAAwAQCdASoBAAEAAQAcJaQAA3AA/v3AgAA="`},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Images: got %v, want %v", got, want)
+	}
+
+	var tests = []struct {
+		checkType string
+		got       map[int]bool
+		want      map[int]bool
+	}{
+		{"ImgFormats avif", report.ImgFormats["avif"].Lines, map[int]bool{4: true, 11: true}},
+		{"ImgFormats webp", report.ImgFormats["webp"].Lines, map[int]bool{9: true, 12: true, 15: true}},
+		{"ImgFormats apng", report.ImgFormats["apng"].Lines, map[int]bool{14: true}},
+		{"ImgFormats base64", report.ImgFormats["base64"].Lines, map[int]bool{15: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	LIMIT_INCLUDE_DEPTH = 20
+	// name of main document in errors, if file name is not provided
+	INCLUDE_DOCUMENT_NAME = "(document)"
+)
+
+var (
+	// <!-- include "header.html" --> and {{> header}}
+	includeDirectiveRe = regexp.MustCompile(`<!--\s*include\s+["']([^"']+)["']\s*-->|{{>\s*([\w./-]+)\s*}}`)
+	// extensions, which tried for partials without extension
+	includePartialExtensions = []string{".html", ".hbs", ".mustache"}
+)
+
+// SourceMapSegment map consecutive lines of flattened html to lines of original file
+type SourceMapSegment struct {
+	FlatLine int    `json:"flat_line"` // first line in flattened html
+	Lines    int    `json:"lines"`
+	File     string `json:"file"` // partial or file name of main document
+	Line     int    `json:"line"` // first line in file
+}
+
+type lineOrigin struct {
+	file string
+	line int
+}
+
+// includeResolver flatten document with includes and remember origin of each line.
+// Same resolver is in inliner module, library modules do not depend on each other
+type includeResolver struct {
+	fsys      fs.FS
+	out       bytes.Buffer
+	lines     []lineOrigin
+	lineBlank bool
+	stack     []string
+}
+
+func (r *includeResolver) write(content []byte, file string, line int) {
+	for _, ch := range content {
+		atLineStart := r.out.Len() == 0 || r.out.Bytes()[r.out.Len()-1] == '\n'
+		isSpace := strings.IndexByte(WHITESPACE, ch) >= 0
+		if atLineStart {
+			r.lines = append(r.lines, lineOrigin{file: file, line: line})
+			r.lineBlank = true
+		} else if r.lineBlank && !isSpace {
+			// indentation before include belongs to partial line
+			r.lines[len(r.lines)-1] = lineOrigin{file: file, line: line}
+		}
+		if !isSpace {
+			r.lineBlank = false
+		}
+
+		r.out.WriteByte(ch)
+		if ch == '\n' {
+			line += 1
+		}
+	}
+}
+
+// includePath return path of include in fsys: names are relative to directory of including file,
+// names with leading "/" are relative to fsys root
+func includePath(name, includingFile string) string {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(strings.TrimPrefix(name, "/"))
+	}
+	return path.Join(path.Dir(includingFile), name)
+}
+
+// readPartial read file by include name, extensions tried for handlebars style partials
+func (r *includeResolver) readPartial(name, includingFile string, isPartial bool) (string, []byte, error) {
+	name = includePath(name, includingFile)
+	candidates := []string{name}
+	if isPartial && len(path.Ext(name)) == 0 {
+		for _, ext := range includePartialExtensions {
+			candidates = append(candidates, name+ext)
+		}
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		var content []byte
+		content, err = fs.ReadFile(r.fsys, candidate)
+		if err == nil {
+			return candidate, content, nil
+		}
+	}
+	return name, nil, fmt.Errorf("include %q: %w", name, err)
+}
+
+func (r *includeResolver) resolve(content []byte, file string) error {
+	if len(r.stack) >= LIMIT_INCLUDE_DEPTH {
+		return fmt.Errorf("include depth limit exceeded: %s", strings.Join(r.stack, " -> "))
+	}
+
+	line := 1
+	cursor := 0
+	for _, match := range includeDirectiveRe.FindAllSubmatchIndex(content, -1) {
+		r.write(content[cursor:match[0]], file, line)
+		line += bytes.Count(content[cursor:match[0]], []byte("\n"))
+
+		name, isPartial := "", false
+		if match[2] >= 0 {
+			name = string(content[match[2]:match[3]])
+		} else {
+			name, isPartial = string(content[match[4]:match[5]]), true
+		}
+		partialFile, partialContent, err := r.readPartial(name, file, isPartial)
+		if err != nil {
+			return err
+		}
+		for _, parent := range r.stack {
+			if parent == partialFile {
+				return fmt.Errorf("include cycle: %s -> %s", strings.Join(r.stack, " -> "), partialFile)
+			}
+		}
+
+		r.stack = append(r.stack, partialFile)
+		if err := r.resolve(partialContent, partialFile); err != nil {
+			return err
+		}
+		r.stack = r.stack[:len(r.stack)-1]
+
+		line += bytes.Count(content[match[0]:match[1]], []byte("\n"))
+		cursor = match[1]
+	}
+	r.write(content[cursor:], file, line)
+	return nil
+}
+
+// resolveIncludes replace include directives with content of files from fsys,
+// return flattened document and origin of each its line. Includes of main document are relative to fsys root
+func resolveIncludes(document []byte, fsys fs.FS, fileName string) ([]byte, []lineOrigin, error) {
+	if len(fileName) == 0 {
+		fileName = INCLUDE_DOCUMENT_NAME
+	}
+	resolver := &includeResolver{fsys: fsys, stack: []string{fileName}}
+	if err := resolver.resolve(document, ""); err != nil {
+		return nil, nil, err
+	}
+	return resolver.out.Bytes(), resolver.lines, nil
+}
+
+// buildSourceMap compress line origins into segments of consecutive lines
+func buildSourceMap(lines []lineOrigin) []SourceMapSegment {
+	var segments []SourceMapSegment
+	for i, origin := range lines {
+		if len(segments) > 0 {
+			last := &segments[len(segments)-1]
+			if last.File == origin.file && last.Line+last.Lines == origin.line {
+				last.Lines += 1
+				continue
+			}
+		}
+		segments = append(segments, SourceMapSegment{FlatLine: i + 1, Lines: 1, File: origin.file, Line: origin.line})
+	}
+	return segments
+}
+
+// originFile return partial, which produced line of flattened html, or file name of main document.
+// Empty if includes were not resolved
+func (prs *ParserEngine) originFile(line int) string {
+	if len(prs.includeLines) == 0 {
+		return ""
+	}
+	if line > 0 && line <= len(prs.includeLines) && len(prs.includeLines[line-1].file) > 0 {
+		return prs.includeLines[line-1].file
+	}
+	return prs.options.FileName
+}
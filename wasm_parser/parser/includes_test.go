@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"header.html":          {Data: []byte("<table>\n<tr><td>Logo</td></tr>\n</table>\n")},
+		"partials/footer.hbs":  {Data: []byte("<p>Footer</p>")},
+		"partials/layout.html": {Data: []byte("{{> social}}\n<!-- include \"../header.html\" -->")},
+		"partials/social.hbs":  {Data: []byte("<p>Social</p>\n")},
+		"partials/rooted.html": {Data: []byte(`<!-- include "/header.html" -->`)},
+		"a.html":               {Data: []byte(`<!-- include "b.html" -->`)},
+		"b.html":               {Data: []byte(`{{> a.html}}`)},
+	}
+
+	var tests = []struct {
+		name      string
+		document  string
+		want      string
+		wantLines []lineOrigin
+		wantErr   string
+	}{
+		{
+			"include and partial",
+			"<body>\n  <!-- include \"header.html\" -->\n{{> partials/footer}}\n</body>",
+			"<body>\n  <table>\n<tr><td>Logo</td></tr>\n</table>\n\n<p>Footer</p>\n</body>",
+			[]lineOrigin{{"", 1}, {"header.html", 1}, {"header.html", 2}, {"header.html", 3}, {"", 2}, {"partials/footer.hbs", 1}, {"", 4}},
+			"",
+		},
+		{
+			"nested partials relative to including file",
+			`<!-- include "partials/layout.html" -->`,
+			"<p>Social</p>\n\n<table>\n<tr><td>Logo</td></tr>\n</table>\n",
+			[]lineOrigin{{"partials/social.hbs", 1}, {"partials/layout.html", 1}, {"header.html", 1}, {"header.html", 2}, {"header.html", 3}},
+			"",
+		},
+		{
+			"nested include from root",
+			`<!-- include "partials/rooted.html" -->`,
+			"<table>\n<tr><td>Logo</td></tr>\n</table>\n",
+			[]lineOrigin{{"header.html", 1}, {"header.html", 2}, {"header.html", 3}},
+			"",
+		},
+		{
+			"cycle",
+			`<!-- include "a.html" -->`,
+			"",
+			nil,
+			"include cycle: (document) -> a.html -> b.html -> a.html",
+		},
+		{
+			"missing file",
+			`<!-- include "missing.html" -->`,
+			"",
+			nil,
+			`include "missing.html"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lines, err := resolveIncludes([]byte(tt.document), fsys, "")
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveIncludes() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines: got %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"header.html": {Data: []byte("<div>\n  <div style=\"display: flex\">Logo</div>\n</div>\n")},
+	}
+	document := "<html><body>\n<!-- include \"header.html\" -->\n<div style=\"display: grid\"></div>\n</body></html>"
+
+	report, err := ReportFromHTMLWithOptions([]byte(document), ParserOptions{Includes: fsys, FileName: "layout.html"})
+	if err != nil {
+		t.Fatalf("ReportFromHTMLWithOptions() error = %v", err)
+	}
+
+	type origin struct {
+		file string
+		line int
+	}
+	got := make(map[string]origin)
+	for value, item := range report.CssProperties["display"] {
+		if len(value) > 0 {
+			got[value] = origin{item.Occurrences[0].File, item.Occurrences[0].Line}
+		}
+	}
+	want := map[string]origin{
+		"flex": {"header.html", 2},
+		"grid": {"layout.html", 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	wantSourceMap := []SourceMapSegment{
+		{FlatLine: 1, Lines: 1, File: "layout.html", Line: 1},
+		{FlatLine: 2, Lines: 3, File: "header.html", Line: 1},
+		{FlatLine: 5, Lines: 3, File: "layout.html", Line: 2},
+	}
+	if !reflect.DeepEqual(report.SourceMap, wantSourceMap) {
+		t.Errorf("source map: got %+v, want %+v", report.SourceMap, wantSourceMap)
+	}
+}
@@ -0,0 +1,265 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// microsoft office markup categories
+const (
+	MSO_VML_ELEMENTS_TYPE         = "vml_elements"
+	MSO_OFFICE_ELEMENTS_TYPE      = "office_elements"
+	MSO_NAMESPACES_TYPE           = "office_namespaces"
+	MSO_PROPERTIES_TYPE           = "mso_properties"
+	MSO_CONDITIONAL_COMMENTS_TYPE = "conditional_comments"
+	MSO_ISSUES_TYPE               = "issues"
+)
+
+// microsoft office markup issues
+const (
+	MSO_ISSUE_VML_OUTSIDE_CONDITIONAL    = "vml_outside_conditional"
+	MSO_ISSUE_OFFICE_OUTSIDE_CONDITIONAL = "office_outside_conditional"
+	MSO_ISSUE_MISSING_VML_NAMESPACE      = "missing_vml_namespace"
+	MSO_ISSUE_MISSING_OFFICE_NAMESPACE   = "missing_office_namespace"
+	MSO_ISSUE_MISSING_WORD_NAMESPACE     = "missing_word_namespace"
+	MSO_ISSUE_INVALID_NAMESPACE_URI      = "invalid_namespace_uri"
+	MSO_ISSUE_UNCLOSED_CONDITIONAL       = "unclosed_conditional_comment"
+)
+
+var (
+	conditionalCommentRe       = regexp.MustCompile(`(?is)^\[if\s+([^\]]+)\]>`)
+	conditionalCommentEndRe    = regexp.MustCompile(`(?i)<!\[endif\]$`)
+	conditionalCommentRevealRe = regexp.MustCompile(`(?is)^\[if\s+([^\]]+)\]><!$`)
+	msoConditionRe             = regexp.MustCompile(`(?i)\bmso\b`)
+	msoNegativeConditionRe     = regexp.MustCompile(`(?i)(!|\bnot\s+)\(?\s*mso\b`)
+
+	msoNamespacesURI = map[string]string{
+		"v": "urn:schemas-microsoft-com:vml",
+		"o": "urn:schemas-microsoft-com:office:office",
+		"w": "urn:schemas-microsoft-com:office:word",
+		"x": "urn:schemas-microsoft-com:office:excel",
+		"m": "http://schemas.microsoft.com/office/2004/12/omml",
+	}
+
+	msoNamespacesIssues = map[string]string{
+		"v": MSO_ISSUE_MISSING_VML_NAMESPACE,
+		"o": MSO_ISSUE_MISSING_OFFICE_NAMESPACE,
+		"w": MSO_ISSUE_MISSING_WORD_NAMESPACE,
+	}
+)
+
+// built-in catalog of Microsoft Office markup, caniemail do not cover it
+var msoRulesDB = map[string]map[string]interface{}{
+	MSO_VML_ELEMENTS_TYPE: {
+		"":             makeIssueRule("VML element", "Vector Markup Language element. Rendered only by Outlook on Windows (Word rendering engine), other clients ignore it.", SEVERITY_INFO),
+		"v:arc":        makeIssueRule("v:arc", "VML arc shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:background": makeIssueRule("v:background", "VML document background. Used for full width background images in Outlook on Windows.", SEVERITY_INFO),
+		"v:curve":      makeIssueRule("v:curve", "VML bezier curve shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:f":          makeIssueRule("v:f", "VML formula, child of v:formulas. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:fill":       makeIssueRule("v:fill", "VML fill of the parent shape, often used for background images. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:formulas":   makeIssueRule("v:formulas", "VML formulas container. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:group":      makeIssueRule("v:group", "VML shapes group. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:h":          makeIssueRule("v:h", "VML handle, child of v:handles. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:handles":    makeIssueRule("v:handles", "VML handles container. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:image":      makeIssueRule("v:image", "VML image shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:imagedata":  makeIssueRule("v:imagedata", "VML image data of the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:line":       makeIssueRule("v:line", "VML line shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:oval":       makeIssueRule("v:oval", "VML oval shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:path":       makeIssueRule("v:path", "VML path of the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:polyline":   makeIssueRule("v:polyline", "VML polyline shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:rect":       makeIssueRule("v:rect", "VML rectangle shape, often used for background images. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:roundrect":  makeIssueRule("v:roundrect", "VML rounded rectangle shape, often used for bulletproof buttons. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:shadow":     makeIssueRule("v:shadow", "VML shadow of the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:shape":      makeIssueRule("v:shape", "VML generic shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:shapetype":  makeIssueRule("v:shapetype", "VML shape template. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:stroke":     makeIssueRule("v:stroke", "VML stroke of the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:textbox":    makeIssueRule("v:textbox", "VML text container inside the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"v:textpath":   makeIssueRule("v:textpath", "VML text along the path of the parent shape. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+	},
+	MSO_OFFICE_ELEMENTS_TYPE: {
+		"":                         makeIssueRule("Office element", "Microsoft Office XML element. Understood only by Outlook on Windows (Word rendering engine), other clients ignore it.", SEVERITY_INFO),
+		"o:allowpng":               makeIssueRule("o:AllowPNG", "Allow PNG images in Outlook on Windows. Should be placed inside o:OfficeDocumentSettings.", SEVERITY_INFO),
+		"o:lock":                   makeIssueRule("o:lock", "Lock aspect ratio or other properties of the parent VML shape in Outlook on Windows.", SEVERITY_INFO),
+		"o:officedocumentsettings": makeIssueRule("o:OfficeDocumentSettings", "Office document settings, should be placed inside <xml> in conditional comment in <head>.", SEVERITY_INFO),
+		"o:p":                      makeIssueRule("o:p", "Office paragraph, generated by Word. Rendered only by Outlook on Windows.", SEVERITY_INFO),
+		"o:pixelsperinch":          makeIssueRule("o:PixelsPerInch", "Fix DPI scaling in Outlook on Windows. Should be placed inside o:OfficeDocumentSettings.", SEVERITY_INFO),
+		"w:anchorlock":             makeIssueRule("w:anchorlock", "Prevent editing of VML shape content (used in bulletproof buttons) in Outlook on Windows.", SEVERITY_INFO),
+	},
+	MSO_NAMESPACES_TYPE: {
+		"":        makeIssueRule("Office XML namespace", "XML namespace declaration for Microsoft Office markup.", SEVERITY_INFO),
+		"xmlns:m": makeIssueRule("xmlns:m", "Office Math Markup Language namespace.", SEVERITY_INFO),
+		"xmlns:o": makeIssueRule("xmlns:o", "Microsoft Office namespace, required for o: elements (urn:schemas-microsoft-com:office:office).", SEVERITY_INFO),
+		"xmlns:v": makeIssueRule("xmlns:v", "Vector Markup Language namespace, required for v: elements (urn:schemas-microsoft-com:vml).", SEVERITY_INFO),
+		"xmlns:w": makeIssueRule("xmlns:w", "Microsoft Word namespace, required for w: elements (urn:schemas-microsoft-com:office:word).", SEVERITY_INFO),
+		"xmlns:x": makeIssueRule("xmlns:x", "Microsoft Excel namespace.", SEVERITY_INFO),
+	},
+	MSO_PROPERTIES_TYPE: {
+		"":                        makeIssueRule("mso-* property", "Microsoft Office CSS property. Understood only by Outlook on Windows (Word rendering engine), other clients ignore it.", SEVERITY_INFO),
+		"mso-ansi-font-size":      makeIssueRule("mso-ansi-font-size", "Font size for ANSI characters in Outlook on Windows.", SEVERITY_INFO),
+		"mso-border-alt":          makeIssueRule("mso-border-alt", "Border, which used by Outlook on Windows instead of border property.", SEVERITY_INFO),
+		"mso-bidi-font-size":      makeIssueRule("mso-bidi-font-size", "Font size for right-to-left text in Outlook on Windows.", SEVERITY_INFO),
+		"mso-color-alt":           makeIssueRule("mso-color-alt", "Text color, which used by Outlook on Windows instead of color property.", SEVERITY_INFO),
+		"mso-element":             makeIssueRule("mso-element", "Word element type, generated by Word.", SEVERITY_INFO),
+		"mso-font-width":          makeIssueRule("mso-font-width", "Horizontal font scaling in Outlook on Windows.", SEVERITY_INFO),
+		"mso-height-rule":         makeIssueRule("mso-height-rule", "How Outlook on Windows treat height: exactly or at-least.", SEVERITY_INFO),
+		"mso-hide":                makeIssueRule("mso-hide", "Hide element in Outlook on Windows (mso-hide: all).", SEVERITY_INFO),
+		"mso-line-height-rule":    makeIssueRule("mso-line-height-rule", "How Outlook on Windows treat line-height: exactly or at-least.", SEVERITY_INFO),
+		"mso-margin-bottom-alt":   makeIssueRule("mso-margin-bottom-alt", "Bottom margin, which used by Outlook on Windows instead of margin-bottom property.", SEVERITY_INFO),
+		"mso-margin-top-alt":      makeIssueRule("mso-margin-top-alt", "Top margin, which used by Outlook on Windows instead of margin-top property.", SEVERITY_INFO),
+		"mso-padding-alt":         makeIssueRule("mso-padding-alt", "Padding, which used by Outlook on Windows instead of padding property.", SEVERITY_INFO),
+		"mso-style-priority":      makeIssueRule("mso-style-priority", "Style priority, generated by Word.", SEVERITY_INFO),
+		"mso-table-lspace":        makeIssueRule("mso-table-lspace", "Left spacing around tables in Outlook on Windows.", SEVERITY_INFO),
+		"mso-table-rspace":        makeIssueRule("mso-table-rspace", "Right spacing around tables in Outlook on Windows.", SEVERITY_INFO),
+		"mso-text-raise":          makeIssueRule("mso-text-raise", "Vertical text offset in Outlook on Windows.", SEVERITY_INFO),
+		"mso-width-percent":       makeIssueRule("mso-width-percent", "Width in percents (1000 = 100%) for VML shapes in Outlook on Windows.", SEVERITY_INFO),
+		"mso-width-relative":      makeIssueRule("mso-width-relative", "Width reference for mso-width-percent in Outlook on Windows.", SEVERITY_INFO),
+		"mso-fit-shape-to-text":   makeIssueRule("mso-fit-shape-to-text", "Resize VML shape to fit text in Outlook on Windows.", SEVERITY_INFO),
+		"mso-generic-font-family": makeIssueRule("mso-generic-font-family", "Generic font family for fallback in Outlook on Windows.", SEVERITY_INFO),
+	},
+	MSO_CONDITIONAL_COMMENTS_TYPE: {
+		"": makeIssueRule("Conditional comment", "Conditional comment, processed only by Outlook on Windows. Other clients treat hidden content as comment.", SEVERITY_INFO),
+	},
+	MSO_ISSUES_TYPE: {
+		MSO_ISSUE_VML_OUTSIDE_CONDITIONAL:    makeIssueRule("VML outside of conditional comment", "VML element is not wrapped in <!--[if mso]> conditional comment, so other email clients can render its content or break layout.", SEVERITY_WARNING),
+		MSO_ISSUE_OFFICE_OUTSIDE_CONDITIONAL: makeIssueRule("Office element outside of conditional comment", "Office element is not wrapped in <!--[if mso]> conditional comment, so other email clients can render its content.", SEVERITY_WARNING),
+		MSO_ISSUE_MISSING_VML_NAMESPACE:      makeIssueRule("Missing xmlns:v declaration", "VML element used, but xmlns:v=\"urn:schemas-microsoft-com:vml\" is not declared, so Outlook on Windows will not render it.", SEVERITY_WARNING),
+		MSO_ISSUE_MISSING_OFFICE_NAMESPACE:   makeIssueRule("Missing xmlns:o declaration", "Office element used, but xmlns:o=\"urn:schemas-microsoft-com:office:office\" is not declared.", SEVERITY_WARNING),
+		MSO_ISSUE_MISSING_WORD_NAMESPACE:     makeIssueRule("Missing xmlns:w declaration", "Word element used, but xmlns:w=\"urn:schemas-microsoft-com:office:word\" is not declared.", SEVERITY_WARNING),
+		MSO_ISSUE_INVALID_NAMESPACE_URI:      makeIssueRule("Invalid Office namespace URI", "Office XML namespace declared with unexpected URI, so Outlook on Windows will not recognize elements with this prefix.", SEVERITY_WARNING),
+		MSO_ISSUE_UNCLOSED_CONDITIONAL:       makeIssueRule("Unclosed conditional comment", "Conditional comment opened with <!--[if ...]><!--> but never closed with <!--<![endif]-->.", SEVERITY_WARNING),
+	},
+}
+
+func isMsoCondition(condition string) bool {
+	return msoConditionRe.MatchString(condition) && !msoNegativeConditionRe.MatchString(condition)
+}
+
+func (prs *ParserEngine) saveToReportMsoMarkup(category, itemKey string, position SourcePosition, ruleMsoData interface{}) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if prs.pr.MsoMarkup == nil {
+		prs.pr.MsoMarkup = make(map[string]map[string]ReportContainer)
+	}
+	prs.pr.MsoMarkup[category] = addToReportItems(prs.pr.MsoMarkup[category], itemKey, position, ruleMsoData)
+}
+
+func (prs *ParserEngine) checkMsoItem(category, itemKey string, position SourcePosition) {
+	if categoryData, ok := msoRulesDB[category]; ok {
+		if ruleData, ok := categoryData[itemKey]; ok {
+			prs.saveToReportMsoMarkup(category, itemKey, position, ruleData)
+		} else if ruleData, ok := categoryData[""]; ok {
+			prs.saveToReportMsoMarkup(category, itemKey, position, ruleData)
+		}
+	}
+}
+
+func (prs *ParserEngine) checkMsoProperty(propertyKey string, position SourcePosition) {
+	if strings.HasPrefix(propertyKey, "mso-") {
+		prs.checkMsoItem(MSO_PROPERTIES_TYPE, propertyKey, position)
+	}
+}
+
+func (prs *ParserEngine) checkMsoMarkup(tagName string, attrs []html.Attribute, position SourcePosition) {
+	tagName = strings.ToLower(tagName)
+
+	for _, att := range attrs {
+		attrKey := strings.ToLower(strings.Trim(att.Key, WHITESPACE))
+		if !strings.HasPrefix(attrKey, "xmlns:") {
+			continue
+		}
+
+		prefix := strings.TrimPrefix(attrKey, "xmlns:")
+		if expectedURI, ok := msoNamespacesURI[prefix]; ok {
+			prs.checkMsoItem(MSO_NAMESPACES_TYPE, attrKey, position)
+			prs.msoNamespaces[prefix] = true
+
+			if !strings.EqualFold(strings.Trim(att.Val, WHITESPACE), expectedURI) {
+				prs.checkMsoItem(MSO_ISSUES_TYPE, MSO_ISSUE_INVALID_NAMESPACE_URI, position)
+			}
+		}
+	}
+
+	prefix, _, found := strings.Cut(tagName, ":")
+	if !found {
+		return
+	}
+
+	inMsoConditional := isMsoCondition(prs.conditionalComment)
+
+	switch prefix {
+	case "v":
+		prs.checkMsoItem(MSO_VML_ELEMENTS_TYPE, tagName, position)
+		if !inMsoConditional {
+			prs.checkMsoItem(MSO_ISSUES_TYPE, MSO_ISSUE_VML_OUTSIDE_CONDITIONAL, position)
+		}
+	case "o", "w":
+		prs.checkMsoItem(MSO_OFFICE_ELEMENTS_TYPE, tagName, position)
+		if !inMsoConditional && tagName != "o:p" { // Word put o:p everywhere and it is harmless
+			prs.checkMsoItem(MSO_ISSUES_TYPE, MSO_ISSUE_OFFICE_OUTSIDE_CONDITIONAL, position)
+		}
+	default:
+		return
+	}
+
+	if _, ok := prs.msoUsages[prefix]; !ok {
+		prs.msoUsages[prefix] = position
+	}
+}
+
+func (prs *ParserEngine) processConditionalComment(raw []byte, tagOffset, tagLine int) {
+	comment := string(raw)
+	comment = strings.TrimPrefix(comment, "<!--")
+	comment = strings.TrimSuffix(comment, "-->")
+	prefixLen := len("<!--")
+	position := SourcePosition{Line: tagLine}.withSnippet(string(raw))
+
+	if conditionalCommentRevealRe.MatchString(comment) { // <!--[if !mso]><!-->
+		condition := conditionalCommentRevealRe.FindStringSubmatch(comment)[1]
+		prs.checkMsoItem(MSO_CONDITIONAL_COMMENTS_TYPE, "if "+strings.ToLower(strings.Join(strings.Fields(condition), " ")), position)
+		prs.conditionalComment = condition
+		prs.conditionalCommentPosition = position
+		return
+	}
+
+	if conditionalCommentEndRe.MatchString(comment) && !conditionalCommentRe.MatchString(comment) { // <!--<![endif]-->
+		prs.conditionalComment = ""
+		prs.conditionalCommentPosition = SourcePosition{}
+		return
+	}
+
+	if !conditionalCommentRe.MatchString(comment) { // regular comment
+		return
+	}
+
+	// <!--[if mso]>...<![endif]-->
+	match := conditionalCommentRe.FindStringSubmatchIndex(comment)
+	condition := comment[match[2]:match[3]]
+	position = position.withSnippet("<!--" + comment[:match[1]])
+	prs.checkMsoItem(MSO_CONDITIONAL_COMMENTS_TYPE, "if "+strings.ToLower(strings.Join(strings.Fields(condition), " ")), position)
+
+	content := conditionalCommentEndRe.ReplaceAllString(comment[match[1]:], "")
+	if len(strings.Trim(content, WHITESPACE)) == 0 {
+		return
+	}
+
+	prevCondition, prevHidden := prs.conditionalComment, prs.isHiddenConditional
+	prs.conditionalComment, prs.isHiddenConditional = condition, true
+	// errors inside of comment do not break parsing of document
+	_ = prs.processHtmlContent([]byte(content), tagOffset+prefixLen+match[1], tagLine)
+	prs.conditionalComment, prs.isHiddenConditional = prevCondition, prevHidden
+}
+
+func (prs *ParserEngine) checkMsoNamespaces() {
+	if len(prs.conditionalComment) > 0 {
+		prs.checkMsoItem(MSO_ISSUES_TYPE, MSO_ISSUE_UNCLOSED_CONDITIONAL, prs.conditionalCommentPosition)
+	}
+
+	for prefix, position := range prs.msoUsages {
+		if issue, ok := msoNamespacesIssues[prefix]; ok && !prs.msoNamespaces[prefix] {
+			prs.checkMsoItem(MSO_ISSUES_TYPE, issue, position)
+		}
+	}
+}
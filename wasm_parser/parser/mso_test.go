@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLMsoMarkup(t *testing.T) {
+	html := `<html xmlns:o="urn:schemas-microsoft-com:office:office">
+<head>
+	<!--[if gte mso 9]>
+	<xml>
+		<o:OfficeDocumentSettings>
+			<o:AllowPNG/>
+		</o:OfficeDocumentSettings>
+	</xml>
+	<![endif]-->
+	<style>
+		td { mso-line-height-rule: exactly; }
+	</style>
+</head>
+<body>
+	<!--[if mso]>
+	<v:roundrect href="https://example.com" style="height:40px;v-text-anchor:middle;width:200px;" arcsize="10%" stroke="f" fillcolor="#d62828">
+		<w:anchorlock/>
+		<center>Button</center>
+	</v:roundrect>
+	<![endif]-->
+	<!--[if !mso]><!-->
+	<div style="mso-hide:all">Not Outlook</div>
+	<!--<![endif]-->
+	<v:rect style="width:600px;"><v:fill type="tile" src="bg.png" /></v:rect>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	var tests = []struct {
+		checkType string
+		got       map[int]bool
+		want      map[int]bool
+	}{
+		{"MsoMarkup office_namespaces xmlns:o", report.MsoMarkup["office_namespaces"]["xmlns:o"].Lines, map[int]bool{1: true}},
+		{"MsoMarkup conditional_comments if gte mso 9", report.MsoMarkup["conditional_comments"]["if gte mso 9"].Lines, map[int]bool{3: true}},
+		{"MsoMarkup conditional_comments if !mso", report.MsoMarkup["conditional_comments"]["if !mso"].Lines, map[int]bool{21: true}},
+		{"MsoMarkup office_elements o:officedocumentsettings", report.MsoMarkup["office_elements"]["o:officedocumentsettings"].Lines, map[int]bool{5: true}},
+		{"MsoMarkup office_elements o:allowpng", report.MsoMarkup["office_elements"]["o:allowpng"].Lines, map[int]bool{6: true}},
+		{"MsoMarkup office_elements w:anchorlock", report.MsoMarkup["office_elements"]["w:anchorlock"].Lines, map[int]bool{17: true}},
+		{"MsoMarkup vml_elements v:roundrect", report.MsoMarkup["vml_elements"]["v:roundrect"].Lines, map[int]bool{16: true}},
+		{"MsoMarkup vml_elements v:rect", report.MsoMarkup["vml_elements"]["v:rect"].Lines, map[int]bool{24: true}},
+		{"MsoMarkup mso_properties mso-line-height-rule", report.MsoMarkup["mso_properties"]["mso-line-height-rule"].Lines, map[int]bool{11: true}},
+		{"MsoMarkup mso_properties mso-hide", report.MsoMarkup["mso_properties"]["mso-hide"].Lines, map[int]bool{22: true}},
+		{"MsoMarkup issues vml_outside_conditional", report.MsoMarkup["issues"]["vml_outside_conditional"].Lines, map[int]bool{24: true}},
+		{"MsoMarkup issues missing_vml_namespace", report.MsoMarkup["issues"]["missing_vml_namespace"].Lines, map[int]bool{16: true}},
+		{"MsoMarkup issues missing_word_namespace", report.MsoMarkup["issues"]["missing_word_namespace"].Lines, map[int]bool{17: true}},
+		{"MsoMarkup issues missing_office_namespace", report.MsoMarkup["issues"]["missing_office_namespace"].Lines, nil},
+	}
+
+	for _, tt := range tests {
+		testname := tt.checkType
+		t.Run(testname, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+}
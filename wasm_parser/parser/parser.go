@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/url"
-	"path/filepath"
+	"io/fs"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -105,9 +105,14 @@ var rulesDB CaniuseDB
 // result structure begin
 
 type ReportContainer struct {
-	Rules     interface{}  `json:"rules"`
-	Lines     map[int]bool `json:"lines"`
-	MoreLines bool         `json:"more_lines"`
+	Rules       interface{}      `json:"rules"`
+	Lines       map[int]bool     `json:"lines"`
+	MoreLines   bool             `json:"more_lines"`
+	Occurrences []SourcePosition `json:"occurrences"`
+	// all usages wrapped by at-rules or media of style tag (progressive enhancement)
+	OnlyConditional bool `json:"only_conditional"`
+	// all usages have fallback (progressive enhancement)
+	OnlyGuarded bool `json:"only_guarded"`
 }
 
 type ParseReport struct {
@@ -124,11 +129,35 @@ type ParseReport struct {
 	CssVariables        ReportContainer                       `json:"css_variables"`
 	CssImportant        ReportContainer                       `json:"css_important"`
 	Html5Doctype        ReportContainer                       `json:"html5_doctype"`
+	MsoMarkup           map[string]map[string]ReportContainer `json:"mso_markup"`
+	UnknownNames        map[string]map[string]ReportContainer `json:"unknown_names"`
+	HtmlDiagnostics     []HtmlDiagnostic                      `json:"html_diagnostics"`
+	HtmlDiagnosticsMore bool                                  `json:"html_diagnostics_more"`
+	HeadAudit           map[string]ReportContainer            `json:"head_audit"`
+	StyleBlocks         []StyleBlock                          `json:"style_blocks"`
+	Images              []ImageReference                      `json:"images"`
+	ImagesMore          bool                                  `json:"images_more"`
+	CssVariablesGraph   CssVariablesGraph                     `json:"css_variables_graph"`
+	Fonts               FontsReport                           `json:"fonts"`
+	ColorPalette        ColorPalette                          `json:"color_palette"`
+	DarkMode            map[string]ReportContainer            `json:"dark_mode"`
+	Accessibility       map[string]ReportContainer            `json:"accessibility"`
+	Contrast            ContrastReport                        `json:"contrast"`
+	Responsive          map[string]ReportContainer            `json:"responsive"`
+	TableLayout         TableLayout                           `json:"table_layout"`
+	TableIssues         map[string]ReportContainer            `json:"table_issues"`
+	Gmail               GmailReport                           `json:"gmail"`
+	GmailIssues         map[string]ReportContainer            `json:"gmail_issues"`
+	SourceMap           []SourceMapSegment                    `json:"source_map"`
+	FileName            string                                `json:"file_name"`
+	Fragment            bool                                  `json:"fragment"`
 }
 
 // result structure end
 
 type ParserEngine struct {
+	// document itself
+	document []byte
 	// array for bytes -> lines
 	bytesToLine []int
 	// group for parallel processing
@@ -138,41 +167,151 @@ type ParserEngine struct {
 	// report itself
 	pr ParseReport
 	// parse time states
-	isStyleTagOpen  bool
-	styleTagContent string
-	styleTagLine    int
+	isStyleTagOpen   bool
+	styleTagContent  string
+	styleTagOffset   int // byte offset of style content in document
+	styleTagMedia    string
+	styleTagPosition SourcePosition
+	styleTagLocation string
+	// microsoft office markup states
+	conditionalComment         string
+	conditionalCommentPosition SourcePosition
+	msoNamespaces              map[string]bool
+	msoUsages                  map[string]SourcePosition
+	isHiddenConditional        bool
+	ghostTablesDepth           int
+	ghostContentDepth          int  // depth of visible elements inside of ghost table
+	isGhostWrapped             bool // current element wrapped by ghost table
+	// html structure states
+	htmlTagsStack []openHtmlTag
+	// email head states
+	headAudit headAuditState
+	// css variables states, all definitions by name (report keeps limited list)
+	cssVariableDefinitions map[string][]CssVariableDefinition
+	// fonts states
+	fontStacksIndex map[string]int
+	// colours states
+	colorPaletteIndex map[string]int
+	// dark mode states
+	darkMode darkModeState
+	// accessibility states
+	accessibility accessibilityState
+	// responsive layout states
+	responsive responsiveState
+	// nested tables states
+	tableLayout tableLayoutState
+	// conditional sections of template language
+	templates templateState
+	// origin of each line, if includes resolved
+	includeLines []lineOrigin
+	// configuration
+	options ParserOptions
+}
+
+type ParserOptions struct {
+	// receive html tokens, css grammar events and rules lookups, disabled if nil
+	Trace TraceSink
+	// html is partial (header, footer, module), document level checks are skipped
+	Fragment bool
+	// logical name of file with html, returned in report
+	FileName string
+	// position of html start in original file, lines and columns in report are shifted by it
+	BaseLine   int
+	BaseColumn int
+	// template root for <!-- include "file.html" --> and {{> partial}} directives, disabled if nil
+	Includes fs.FS
+	// approved colours, other colours in email reported as violations
+	BrandColors []string
+	// max CIE76 distance for "near brand colour" violation, default is DEFAULT_BRAND_COLOR_DELTAE
+	BrandColorsTolerance float64
+	// max width of layout in px for responsive checks, default is DEFAULT_RESPONSIVE_MAX_WIDTH
+	MaxWidth int
+	// html after css inlining, its size checked against Gmail clipping threshold, disabled if nil
+	InlinedHTML []byte
 }
 
 func InitParser() *ParserEngine {
+	return InitParserWithOptions(ParserOptions{})
+}
+
+func InitParserWithOptions(options ParserOptions) *ParserEngine {
 	return &ParserEngine{
 		bytesToLine:     []int{},
 		isStyleTagOpen:  false,
 		styleTagContent: "",
+		msoNamespaces:   make(map[string]bool),
+		msoUsages:       make(map[string]SourcePosition),
+		htmlTagsStack:   []openHtmlTag{},
+		headAudit: headAuditState{
+			metas: make(map[string][]headMetaEntry),
+		},
+		cssVariableDefinitions: make(map[string][]CssVariableDefinition),
+		fontStacksIndex:        make(map[string]int),
+		colorPaletteIndex:      make(map[string]int),
+		darkMode: darkModeState{
+			overrides: make(map[string]bool),
+		},
+		accessibility: accessibilityState{
+			ids: make(map[string]bool),
+		},
+		responsive: responsiveState{
+			maxWidths: make(map[string]bool),
+		},
+		options: options,
 	}
 }
 
-func makeInitialReportContainer(position int, ruleCssPropData interface{}) ReportContainer {
+func makeInitialReportContainer(position SourcePosition, ruleCssPropData interface{}) ReportContainer {
 	lines := make(map[int]bool)
-	lines[position] = true
+	lines[position.Line] = true
 
 	return ReportContainer{
-		Rules:     ruleCssPropData,
-		Lines:     lines,
-		MoreLines: false,
+		Rules:           ruleCssPropData,
+		Lines:           lines,
+		MoreLines:       false,
+		Occurrences:     []SourcePosition{position},
+		OnlyConditional: position.isConditional(),
+		OnlyGuarded:     position.isGuarded(),
+	}
+}
+
+// makeIssueRule describe item of report section, which has no caniemail stats
+func makeIssueRule(title, description, severity string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"severity":    severity,
+	}
+}
+
+// addToReportItems add position to items of report section, should be called under lock
+func addToReportItems(items map[string]ReportContainer, itemKey string, position SourcePosition, ruleData interface{}) map[string]ReportContainer {
+	if prKeyData, ok := items[itemKey]; ok {
+		items[itemKey] = addPositionToReportContainer(prKeyData, position)
+		return items
 	}
+	if items == nil {
+		items = make(map[string]ReportContainer)
+	}
+	items[itemKey] = makeInitialReportContainer(position, ruleData)
+	return items
+}
+
+// saveToReportIssues add issue position to report section with issues as keys
+func (prs *ParserEngine) saveToReportIssues(target *map[string]ReportContainer, rules map[string]map[string]interface{}, issue string, position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	*target = addToReportItems(*target, issue, position, rules[issue])
 }
 
-func (prs *ParserEngine) saveToReportHtmlAttributes(attrKey, attrVal string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportHtmlAttributes(attrKey, attrVal string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.HtmlAttributes[attrKey]; ok {
 		if prValData, ok := prKeyData[attrVal]; ok {
-			if len(prValData.Lines) < LIMIT_REPORT_LINES {
-				prValData.Lines[position] = true
-			} else {
-				prValData.MoreLines = true
-			}
+			prValData = addPositionToReportContainer(prValData, position)
 			prKeyData[attrVal] = prValData
 			prs.pr.HtmlAttributes[attrKey] = prKeyData
 		} else {
@@ -198,85 +337,57 @@ func (prs *ParserEngine) saveToReportHtmlAttributes(attrKey, attrVal string, pos
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssVariables(position int) {
+func (prs *ParserEngine) saveToReportCssVariables(position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
 	if len(prs.pr.CssVariables.Lines) > 0 {
-		if len(prs.pr.CssVariables.Lines) < LIMIT_REPORT_LINES {
-			prs.pr.CssVariables.Lines[position] = true
-		} else {
-			prs.pr.CssVariables.MoreLines = true
-		}
+		prs.pr.CssVariables = addPositionToReportContainer(prs.pr.CssVariables, position)
 	} else {
-		lines := make(map[int]bool)
-		lines[position] = true
-
-		prs.pr.CssVariables = ReportContainer{
-			Rules:     rulesDB.CssVariables,
-			Lines:     lines,
-			MoreLines: false,
-		}
+		prs.pr.CssVariables = makeInitialReportContainer(position, rulesDB.CssVariables)
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssImportant(position int) {
+func (prs *ParserEngine) saveToReportCssImportant(position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
 	if len(prs.pr.CssImportant.Lines) > 0 {
-		if len(prs.pr.CssImportant.Lines) < LIMIT_REPORT_LINES {
-			prs.pr.CssImportant.Lines[position] = true
-		} else {
-			prs.pr.CssImportant.MoreLines = true
-		}
+		prs.pr.CssImportant = addPositionToReportContainer(prs.pr.CssImportant, position)
 	} else {
-		lines := make(map[int]bool)
-		lines[position] = true
-
-		prs.pr.CssImportant = ReportContainer{
-			Rules:     rulesDB.CssImportant,
-			Lines:     lines,
-			MoreLines: false,
-		}
+		prs.pr.CssImportant = makeInitialReportContainer(position, rulesDB.CssImportant)
 	}
 }
 
-func (prs *ParserEngine) saveToReportHtml5Doctype(position int) {
+func (prs *ParserEngine) saveToReportHtml5Doctype(position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
 	if len(prs.pr.Html5Doctype.Lines) > 0 {
-		if len(prs.pr.Html5Doctype.Lines) < LIMIT_REPORT_LINES {
-			prs.pr.Html5Doctype.Lines[position] = true
-		} else {
-			prs.pr.Html5Doctype.MoreLines = true
-		}
+		prs.pr.Html5Doctype = addPositionToReportContainer(prs.pr.Html5Doctype, position)
 	} else {
-		lines := make(map[int]bool)
-		lines[position] = true
-
-		prs.pr.Html5Doctype = ReportContainer{
-			Rules:     rulesDB.Html5Doctype,
-			Lines:     lines,
-			MoreLines: false,
-		}
+		prs.pr.Html5Doctype = makeInitialReportContainer(position, rulesDB.Html5Doctype)
 	}
 }
 
-func (prs *ParserEngine) checkHtmlAttribute(attrKey, attrVal string, position int) {
+func (prs *ParserEngine) checkHtmlAttribute(attrKey, attrVal string, position SourcePosition) {
 	attrKey = strings.ToLower(strings.Trim(attrKey, WHITESPACE))
 	attrVal = strings.ToLower(strings.Trim(attrVal, WHITESPACE))
 
-	if cssKeyData, ok := rulesDB.HtmlAttributes[attrKey]; ok {
-		if cssValData, ok := cssKeyData[attrVal]; ok {
-			prs.saveToReportHtmlAttributes(attrKey, attrVal, position, cssValData)
-		}
+	cssValData, ok := rulesDB.HtmlAttributes[attrKey][attrVal]
+	prs.traceRuleLookup("html_attributes", fmt.Sprintf(TWO_KEYS_MERGE_FORMAT, attrKey, attrVal), ok, position)
+	if ok {
+		prs.saveToReportHtmlAttributes(attrKey, attrVal, position, cssValData)
 	}
 }
 
-func (prs *ParserEngine) saveToReportAtRuleCssStatements(propertyKey, propertyVal string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportAtRuleCssStatements(propertyKey, propertyVal string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.AtRuleCssStatements[propertyKey]; ok {
 		if prValData, ok := prKeyData[propertyVal]; ok {
-			if len(prValData.Lines) < LIMIT_REPORT_LINES {
-				prValData.Lines[position] = true
-			} else {
-				prValData.MoreLines = true
-			}
+			prValData = addPositionToReportContainer(prValData, position)
 			prKeyData[propertyVal] = prValData
 			prs.pr.AtRuleCssStatements[propertyKey] = prKeyData
 		} else {
@@ -302,27 +413,23 @@ func (prs *ParserEngine) saveToReportAtRuleCssStatements(propertyKey, propertyVa
 	}
 }
 
-func (prs *ParserEngine) checkAtRuleCssStatements(propertyKey, propertyVal string, position int) {
+func (prs *ParserEngine) checkAtRuleCssStatements(propertyKey, propertyVal string, position SourcePosition) {
 	propertyKey = strings.ToLower(strings.Trim(propertyKey, WHITESPACE))
 	propertyVal = strings.ToLower(strings.Trim(propertyVal, WHITESPACE))
 
-	if cssKeyData, ok := rulesDB.AtRuleCssStatements[propertyKey]; ok {
-		if cssValData, ok := cssKeyData[propertyVal]; ok {
-			prs.saveToReportAtRuleCssStatements(propertyKey, propertyVal, position, cssValData)
-		}
+	cssValData, ok := rulesDB.AtRuleCssStatements[propertyKey][propertyVal]
+	prs.traceRuleLookup("at_rule_css_statements", fmt.Sprintf(TWO_KEYS_MERGE_FORMAT, propertyKey, propertyVal), ok, position)
+	if ok {
+		prs.saveToReportAtRuleCssStatements(propertyKey, propertyVal, position, cssValData)
 	}
 }
 
-func (prs *ParserEngine) saveToReportImgFormats(psSelectorValue string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportImgFormats(psSelectorValue string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.ImgFormats[psSelectorValue]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.ImgFormats[psSelectorValue] = prKeyData
 	} else {
 		if len(prs.pr.ImgFormats) > 0 {
@@ -335,55 +442,12 @@ func (prs *ParserEngine) saveToReportImgFormats(psSelectorValue string, position
 	}
 }
 
-func (prs *ParserEngine) checkImgFormat(imgUrl string, position int) {
-	if cssUrlRe.MatchString(imgUrl) {
-		imgUrl = cssUrlRe.FindStringSubmatch(imgUrl)[1] // parse url from "url(img.path)"
-	}
-
-	if strings.HasPrefix(imgUrl, "data:") && strings.Contains(imgUrl, "base64") {
-		if imgFormatsData, ok := rulesDB.ImgFormats["base64"]; ok {
-			prs.saveToReportImgFormats("base64", position, imgFormatsData)
-		}
-		return
-	}
-
-	urlData, err := url.Parse(imgUrl)
-	if err != nil {
-		return
-	}
-
-	format := strings.Replace(filepath.Ext(urlData.Path), ".", "", 1) // remove dot from extension
-	if imgFormatsData, ok := rulesDB.ImgFormats[format]; ok {
-		prs.saveToReportImgFormats(format, position, imgFormatsData)
-	}
-}
-
-func (prs *ParserEngine) checkAttrImgFormat(attrKey, imgUrl string, position int) {
-	imgUrl = strings.ToLower(strings.Trim(imgUrl, WHITESPACE))
-
-	if attrKey == "srcset" && (strings.Contains(imgUrl, " ") || strings.Contains(imgUrl, ",")) {
-		for _, imgWithSize := range strings.Split(imgUrl, ",") {
-			imgAndOther := strings.Split(strings.Trim(imgWithSize, WHITESPACE), " ")
-			if len(imgAndOther) > 0 {
-				prs.checkImgFormat(strings.Trim(imgAndOther[0], WHITESPACE), position)
-			}
-		}
-		return
-	}
-
-	prs.checkImgFormat(imgUrl, position)
-}
-
-func (prs *ParserEngine) saveToReportCssPseudoSelectors(psSelectorValue string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportCssPseudoSelectors(psSelectorValue string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.CssPseudoSelectors[psSelectorValue]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.CssPseudoSelectors[psSelectorValue] = prKeyData
 	} else {
 		if len(prs.pr.CssPseudoSelectors) > 0 {
@@ -396,23 +460,21 @@ func (prs *ParserEngine) saveToReportCssPseudoSelectors(psSelectorValue string,
 	}
 }
 
-func (prs *ParserEngine) checkCssPseudoSelector(psSelectorValue string, position int) {
+func (prs *ParserEngine) checkCssPseudoSelector(psSelectorValue string, position SourcePosition) {
 	psSelectorValue = strings.ToLower(strings.Trim(psSelectorValue, WHITESPACE))
-	if cssFunctionsData, ok := rulesDB.CssPseudoSelectors[psSelectorValue]; ok {
+	cssFunctionsData, ok := rulesDB.CssPseudoSelectors[psSelectorValue]
+	prs.traceRuleLookup("css_pseudo_selectors", psSelectorValue, ok, position)
+	if ok {
 		prs.saveToReportCssPseudoSelectors(psSelectorValue, position, cssFunctionsData)
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssFunctions(functionValue string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportCssFunctions(functionValue string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.CssFunctions[functionValue]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.CssFunctions[functionValue] = prKeyData
 	} else {
 		if len(prs.pr.CssFunctions) > 0 {
@@ -425,23 +487,21 @@ func (prs *ParserEngine) saveToReportCssFunctions(functionValue string, position
 	}
 }
 
-func (prs *ParserEngine) checkCssFunction(functionValue string, position int) {
+func (prs *ParserEngine) checkCssFunction(functionValue string, position SourcePosition) {
 	functionValue = strings.ToLower(strings.Trim(strings.ReplaceAll(functionValue, "(", ""), WHITESPACE))
-	if cssFunctionsData, ok := rulesDB.CssFunctions[functionValue]; ok {
+	cssFunctionsData, ok := rulesDB.CssFunctions[functionValue]
+	prs.traceRuleLookup("css_functions", functionValue, ok, position)
+	if ok {
 		prs.saveToReportCssFunctions(functionValue, position, cssFunctionsData)
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssDimention(dimentionValue string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportCssDimention(dimentionValue string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.CssDimentions[dimentionValue]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.CssDimentions[dimentionValue] = prKeyData
 	} else {
 		if len(prs.pr.CssDimentions) > 0 {
@@ -454,23 +514,21 @@ func (prs *ParserEngine) saveToReportCssDimention(dimentionValue string, positio
 	}
 }
 
-func (prs *ParserEngine) checkCssDimention(dimentionValue string, position int) {
+func (prs *ParserEngine) checkCssDimention(dimentionValue string, position SourcePosition) {
 	dimentionValue = strings.ToLower(strings.Trim(dimentionsRe.ReplaceAllString(dimentionValue, ""), WHITESPACE))
-	if cssDimentionsData, ok := rulesDB.CssDimentions[dimentionValue]; ok {
+	cssDimentionsData, ok := rulesDB.CssDimentions[dimentionValue]
+	prs.traceRuleLookup("css_dimentions", dimentionValue, ok, position)
+	if ok {
 		prs.saveToReportCssDimention(dimentionValue, position, cssDimentionsData)
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssSelectorType(selectorType CssSelectorType, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportCssSelectorType(selectorType CssSelectorType, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.CssSelectorTypes[selectorType.String()]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.CssSelectorTypes[selectorType.String()] = prKeyData
 	} else {
 		if len(prs.pr.CssSelectorTypes) > 0 {
@@ -483,43 +541,116 @@ func (prs *ParserEngine) saveToReportCssSelectorType(selectorType CssSelectorTyp
 	}
 }
 
-func (prs *ParserEngine) checkCssSelectorType(selectorType CssSelectorType, position int) {
-	// log.Printf("[checkCssSelectorType]: %v - %v\n", selectorType, position)
-	if cssSelectorTypeData, ok := rulesDB.CssSelectorTypes[selectorType.String()]; ok {
+func (prs *ParserEngine) checkCssSelectorType(selectorType CssSelectorType, position SourcePosition) {
+	cssSelectorTypeData, ok := rulesDB.CssSelectorTypes[selectorType.String()]
+	prs.traceRuleLookup("css_selector_types", selectorType.String(), ok, position)
+	if ok {
 		prs.saveToReportCssSelectorType(selectorType, position, cssSelectorTypeData)
 	}
 }
 
-func (prs *ParserEngine) checkCssPropertyStyle(propertyKey, propertyVal string, position int) {
-	propertyKey = normalizeCssProp(strings.ToLower(strings.Trim(propertyKey, WHITESPACE)))
-	propertyVal = strings.Trim(strings.ReplaceAll(propertyVal, "!important", ""), WHITESPACE)
+// cssValueKeywords split declaration value to keywords (identifiers and function names),
+// grouped by comma separated lists. Strings and urls are not keywords
+func cssValueKeywords(values []css.Token) (string, [][]string) {
+	var (
+		normalized []string
+		segments   [][]string = [][]string{{}}
+		depth      int        = 0
+	)
 
-	if cssKeyData, ok := rulesDB.CssProperties[propertyKey]; ok {
-		if cssValData, ok := cssKeyData[""]; ok {
-			prs.saveToReportCssProperty(propertyKey, "", position, cssValData)
+	for i, val := range values {
+		tokenVal := strings.ToLower(strings.Trim(string(val.Data), WHITESPACE))
+
+		switch val.TokenType {
+		case css.WhitespaceToken, css.CommentToken:
+			continue
+		case css.DelimToken:
+			if tokenVal == "!" {
+				for _, nextVal := range values[i+1:] {
+					if nextVal.TokenType == css.WhitespaceToken {
+						continue
+					}
+					if nextVal.TokenType == css.IdentToken && strings.EqualFold(string(nextVal.Data), "important") {
+						return strings.Join(normalized, " "), segments // "!important" is not part of value
+					}
+					break
+				}
+			}
+		case css.CommaToken:
+			if depth == 0 {
+				segments = append(segments, []string{})
+			}
+		case css.IdentToken:
+			segments[len(segments)-1] = append(segments[len(segments)-1], tokenVal)
+		case css.FunctionToken:
+			segments[len(segments)-1] = append(segments[len(segments)-1], strings.TrimSuffix(tokenVal, "("))
+			depth += 1
+		case css.LeftParenthesisToken:
+			depth += 1
+		case css.RightParenthesisToken:
+			if depth > 0 {
+				depth -= 1
+			}
 		}
-		if cssValData, ok := cssKeyData[propertyVal]; ok {
-			prs.saveToReportCssProperty(propertyKey, propertyVal, position, cssValData)
+
+		normalized = append(normalized, tokenVal)
+	}
+
+	return strings.Join(normalized, " "), segments
+}
+
+// isCssValueKeywordsMatch check if rule value (one or several keywords) present in declaration value
+func isCssValueKeywordsMatch(ruleVal string, segments [][]string) bool {
+	ruleKeywords := strings.Fields(ruleVal)
+	if len(ruleKeywords) == 0 {
+		return false
+	}
+
+	for _, keywords := range segments {
+		for i := 0; i+len(ruleKeywords) <= len(keywords); i++ {
+			matched := true
+			for j, ruleKeyword := range ruleKeywords {
+				if keywords[i+j] != ruleKeyword {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
 		}
+	}
+	return false
+}
+
+func (prs *ParserEngine) checkCssPropertyStyle(propertyKey string, values []css.Token, position SourcePosition) {
+	propertyKey = strings.ToLower(strings.Trim(propertyKey, WHITESPACE))
+	prs.checkUnknownCssProperty(propertyKey, position)
+
+	propertyKey = normalizeCssProp(propertyKey)
+
+	prs.checkMsoProperty(propertyKey, position)
+
+	cssKeyData, ok := rulesDB.CssProperties[propertyKey]
+	prs.traceRuleLookup("css_properties", propertyKey, ok, position)
+	if ok {
+		propertyVal, keywords := cssValueKeywords(values)
+
 		for prKey, prVal := range cssKeyData {
-			if propertyVal != prKey && strings.Contains(propertyVal, prKey) {
+			if len(prKey) == 0 || prKey == propertyVal || isCssValueKeywordsMatch(prKey, keywords) {
 				prs.saveToReportCssProperty(propertyKey, prKey, position, prVal)
 			}
 		}
 	}
 }
 
-func (prs *ParserEngine) saveToReportCssProperty(propertyKey, propertyVal string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportCssProperty(propertyKey, propertyVal string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.CssProperties[propertyKey]; ok {
 		if prValData, ok := prKeyData[propertyVal]; ok {
-			if len(prValData.Lines) < LIMIT_REPORT_LINES {
-				prValData.Lines[position] = true
-			} else {
-				prValData.MoreLines = true
-			}
+			prValData = addPositionToReportContainer(prValData, position)
 			prKeyData[propertyVal] = prValData
 			prs.pr.CssProperties[propertyKey] = prKeyData
 		} else {
@@ -545,17 +676,26 @@ func (prs *ParserEngine) saveToReportCssProperty(propertyKey, propertyVal string
 	}
 }
 
-func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType, data []byte, position int) {
+func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType, data []byte, position SourcePosition) {
+	position = position.withSnippet(cssGrammarSnippet(gt, data, p.Values()))
+
 	switch gt {
 	case css.CustomPropertyGrammar:
 		prs.saveToReportCssVariables(position)
+		prs.saveCssVariableDefinition(string(data), p.Values(), position)
+		prs.checkCssColors(string(data), tokenizeCssValue(cssTokensToString(p.Values())), position)
 	case css.AtRuleGrammar:
 		prs.checkAtRuleCssStatements(string(data), "", position)
+		if strings.ToLower(string(data)) == "@import" {
+			prs.checkFontImport(p.Values(), position)
+		}
 		for _, val := range p.Values() {
 			prs.checkAtRuleCssStatements(string(data), string(val.Data), position)
 		}
 	case css.BeginAtRuleGrammar:
 		prs.checkAtRuleCssStatements(string(data), "", position)
+		prs.checkDarkModeAtRule(string(data), p.Values(), position)
+		prs.checkResponsiveAtRule(string(data), p.Values(), position)
 		for _, val := range p.Values() {
 			prs.checkAtRuleCssStatements(string(data), string(val.Data), position)
 
@@ -567,6 +707,8 @@ func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType,
 		if gt == css.QualifiedRuleGrammar {
 			prs.checkCssSelectorType(GROUPING_SELECTORS_TYPE, position)
 		}
+		prs.checkDarkModeSelector(p.Values(), position)
+		prs.checkGmailSelector(p.Values(), position)
 
 		prevTokenType := css.Token{
 			TokenType: css.ErrorToken,
@@ -650,7 +792,6 @@ func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType,
 			prs.checkCssSelectorType(TYPE_SELECTOR_TYPE, position)
 		}
 	case css.DeclarationGrammar:
-		propVal := ""
 		isPrevDelimCanBeImportant := false
 		for _, val := range p.Values() {
 			cssPropVal := strings.ToLower(strings.Trim(string(val.Data), WHITESPACE))
@@ -665,7 +806,7 @@ func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType,
 				prs.checkCssFunction(string(val.Data), position)
 			}
 			if val.TokenType == css.URLToken {
-				prs.checkImgFormat(string(val.Data), position)
+				prs.checkCssImage(string(data), string(val.Data), position)
 			}
 			if val.TokenType == css.CustomPropertyNameToken {
 				prs.saveToReportCssVariables(position)
@@ -674,28 +815,38 @@ func (prs *ParserEngine) checkCssParsedToken(p *css.Parser, gt css.GrammarType,
 				prs.saveToReportCssImportant(position)
 			}
 			isPrevDelimCanBeImportant = (val.TokenType == css.DelimToken && cssPropVal == "!")
-			propVal += cssPropVal
 		}
-		prs.checkCssPropertyStyle(string(data), propVal, position)
+		prs.checkCssPropertyStyle(string(data), p.Values(), position)
+		prs.checkCssVariableUsages(string(data), p.Values(), position)
+		prs.checkFontDeclaration(string(data), p.Values(), position)
+		prs.checkCssColors(string(data), p.Values(), position)
+		prs.checkDarkModeDeclaration(string(data), p.Values(), position)
+		prs.checkAccessibilityDeclaration(string(data), p.Values(), position)
+		prs.checkResponsiveDeclaration(string(data), p.Values(), position)
 	}
 }
 
-func (prs *ParserEngine) checkTagInlinedStyle(inlineStyle string, position int) {
+func (prs *ParserEngine) checkTagInlinedStyle(inlineStyle string, position SourcePosition) {
+	declaredProperties := make(map[string]bool)
+
 	p := css.NewParser(parse.NewInput(bytes.NewBufferString(inlineStyle)), true)
 	for {
 		gt, _, data := p.Next()
 
-		// log.Printf("[checkTagInlinedStyle]: %v - %v - %v\n", gt, tt, string(data))
-
 		if gt == css.ErrorGrammar {
 			return
 		}
+		prs.traceCssGrammar(gt, data, p, position.Line)
 
-		prs.checkCssParsedToken(p, gt, data, position)
+		declarationPosition := position
+		if gt == css.DeclarationGrammar {
+			declarationPosition = declarationPosition.withGuard(prs.ghostGuard(string(data)))
+		}
+		prs.checkCssParsedToken(p, gt, data, declarationPosition.withGuard(cssBlockGuard(gt, data, declaredProperties)))
 	}
 }
 
-func (prs *ParserEngine) processCssInStyleTag(inlineStyle string, htmlTagPosition int) {
+func (prs *ParserEngine) processCssInStyleTag(inlineStyle string, htmlTagPosition, htmlBytesOffset int, styleMedia string) cssBlockStats {
 	var (
 		bytesToLine []int
 		cursorPos   int = 0
@@ -746,32 +897,68 @@ func (prs *ParserEngine) processCssInStyleTag(inlineStyle string, htmlTagPositio
 		}
 	}
 
+	var (
+		selector string
+		atRules  []string
+	)
+	declaredProperties := make(map[string]bool)
+	stats := cssBlockStats{}
+	fontFaces := fontFaceCollector{}
+
 	p := css.NewParser(parse.NewInput(bytes.NewBufferString(inlineStyle)), false)
 	for {
 		gt, _, data := p.Next()
 
-		// log.Printf("[checkTagInlinedStyle]: %v - %v - %v - %v\n", gt, string(data), p.Values(), p.Offset())
-
 		if gt == css.ErrorGrammar {
-			return
+			fontFaces.flush(prs)
+			return stats
+		}
+		stats.track(gt)
+
+		// selector and at-rules, which wrap current grammar item
+		switch gt {
+		case css.QualifiedRuleGrammar:
+			selector += cssGrammarSnippet(gt, data, p.Values()) + ", "
+		case css.BeginRulesetGrammar:
+			selector += cssGrammarSnippet(gt, data, p.Values())
+		case css.EndRulesetGrammar:
+			selector = ""
+		case css.EndAtRuleGrammar:
+			if len(atRules) > 0 {
+				atRules = atRules[:len(atRules)-1]
+			}
 		}
 
-		position := htmlTagPosition + getLineByOffset(gt, p.Offset()) - 1
+		position := SourcePosition{
+			Line:     htmlTagPosition + getLineByOffset(gt, p.Offset()) - 1,
+			Selector: limitSnippet(selector),
+			Media:    styleMedia,
+		}
+		if len(atRules) > 0 {
+			position.AtRules = slices.Clone(atRules) // stack reused by sibling at-rules
+		}
+		position = position.withGuard(cssBlockGuard(gt, data, declaredProperties)).
+			withTemplateConditions(prs.templateConditionsAt(htmlBytesOffset + p.Offset()))
+		if isCssSupportsGuarded(atRules) {
+			position = position.withGuard(GUARD_SUPPORTS_RULE)
+		}
+		prs.traceCssGrammar(gt, data, p, position.Line)
 		prs.checkCssParsedToken(p, gt, data, position)
+		fontFaces.track(prs, gt, data, p.Values(), position)
+
+		if gt == css.BeginAtRuleGrammar {
+			atRules = append(atRules, limitSnippet(cssGrammarSnippet(gt, data, p.Values())))
+		}
 	}
 }
 
-func (prs *ParserEngine) saveToReportHtmlTag(tagName, tagAttr string, position int, ruleTagAttrData interface{}) {
+func (prs *ParserEngine) saveToReportHtmlTag(tagName, tagAttr string, position SourcePosition, ruleTagAttrData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if tagData, ok := prs.pr.HtmlTags[tagName]; ok {
 		if tagAttrData, ok := tagData[tagAttr]; ok {
-			if len(tagAttrData.Lines) < LIMIT_REPORT_LINES {
-				tagAttrData.Lines[position] = true
-			} else {
-				tagAttrData.MoreLines = true
-			}
+			tagAttrData = addPositionToReportContainer(tagAttrData, position)
 			tagData[tagAttr] = tagAttrData
 			prs.pr.HtmlTags[tagName] = tagData
 		} else {
@@ -797,66 +984,73 @@ func (prs *ParserEngine) saveToReportHtmlTag(tagName, tagAttr string, position i
 	}
 }
 
-func (prs *ParserEngine) checkHtmlTagWithAttr(attrKey, attrVal string, position int) {
+func (prs *ParserEngine) checkHtmlTagWithAttr(attrKey, attrVal string, position SourcePosition) {
 	prs.checkHtmlAttribute(attrKey, "", position)
 	prs.checkHtmlAttribute(attrKey, attrVal, position)
 
 	if attrKey == "style" {
 		prs.checkTagInlinedStyle(attrVal, position)
 	}
-
-	if attrKey == "src" || attrKey == "srcset" {
-		prs.checkAttrImgFormat(attrKey, attrVal, position)
-	}
 }
 
-func (prs *ParserEngine) checkHtmlTags(tagName string, attrs []html.Attribute, position int) {
+func (prs *ParserEngine) checkHtmlTags(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
 	if len(tagName) == 0 {
 		return
 	}
 
 	tagName = strings.ToLower(tagName)
 
-	if ruleTagData, ok := rulesDB.HtmlTags[tagName]; ok {
+	prs.checkUnknownHtmlNames(tagName, attrs, attrsPositions, position)
+	prs.checkHtmlImages(tagName, attrs, attrsPositions, position)
+	prs.checkFontLink(tagName, attrs, attrsPositions, position)
+	prs.checkDarkModeHtml(tagName, attrs, attrsPositions, position)
+	prs.checkHtmlColors(tagName, attrs, attrsPositions, position)
+	prs.checkResponsiveHtml(tagName, attrs, attrsPositions, position)
+
+	ruleTagData, ok := rulesDB.HtmlTags[tagName]
+	prs.traceRuleLookup("html_tags", tagName, ok, position)
+	if ok {
 		if ruleTagAttrData, ok := ruleTagData[""]; ok {
 			prs.saveToReportHtmlTag(tagName, "", position, ruleTagAttrData)
 		}
 		for _, att := range attrs {
 			attrKey := strings.ToLower(att.Key)
 			attrVal := strings.ToLower(att.Val)
+			attrPosition := attributePosition(attrsPositions, attrKey, position).withGuard(prs.ghostGuard(attrKey))
 
-			if ruleTagAttrData, ok := ruleTagData[attrKey]; ok {
-				prs.saveToReportHtmlTag(tagName, attrKey, position, ruleTagAttrData)
+			ruleTagAttrData, ok := ruleTagData[attrKey]
+			prs.traceRuleLookup("html_tags", fmt.Sprintf(TWO_KEYS_MERGE_FORMAT, tagName, attrKey), ok, attrPosition)
+			if ok {
+				prs.saveToReportHtmlTag(tagName, attrKey, attrPosition, ruleTagAttrData)
 			}
 
 			attrWithVal := fmt.Sprintf(TWO_KEYS_MERGE_FORMAT, attrKey, attrVal)
-			if ruleTagAttrData, ok := ruleTagData[attrWithVal]; ok {
-				prs.saveToReportHtmlTag(tagName, attrWithVal, position, ruleTagAttrData)
+			ruleTagAttrData, ok = ruleTagData[attrWithVal]
+			prs.traceRuleLookup("html_tags", fmt.Sprintf(TWO_KEYS_MERGE_FORMAT, tagName, attrWithVal), ok, attrPosition)
+			if ok {
+				prs.saveToReportHtmlTag(tagName, attrWithVal, attrPosition, ruleTagAttrData)
 			}
 
-			prs.checkHtmlTagWithAttr(attrKey, attrVal, position)
+			prs.checkHtmlTagWithAttr(attrKey, attrVal, attrPosition)
 		}
 	} else {
 		// check inline style for valid elements too
 		for _, att := range attrs {
 			attrKey := strings.ToLower(att.Key)
 			attrVal := strings.ToLower(att.Val)
+			attrPosition := attributePosition(attrsPositions, attrKey, position)
 
-			prs.checkHtmlTagWithAttr(attrKey, attrVal, position)
+			prs.checkHtmlTagWithAttr(attrKey, attrVal, attrPosition)
 		}
 	}
 }
 
-func (prs *ParserEngine) saveToReportLinkTypes(linkType string, position int, ruleCssPropData interface{}) {
+func (prs *ParserEngine) saveToReportLinkTypes(linkType string, position SourcePosition, ruleCssPropData interface{}) {
 	prs.mx.Lock()
 	defer prs.mx.Unlock()
 
 	if prKeyData, ok := prs.pr.LinkTypes[linkType]; ok {
-		if len(prKeyData.Lines) < LIMIT_REPORT_LINES {
-			prKeyData.Lines[position] = true
-		} else {
-			prKeyData.MoreLines = true
-		}
+		prKeyData = addPositionToReportContainer(prKeyData, position)
 		prs.pr.LinkTypes[linkType] = prKeyData
 	} else {
 		if len(prs.pr.LinkTypes) > 0 {
@@ -869,19 +1063,33 @@ func (prs *ParserEngine) saveToReportLinkTypes(linkType string, position int, ru
 	}
 }
 
-func (prs *ParserEngine) checkLinkTypes(attrs []html.Attribute, position int) {
+func getHtmlAttributeValue(attrs []html.Attribute, attrKey string) string {
+	for _, att := range attrs {
+		if strings.ToLower(att.Key) == attrKey {
+			return strings.Trim(att.Val, WHITESPACE)
+		}
+	}
+	return ""
+}
+
+func (prs *ParserEngine) checkLinkTypes(attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
 	for _, att := range attrs {
 		attrKey := strings.ToLower(att.Key)
 		attrVal := strings.Trim(att.Val, WHITESPACE)
 
 		if attrKey == "href" && len(attrVal) > 0 {
+			position := attributePosition(attrsPositions, attrKey, position)
 			if anchorLinkRe.MatchString(attrVal) {
-				if ruleLinkData, ok := rulesDB.LinkTypes["anchor"]; ok {
+				ruleLinkData, ok := rulesDB.LinkTypes["anchor"]
+				prs.traceRuleLookup("link_types", "anchor", ok, position)
+				if ok {
 					prs.saveToReportLinkTypes("anchor", position, ruleLinkData)
 				}
 			}
 			if mailtoLinkRe.MatchString(attrVal) {
-				if ruleLinkData, ok := rulesDB.LinkTypes["mailto"]; ok {
+				ruleLinkData, ok := rulesDB.LinkTypes["mailto"]
+				prs.traceRuleLookup("link_types", "mailto", ok, position)
+				if ok {
 					prs.saveToReportLinkTypes("mailto", position, ruleLinkData)
 				}
 			}
@@ -889,50 +1097,83 @@ func (prs *ParserEngine) checkLinkTypes(attrs []html.Attribute, position int) {
 	}
 }
 
-func (prs *ParserEngine) processHtmlToken(htmlTokenizer *html.Tokenizer, token html.Token, tagLine int) {
+func (prs *ParserEngine) processHtmlToken(htmlTokenizer *html.Tokenizer, token html.Token, tagOffset, tagLine int) {
+	// check html structure
+	prs.checkHtmlStructure(htmlTokenizer, token, tagOffset, tagLine)
+
+	prs.trackGhostStructure(token)
+	tagPosition := SourcePosition{Line: tagLine}.
+		withSnippet(string(htmlTokenizer.Raw())).
+		withTemplateConditions(prs.templateConditionsAt(tagOffset))
+	prs.collectHeadAudit(token, tagPosition)
+	prs.collectAccessibility(token, tagPosition)
+	prs.collectTableLayout(token, tagPosition)
+
 	switch token.Type {
 	case html.TextToken:
 		if prs.isStyleTagOpen {
+			if len(prs.styleTagContent) == 0 {
+				prs.styleTagOffset = tagOffset
+			}
 			prs.styleTagContent += strings.Replace(token.Data, "\x00", "\ufffd", -1) // replace NULL
 		}
 	case html.StartTagToken:
+		attrsPositions := htmlAttributesPositions(tagPosition, htmlTokenizer.Raw())
 		switch token.DataAtom {
 		case a.Style:
 			prs.isStyleTagOpen = true
-			prs.styleTagLine = tagLine
+			prs.styleTagMedia = collapseWhitespace(getHtmlAttributeValue(token.Attr, "media"))
+			prs.styleTagPosition = tagPosition
+			prs.styleTagLocation = prs.getStyleBlockLocation()
+			if isDarkModeMedia(prs.styleTagMedia) {
+				prs.saveToReportDarkMode(DARK_MODE_PREFERS_COLOR_SCHEME, tagPosition)
+			}
 		case a.A:
 			// check link
-			prs.checkLinkTypes(token.Attr, tagLine)
+			prs.checkLinkTypes(token.Attr, attrsPositions, tagPosition)
 		}
 		// process html tag
-		prs.checkHtmlTags(token.Data, token.Attr, tagLine)
+		prs.checkHtmlTags(token.Data, token.Attr, attrsPositions, tagPosition)
+		prs.checkMsoMarkup(token.Data, token.Attr, tagPosition)
 	case html.EndTagToken:
 		switch token.DataAtom {
 		case a.Style:
-			if prs.isStyleTagOpen && len(prs.styleTagContent) > 0 {
-				prs.wg.Add(1)
-				go func(content string, line int) {
-					defer prs.wg.Done()
-					prs.processCssInStyleTag(content, line)
-				}(prs.styleTagContent, prs.styleTagLine)
+			if prs.isStyleTagOpen {
+				blockIndex := prs.addStyleBlock(prs.styleTagPosition, prs.styleTagLocation, prs.styleTagMedia, len(prs.styleTagContent))
+				if len(prs.styleTagContent) > 0 {
+					prs.wg.Add(1)
+					go func(content string, position SourcePosition, contentOffset int, media string, blockIndex int) {
+						defer prs.wg.Done()
+						prs.saveStyleBlockStats(blockIndex, prs.processCssInStyleTag(content, position.Line, contentOffset, media))
+						prs.checkGmailStyleBlock(content, position, blockIndex)
+					}(prs.styleTagContent, prs.styleTagPosition, prs.styleTagOffset, prs.styleTagMedia, blockIndex)
+				}
 				// reset style tag storage
 				prs.isStyleTagOpen = false
 				prs.styleTagContent = ""
-				prs.styleTagLine = 0
+				prs.styleTagOffset = 0
+				prs.styleTagMedia = ""
+				prs.styleTagPosition = SourcePosition{}
+				prs.styleTagLocation = ""
 			}
 		}
 	case html.SelfClosingTagToken:
+		attrsPositions := htmlAttributesPositions(tagPosition, htmlTokenizer.Raw())
 		// process html tag
-		prs.checkHtmlTags(token.Data, token.Attr, tagLine)
+		prs.checkHtmlTags(token.Data, token.Attr, attrsPositions, tagPosition)
+		prs.checkMsoMarkup(token.Data, token.Attr, tagPosition)
+	case html.CommentToken:
+		// conditional comments hide markup from tokenizer
+		prs.processConditionalComment(htmlTokenizer.Raw(), tagOffset, tagLine)
 	case html.DoctypeToken:
 		// check doctype
 		if html5DoctypeRe.MatchString(token.String()) {
-			prs.saveToReportHtml5Doctype(tagLine)
+			prs.saveToReportHtml5Doctype(tagPosition)
 		}
 	}
 }
 
-func (prs ParserEngine) getLineFromOffset(tagLine, offset int) int {
+func (prs *ParserEngine) getLineFromOffset(tagLine, offset int) int {
 	// binary search used before, but looks like it waste of time - we can just "follow" bytes offset
 	// return sort.Search(len(prs.bytesToLine), func(i int) bool { return prs.bytesToLine[i] > offset })
 	for {
@@ -964,17 +1205,14 @@ func (prs *ParserEngine) calulateNewlineBytePos(document []byte) {
 	prs.bytesToLine = bytesToLine
 }
 
-func (prs *ParserEngine) Report(document []byte) (*ParseReport, error) {
+// processHtmlContent tokenize html, which start at htmlBytesOffset byte of document
+func (prs *ParserEngine) processHtmlContent(content []byte, htmlBytesOffset, tagLine int) error {
 	var (
-		err             error
-		htmlTokenizer   *html.Tokenizer
-		htmlBytesOffset int = 0
-		tagLine         int = 0
+		err           error
+		htmlTokenizer *html.Tokenizer
 	)
 
-	prs.calulateNewlineBytePos(document)
-
-	htmlTokenizer = html.NewTokenizer(bytes.NewReader(document))
+	htmlTokenizer = html.NewTokenizer(bytes.NewReader(content))
 	for err != io.EOF {
 		// CDATA sections are not alowed
 		htmlTokenizer.AllowCDATA(false)
@@ -984,20 +1222,69 @@ func (prs *ParserEngine) Report(document []byte) (*ParseReport, error) {
 		if tt.Type == html.ErrorToken {
 			err = htmlTokenizer.Err()
 			if err != nil && err != io.EOF {
-				return nil, err
+				return err
 			}
 		}
 
-		// log.Printf("[htmlTokenizer]: info: %v ; data: %v ; type: %v ; atom: %v; attr - %v \n", tt, tt.Data, tt.Type, tt.DataAtom, tt.Attr)
-
 		tagLine = prs.getLineFromOffset(tagLine, htmlBytesOffset)
-		prs.processHtmlToken(htmlTokenizer, tt, tagLine)
+		if prs.isTraceEnabled() {
+			prs.trace(TraceEvent{
+				Kind:   TRACE_HTML_TOKEN,
+				Type:   tt.Type.String(),
+				Data:   limitSnippet(string(htmlTokenizer.Raw())),
+				Offset: htmlBytesOffset,
+				Line:   tagLine,
+			})
+		}
+		prs.processHtmlToken(htmlTokenizer, tt, htmlBytesOffset, tagLine)
 
 		htmlBytesOffset += len(htmlTokenizer.Raw())
 	}
 
+	return nil
+}
+
+func (prs *ParserEngine) Report(document []byte) (*ParseReport, error) {
+	if prs.options.Includes != nil {
+		flattened, includeLines, err := resolveIncludes(document, prs.options.Includes, prs.options.FileName)
+		if err != nil {
+			return nil, err
+		}
+		document, prs.includeLines = flattened, includeLines
+	}
+
+	prs.document = document // original source used for contexts
+	prs.calulateNewlineBytePos(document)
+
+	maskedDocument, templates := maskTemplateTags(document)
+	prs.templates = templates
+
+	if err := prs.processHtmlContent(maskedDocument, 0, 0); err != nil {
+		return nil, err
+	}
+
 	prs.wg.Wait() // wait all jobs
 
+	if !prs.options.Fragment {
+		// document level checks
+		prs.checkMsoNamespaces()
+		prs.checkUnclosedHtmlTags()
+		prs.checkHeadAudit()
+	}
+	prs.checkStyleBlocks()
+	prs.sortImageReferences()
+	prs.checkCssVariablesGraph()
+	prs.checkFonts()
+	prs.checkColorPalette()
+	prs.checkDarkMode()
+	prs.checkAccessibility()
+	prs.checkContrast(maskedDocument)
+	prs.checkResponsive()
+	prs.checkTableLayout()
+	prs.checkGmail()
+	prs.fillReportContexts()
+	prs.applyOriginOffsets()
+
 	return &prs.pr, nil
 }
 
@@ -1006,10 +1293,21 @@ func init() {
 	if err := json.Unmarshal(caniuseJSON, &rulesDB); err != nil {
 		panic(err)
 	}
+	// known names for typos detection
+	initKnownNames()
+	// issues explanations use rules stats
+	initDarkModeRules()
+	initAccessibilityRules()
+	initResponsiveRules()
+	initTablesRules()
 }
 
 func ReportFromHTML(document []byte) (*ParseReport, error) {
-	parser := InitParser()
+	return ReportFromHTMLWithOptions(document, ParserOptions{})
+}
+
+func ReportFromHTMLWithOptions(document []byte, options ParserOptions) (*ParseReport, error) {
+	parser := InitParserWithOptions(options)
 	report, err := parser.Report(document)
 	if err != nil {
 		return nil, err
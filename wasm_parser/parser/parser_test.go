@@ -712,6 +712,50 @@ func TestReportWorkWithContainCssRule(t *testing.T) {
 	}
 }
 
+func TestReportFromHTMLCssValueKeywords(t *testing.T) {
+	html := `<html><body>
+<style>
+	.inline { display: inline-flex; }
+	.grid-bg { background:url(grid.png) no-repeat; }
+	.flex { display: flex !important; }
+	.grid { display:grid }
+	.fonts { font-family: "system-ui", Arial, ui-sans-serif; }
+	.quoted { font-family: "system-ui"; }
+	.gradient { background: #fff, conic-gradient(red, blue); }
+	.size { width: min(100%, max-content); max-width: max-content-box; }
+</style>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	var tests = []struct {
+		checkType string
+		got       map[int]bool
+		want      map[int]bool
+	}{
+		{"CssProperties display flex", report.CssProperties["display"]["flex"].Lines, map[int]bool{5: true}},
+		{"CssProperties display grid", report.CssProperties["display"]["grid"].Lines, map[int]bool{6: true}},
+		{"CssProperties font-family system-ui", report.CssProperties["font-family"]["system-ui"].Lines, nil},
+		{"CssProperties font-family ui-sans-serif", report.CssProperties["font-family"]["ui-sans-serif"].Lines, map[int]bool{7: true}},
+		{"CssProperties background conic-gradient", report.CssProperties["background"]["conic-gradient"].Lines, map[int]bool{9: true}},
+		{"CssProperties width max-content", report.CssProperties["width"]["max-content"].Lines, map[int]bool{10: true}},
+		{"CssProperties max-width max-content", report.CssProperties["max-width"]["max-content"].Lines, nil},
+	}
+
+	for _, tt := range tests {
+		testname := tt.checkType
+		t.Run(testname, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+}
+
 func BenchmarkReportFromHTML(b *testing.B) {
 	html, err := os.ReadFile("./bench.html")
 	if err != nil {
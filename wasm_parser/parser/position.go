@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+const (
+	LIMIT_SNIPPET_LENGTH = 300
+	CONTEXT_WINDOW_LINES = 1 // lines before and after finding
+)
+
+// SourcePosition is place in the document, where finding was detected
+type SourcePosition struct {
+	Line     int      `json:"line"`
+	File     string   `json:"file,omitempty"` // partial, which produced finding (includes resolution)
+	Snippet  string   `json:"snippet"`
+	Context  string   `json:"context"`
+	Selector string   `json:"selector,omitempty"`
+	AtRules  []string `json:"at_rules,omitempty"` // enclosing at-rules, outer first
+	Media    string   `json:"media,omitempty"`    // media attribute of <style> element
+	Guard    string   `json:"guard,omitempty"`    // fallback, which make finding safe
+	// enclosing template conditionals ({% if %}, {{#if}}, *|IF:|*), outer first
+	TemplateConditions []string `json:"template_conditions,omitempty"`
+}
+
+// isConditional return true, if finding is applied only under some media, support or other at-rule condition
+func (pos SourcePosition) isConditional() bool {
+	return len(pos.AtRules) > 0 || (len(pos.Media) > 0 && pos.Media != "all")
+}
+
+func (pos SourcePosition) withSnippet(snippet string) SourcePosition {
+	pos.Snippet = limitSnippet(snippet)
+	return pos
+}
+
+func limitSnippet(snippet string) string {
+	return cutSnippet(strings.Trim(snippet, WHITESPACE))
+}
+
+func cutSnippet(snippet string) string {
+	if len(snippet) <= LIMIT_SNIPPET_LENGTH {
+		return snippet
+	}
+
+	cut := LIMIT_SNIPPET_LENGTH
+	for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+		cut -= 1
+	}
+	return snippet[:cut] + "…"
+}
+
+func collapseWhitespace(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
+
+func cssTokensToString(values []css.Token) string {
+	var buf strings.Builder
+	for _, val := range values {
+		buf.Write(val.Data)
+	}
+	return buf.String()
+}
+
+// cssGrammarSnippet restore source of css grammar item
+func cssGrammarSnippet(gt css.GrammarType, data []byte, values []css.Token) string {
+	switch gt {
+	case css.DeclarationGrammar, css.CustomPropertyGrammar:
+		return string(data) + ": " + strings.Trim(cssTokensToString(values), WHITESPACE)
+	case css.AtRuleGrammar, css.BeginAtRuleGrammar:
+		return collapseWhitespace(string(data) + " " + cssTokensToString(values))
+	case css.QualifiedRuleGrammar, css.BeginRulesetGrammar:
+		return collapseWhitespace(string(data) + cssTokensToString(values))
+	}
+	return string(data)
+}
+
+func addPositionToReportContainer(container ReportContainer, position SourcePosition) ReportContainer {
+	container.OnlyConditional = container.OnlyConditional && position.isConditional()
+	container.OnlyGuarded = container.OnlyGuarded && position.isGuarded()
+
+	if len(container.Lines) < LIMIT_REPORT_LINES {
+		container.Lines[position.Line] = true
+	} else {
+		container.MoreLines = true
+	}
+
+	if len(container.Occurrences) < LIMIT_REPORT_LINES {
+		for _, occurrence := range container.Occurrences {
+			if occurrence.Line == position.Line && occurrence.Snippet == position.Snippet {
+				return container
+			}
+		}
+		container.Occurrences = append(container.Occurrences, position)
+	}
+	return container
+}
+
+func (prs *ParserEngine) getContextForLine(line int, cache map[int]string) string {
+	if context, ok := cache[line]; ok {
+		return context
+	}
+
+	var lines []string
+	for i := line - CONTEXT_WINDOW_LINES; i <= line+CONTEXT_WINDOW_LINES; i++ {
+		if i < 1 || i > len(prs.bytesToLine) {
+			continue
+		}
+		start := prs.bytesToLine[i-1]
+		end := len(prs.document)
+		if i < len(prs.bytesToLine) {
+			end = prs.bytesToLine[i] - 1 // without "\n"
+		}
+		if start > end || end > len(prs.document) {
+			continue
+		}
+		lines = append(lines, cutSnippet(string(bytes.TrimRight(prs.document[start:end], WHITESPACE))))
+	}
+
+	context := strings.Join(lines, "\n")
+	cache[line] = context
+	return context
+}
+
+func (prs *ParserEngine) fillContainerContexts(container ReportContainer, cache map[int]string) {
+	// style tags processed in parallel
+	sort.SliceStable(container.Occurrences, func(i, j int) bool {
+		return container.Occurrences[i].Line < container.Occurrences[j].Line
+	})
+	for i := range container.Occurrences {
+		container.Occurrences[i].Context = prs.getContextForLine(container.Occurrences[i].Line, cache)
+	}
+}
+
+// fillReportContexts add surrounding source lines for each finding
+func (prs *ParserEngine) fillReportContexts() {
+	cache := make(map[int]string)
+
+	for _, nestedData := range []map[string]map[string]ReportContainer{
+		prs.pr.HtmlTags,
+		prs.pr.HtmlAttributes,
+		prs.pr.CssProperties,
+		prs.pr.AtRuleCssStatements,
+		prs.pr.MsoMarkup,
+		prs.pr.UnknownNames,
+	} {
+		for _, items := range nestedData {
+			for _, item := range items {
+				prs.fillContainerContexts(item, cache)
+			}
+		}
+	}
+
+	for _, items := range []map[string]ReportContainer{
+		prs.pr.CssSelectorTypes,
+		prs.pr.CssDimentions,
+		prs.pr.CssFunctions,
+		prs.pr.CssPseudoSelectors,
+		prs.pr.ImgFormats,
+		prs.pr.LinkTypes,
+		prs.pr.HeadAudit,
+		prs.pr.DarkMode,
+		prs.pr.Accessibility,
+		prs.pr.Responsive,
+		prs.pr.TableIssues,
+		prs.pr.GmailIssues,
+	} {
+		for _, item := range items {
+			prs.fillContainerContexts(item, cache)
+		}
+	}
+
+	for _, item := range []ReportContainer{
+		prs.pr.CssVariables,
+		prs.pr.CssImportant,
+		prs.pr.Html5Doctype,
+	} {
+		prs.fillContainerContexts(item, cache)
+	}
+
+	for i := range prs.pr.HtmlDiagnostics {
+		prs.pr.HtmlDiagnostics[i].Context = prs.getContextForLine(prs.pr.HtmlDiagnostics[i].Line, cache)
+	}
+
+	for i := range prs.pr.StyleBlocks {
+		prs.pr.StyleBlocks[i].Context = prs.getContextForLine(prs.pr.StyleBlocks[i].Line, cache)
+	}
+
+	for i := range prs.pr.Images {
+		prs.pr.Images[i].Context = prs.getContextForLine(prs.pr.Images[i].Line, cache)
+	}
+
+	for i := range prs.pr.CssVariablesGraph.Definitions {
+		prs.pr.CssVariablesGraph.Definitions[i].Context = prs.getContextForLine(prs.pr.CssVariablesGraph.Definitions[i].Line, cache)
+	}
+
+	for i := range prs.pr.CssVariablesGraph.Usages {
+		prs.pr.CssVariablesGraph.Usages[i].Context = prs.getContextForLine(prs.pr.CssVariablesGraph.Usages[i].Line, cache)
+	}
+
+	for i := range prs.pr.Fonts.Stacks {
+		prs.pr.Fonts.Stacks[i].Context = prs.getContextForLine(prs.pr.Fonts.Stacks[i].Line, cache)
+	}
+
+	for i := range prs.pr.Fonts.FontFaces {
+		prs.pr.Fonts.FontFaces[i].Context = prs.getContextForLine(prs.pr.Fonts.FontFaces[i].Line, cache)
+	}
+
+	for i := range prs.pr.Fonts.Links {
+		prs.pr.Fonts.Links[i].Context = prs.getContextForLine(prs.pr.Fonts.Links[i].Line, cache)
+	}
+
+	for i := range prs.pr.ColorPalette.Colors {
+		prs.pr.ColorPalette.Colors[i].Context = prs.getContextForLine(prs.pr.ColorPalette.Colors[i].Line, cache)
+	}
+
+	for i := range prs.pr.TableLayout.Tables {
+		prs.pr.TableLayout.Tables[i].Context = prs.getContextForLine(prs.pr.TableLayout.Tables[i].Line, cache)
+	}
+
+	for i := range prs.pr.Contrast.Issues {
+		prs.pr.Contrast.Issues[i].Context = prs.getContextForLine(prs.pr.Contrast.Issues[i].Line, cache)
+	}
+}
+
+// htmlAttributesPositions use attribute source as snippet for findings, related to the attribute
+func htmlAttributesPositions(position SourcePosition, raw []byte) map[string]SourcePosition {
+	positions := make(map[string]SourcePosition)
+	for _, attr := range rawHtmlAttributes(raw) {
+		if _, ok := positions[attr.key]; !ok { // tokenizer keep first attribute
+			positions[attr.key] = position.withSnippet(attr.source)
+		}
+	}
+	return positions
+}
+
+func attributePosition(positions map[string]SourcePosition, attrKey string, position SourcePosition) SourcePosition {
+	if attrPosition, ok := positions[attrKey]; ok {
+		return attrPosition
+	}
+	return position
+}
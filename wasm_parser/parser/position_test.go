@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLSourcePositions(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		@media screen and (max-width: 600px) {
+			.one, .two { display: flex; }
+		}
+	</style>
+</head>
+<body>
+	<div class="header" style="background-image: url('logo.webp')">
+		<a href="#top">Top</a>
+	</div>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	var tests = []struct {
+		checkType string
+		got       []SourcePosition
+		want      []SourcePosition
+	}{
+		{
+			"CssProperties display flex",
+			report.CssProperties["display"]["flex"].Occurrences,
+			[]SourcePosition{{
+				Line:     5,
+				Snippet:  "display: flex",
+				Context:  "\t\t@media screen and (max-width: 600px) {\n\t\t\t.one, .two { display: flex; }\n\t\t}",
+				Selector: ".one,.two",
+				AtRules:  []string{"@media screen and (max-width:600px)"},
+			}},
+		},
+		{
+			"ImgFormats webp",
+			report.ImgFormats["webp"].Occurrences,
+			[]SourcePosition{{
+				Line:    10,
+				Snippet: "background-image: url('logo.webp')",
+				Context: "<body>\n\t<div class=\"header\" style=\"background-image: url('logo.webp')\">\n\t\t<a href=\"#top\">Top</a>",
+			}},
+		},
+		{
+			"LinkTypes anchor",
+			report.LinkTypes["anchor"].Occurrences,
+			[]SourcePosition{{
+				Line:    11,
+				Snippet: `href="#top"`,
+				Context: "\t<div class=\"header\" style=\"background-image: url('logo.webp')\">\n\t\t<a href=\"#top\">Top</a>\n\t</div>",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %#v, want %#v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLAtRulesContext(t *testing.T) {
+	html := `<html>
+<head>
+	<style media="screen and (max-width:600px)">
+		.mobile { display: flex; }
+	</style>
+	<style>
+		@supports (display: grid) {
+			@media (prefers-color-scheme: dark) {
+				.dark { display: grid; }
+			}
+		}
+		.base { display: grid; }
+		.mobile { display: flex; }
+	</style>
+</head>
+<body></body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	type atRulesContext struct {
+		Line    int
+		AtRules []string
+		Media   string
+	}
+
+	collect := func(container ReportContainer) []atRulesContext {
+		var result []atRulesContext
+		for _, occurrence := range container.Occurrences {
+			result = append(result, atRulesContext{occurrence.Line, occurrence.AtRules, occurrence.Media})
+		}
+		return result
+	}
+
+	var tests = []struct {
+		checkType string
+		got       []atRulesContext
+		want      []atRulesContext
+	}{
+		{
+			"CssProperties display grid",
+			collect(report.CssProperties["display"]["grid"]),
+			[]atRulesContext{
+				{9, []string{"@supports (display:grid)", "@media (prefers-color-scheme:dark)"}, ""},
+				{12, nil, ""},
+			},
+		},
+		{
+			"CssProperties display flex",
+			collect(report.CssProperties["display"]["flex"]),
+			[]atRulesContext{
+				{4, nil, "screen and (max-width:600px)"},
+				{13, nil, ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+
+	if report.CssProperties["display"]["grid"].OnlyConditional {
+		t.Errorf("CssProperties display grid: expected to be used without conditions")
+	}
+	if !report.AtRuleCssStatements["@media"][""].OnlyConditional {
+		t.Errorf("AtRuleCssStatements @media: expected to be used only inside of conditions")
+	}
+}
+
+func TestReportFromHTMLSiblingAtRulesContext(t *testing.T) {
+	html := `<style>
+	@media screen {
+		@supports (display: grid) {
+			.grid { display: grid; }
+		}
+		@supports (display: flex) {
+			.flex { display: flex; }
+		}
+	}
+</style>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	var tests = []struct {
+		checkType string
+		container ReportContainer
+		want      []string
+	}{
+		{"display grid", report.CssProperties["display"]["grid"], []string{"@media screen", "@supports (display:grid)"}},
+		{"display flex", report.CssProperties["display"]["flex"], []string{"@media screen", "@supports (display:flex)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			if len(tt.container.Occurrences) != 1 {
+				t.Fatalf("%s: got %d occurrences, want 1", tt.checkType, len(tt.container.Occurrences))
+			}
+			if got := tt.container.Occurrences[0].AtRules; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, got, tt.want)
+			}
+		})
+	}
+}
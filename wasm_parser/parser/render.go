@@ -0,0 +1,554 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// template engines for rendering before analysis
+const (
+	TEMPLATE_ENGINE_TEXT = "text" // text/template
+	TEMPLATE_ENGINE_HTML = "html" // html/template, escape output by context
+
+	LIMIT_TEMPLATE_INCLUDE_DEPTH = 10
+	// text/template print missing map values as "<no value>", which looks like html tag,
+	// so output of actions piped to this func, which print missing values as empty string
+	TEMPLATE_VALUE_FUNC = "vmailValue"
+)
+
+// TemplateFixture is data for one rendered variant, JSON encoded
+type TemplateFixture struct {
+	Name string
+	Data []byte
+}
+
+type RenderOptions struct {
+	// TEMPLATE_ENGINE_TEXT (default) or TEMPLATE_ENGINE_HTML
+	Engine   string
+	Fixtures []TemplateFixture
+	// options for analysis of each rendered variant
+	Parser ParserOptions
+}
+
+// TemplateVariant is result of rendering with one fixture
+type TemplateVariant struct {
+	Fixture string       `json:"fixture"`
+	Report  *ParseReport `json:"report"`
+	Error   string       `json:"error,omitempty"` // execution error, variant not analysed
+}
+
+// TemplateFinding is report item, which found at least in one rendered variant
+type TemplateFinding struct {
+	Section  string           `json:"section"`
+	Key      string           `json:"key"`
+	Value    string           `json:"value,omitempty"`
+	Rules    interface{}      `json:"rules"` // nil for items of report lists, like html diagnostics
+	Fixtures []string         `json:"fixtures"`
+	Lines    map[string][]int `json:"lines"` // fixture -> lines in rendered html
+}
+
+// UndefinedVariable is template field or variable, which missing in fixture data
+type UndefinedVariable struct {
+	Name     string   `json:"name"`
+	Line     int      `json:"line"` // line in template source
+	Fixtures []string `json:"fixtures"`
+}
+
+type TemplateReport struct {
+	Variants           []TemplateVariant   `json:"variants"`
+	Findings           []TemplateFinding   `json:"findings"`
+	UndefinedVariables []UndefinedVariable `json:"undefined_variables"`
+}
+
+// templateRenderer execute template with parsed fixture data
+type templateRenderer interface {
+	Execute(wr *bytes.Buffer, data interface{}) error
+}
+
+type textTemplateRenderer struct{ tmpl *template.Template }
+
+func (r textTemplateRenderer) Execute(wr *bytes.Buffer, data interface{}) error {
+	return r.tmpl.Execute(wr, data)
+}
+
+// templateValue replace missing value with empty string, missing values reported as undefined variables
+func templateValue(value interface{}) interface{} {
+	if value == nil {
+		return ""
+	}
+	return value
+}
+
+// pipeTemplateValues add TEMPLATE_VALUE_FUNC to the end of all printing actions
+func pipeTemplateValues(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			pipeTemplateValues(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return // assignment print nothing
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(TEMPLATE_VALUE_FUNC).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		pipeTemplateValues(tree, n.List)
+		pipeTemplateValues(tree, n.ElseList)
+	case *parse.RangeNode:
+		pipeTemplateValues(tree, n.List)
+		pipeTemplateValues(tree, n.ElseList)
+	case *parse.WithNode:
+		pipeTemplateValues(tree, n.List)
+		pipeTemplateValues(tree, n.ElseList)
+	}
+}
+
+type htmlTemplateRenderer struct{ tmpl *htmltemplate.Template }
+
+func (r htmlTemplateRenderer) Execute(wr *bytes.Buffer, data interface{}) error {
+	return r.tmpl.Execute(wr, data)
+}
+
+func newTemplateRenderer(engine string, source string) (templateRenderer, error) {
+	switch engine {
+	case "", TEMPLATE_ENGINE_TEXT:
+		tmpl, err := template.New("email").Funcs(template.FuncMap{TEMPLATE_VALUE_FUNC: templateValue}).Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, definedTmpl := range tmpl.Templates() {
+			if definedTmpl.Tree != nil {
+				pipeTemplateValues(definedTmpl.Tree, definedTmpl.Tree.Root)
+			}
+		}
+		return textTemplateRenderer{tmpl: tmpl}, nil
+	case TEMPLATE_ENGINE_HTML:
+		tmpl, err := htmltemplate.New("email").Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		return htmlTemplateRenderer{tmpl: tmpl}, nil
+	}
+	return nil, fmt.Errorf("unsupported template engine: %s", engine)
+}
+
+// templateWalker look for fields and variables, which cannot be resolved in fixture data
+type templateWalker struct {
+	tmpl      *template.Template
+	source    string
+	undefined map[string]int // name -> line
+	depth     int
+}
+
+// templateDot is value of dot or variable, unknown if it cannot be calculated statically
+type templateDot struct {
+	value interface{}
+	known bool
+}
+
+func (w *templateWalker) line(node parse.Node) int {
+	offset := min(int(node.Position()), len(w.source))
+	return strings.Count(w.source[:offset], "\n") + 1
+}
+
+func (w *templateWalker) markUndefined(name string, node parse.Node) {
+	if _, ok := w.undefined[name]; !ok {
+		w.undefined[name] = w.line(node)
+	}
+}
+
+// resolveTemplateFields follow fields chain in JSON data
+func resolveTemplateFields(dot templateDot, fields []string) (templateDot, bool) {
+	for _, field := range fields {
+		if !dot.known {
+			return dot, true
+		}
+		switch value := dot.value.(type) {
+		case map[string]interface{}:
+			fieldValue, ok := value[field]
+			if !ok {
+				return templateDot{}, false
+			}
+			dot = templateDot{value: fieldValue, known: true}
+		default:
+			return templateDot{}, false
+		}
+	}
+	return dot, true
+}
+
+func (w *templateWalker) resolveNode(node parse.Node, dot templateDot, vars map[string]templateDot) templateDot {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		value, ok := resolveTemplateFields(dot, n.Ident)
+		if !ok {
+			w.markUndefined(n.String(), n)
+		}
+		return value
+	case *parse.VariableNode:
+		variable, ok := vars[n.Ident[0]]
+		if !ok {
+			return templateDot{}
+		}
+		value, ok := resolveTemplateFields(variable, n.Ident[1:])
+		if !ok {
+			w.markUndefined(n.String(), n)
+		}
+		return value
+	case *parse.PipeNode:
+		return w.resolvePipe(n, dot, vars)
+	case *parse.ChainNode:
+		w.resolveNode(n.Node, dot, vars)
+	}
+	return templateDot{}
+}
+
+// resolvePipe check all arguments of pipe and return its value, if pipe is single field or variable
+func (w *templateWalker) resolvePipe(pipe *parse.PipeNode, dot templateDot, vars map[string]templateDot) templateDot {
+	if pipe == nil {
+		return templateDot{}
+	}
+
+	result := templateDot{}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			result = w.resolveNode(arg, dot, vars)
+		}
+	}
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		result = templateDot{}
+	}
+
+	for _, variable := range pipe.Decl {
+		vars[variable.Ident[0]] = result
+	}
+	return result
+}
+
+// resolveCondition resolve pipe of if and with actions, missing single field is not reported,
+// false returned in this case
+func (w *templateWalker) resolveCondition(pipe *parse.PipeNode, dot templateDot, vars map[string]templateDot) (templateDot, bool) {
+	if len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		var fields []string
+		variable := dot
+		switch n := pipe.Cmds[0].Args[0].(type) {
+		case *parse.FieldNode:
+			fields = n.Ident
+		case *parse.VariableNode:
+			value, ok := vars[n.Ident[0]]
+			if !ok {
+				return templateDot{}, true
+			}
+			variable, fields = value, n.Ident[1:]
+		}
+		if len(fields) > 0 {
+			value, ok := resolveTemplateFields(variable, fields)
+			for _, decl := range pipe.Decl {
+				vars[decl.Ident[0]] = value
+			}
+			return value, ok
+		}
+	}
+	return w.resolvePipe(pipe, dot, vars), true
+}
+
+// rangeElement return first element of slice or map, unknown if it is empty
+func rangeElement(dot templateDot) templateDot {
+	if !dot.known {
+		return dot
+	}
+	switch value := dot.value.(type) {
+	case []interface{}:
+		if len(value) > 0 {
+			return templateDot{value: value[0], known: true}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			return templateDot{value: value[keys[0]], known: true}
+		}
+	}
+	return templateDot{}
+}
+
+func copyTemplateVars(vars map[string]templateDot) map[string]templateDot {
+	copied := make(map[string]templateDot, len(vars))
+	for key, value := range vars {
+		copied[key] = value
+	}
+	return copied
+}
+
+func (w *templateWalker) walk(node parse.Node, dot templateDot, vars map[string]templateDot) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, dot, vars)
+		}
+	case *parse.ActionNode:
+		w.resolvePipe(n.Pipe, dot, vars)
+	case *parse.IfNode:
+		// {{if .promo}} is existence check, missing value only skip body
+		if _, ok := w.resolveCondition(n.Pipe, dot, vars); ok {
+			w.walk(n.List, dot, copyTemplateVars(vars))
+		}
+		w.walk(n.ElseList, dot, copyTemplateVars(vars))
+	case *parse.WithNode:
+		scope := copyTemplateVars(vars)
+		if value, ok := w.resolveCondition(n.Pipe, dot, scope); ok && (!value.known || value.value != nil) {
+			w.walk(n.List, value, scope)
+		}
+		w.walk(n.ElseList, dot, copyTemplateVars(vars))
+	case *parse.RangeNode:
+		scope := copyTemplateVars(vars)
+		element := rangeElement(w.resolvePipe(n.Pipe, dot, scope))
+		for i, variable := range n.Pipe.Decl {
+			if i == len(n.Pipe.Decl)-1 {
+				scope[variable.Ident[0]] = element
+			} else {
+				scope[variable.Ident[0]] = templateDot{}
+			}
+		}
+		w.walk(n.List, element, scope)
+		w.walk(n.ElseList, dot, copyTemplateVars(vars))
+	case *parse.TemplateNode:
+		value := w.resolvePipe(n.Pipe, dot, vars)
+		included := w.tmpl.Lookup(n.Name)
+		if included == nil || included.Tree == nil || w.depth >= LIMIT_TEMPLATE_INCLUDE_DEPTH {
+			return
+		}
+		w.depth += 1
+		w.walk(included.Tree.Root, value, map[string]templateDot{"$": value})
+		w.depth -= 1
+	}
+}
+
+// findTemplateUndefinedVariables return fields and variables, missing in data, with lines in source
+func findTemplateUndefinedVariables(source string, data interface{}) (map[string]int, error) {
+	// html/template use the same syntax, so text/template tree is enough for analysis
+	tmpl, err := template.New("email").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	walker := &templateWalker{
+		tmpl:      tmpl,
+		source:    source,
+		undefined: make(map[string]int),
+	}
+	root := templateDot{value: data, known: true}
+	walker.walk(tmpl.Tree.Root, root, map[string]templateDot{"$": root})
+	return walker.undefined, nil
+}
+
+// templateFindingsCollector merge findings of rendered variants
+type templateFindingsCollector struct {
+	findings map[string]*TemplateFinding
+}
+
+func (c *templateFindingsCollector) finding(fixture, section, key, value string, rules interface{}) *TemplateFinding {
+	findingKey := strings.Join([]string{section, key, value}, "||")
+	finding, ok := c.findings[findingKey]
+	if !ok {
+		finding = &TemplateFinding{
+			Section: section,
+			Key:     key,
+			Value:   value,
+			Rules:   rules,
+			Lines:   make(map[string][]int),
+		}
+		c.findings[findingKey] = finding
+	}
+
+	if _, ok := finding.Lines[fixture]; !ok {
+		finding.Fixtures = append(finding.Fixtures, fixture)
+		finding.Lines[fixture] = []int{}
+	}
+	return finding
+}
+
+func (c *templateFindingsCollector) add(fixture, section, key, value string, container ReportContainer) {
+	finding := c.finding(fixture, section, key, value, container.Rules)
+	for line := range container.Lines {
+		finding.Lines[fixture] = append(finding.Lines[fixture], line)
+	}
+	sort.Ints(finding.Lines[fixture])
+}
+
+// addLine merge item of report list, which has no rules
+func (c *templateFindingsCollector) addLine(fixture, section, key, value string, line int) {
+	finding := c.finding(fixture, section, key, value, nil)
+	if i, found := slices.BinarySearch(finding.Lines[fixture], line); !found {
+		finding.Lines[fixture] = slices.Insert(finding.Lines[fixture], i, line)
+	}
+}
+
+func (c *templateFindingsCollector) collect(fixture string, pr *ParseReport) {
+	for section, nestedData := range map[string]map[string]map[string]ReportContainer{
+		"html_tags":              pr.HtmlTags,
+		"html_attributes":        pr.HtmlAttributes,
+		"css_properties":         pr.CssProperties,
+		"at_rule_css_statements": pr.AtRuleCssStatements,
+		"mso_markup":             pr.MsoMarkup,
+		"unknown_names":          pr.UnknownNames,
+	} {
+		for key, items := range nestedData {
+			for value, item := range items {
+				c.add(fixture, section, key, value, item)
+			}
+		}
+	}
+
+	for section, items := range map[string]map[string]ReportContainer{
+		"css_selector_types":   pr.CssSelectorTypes,
+		"css_dimentions":       pr.CssDimentions,
+		"css_functions":        pr.CssFunctions,
+		"css_pseudo_selectors": pr.CssPseudoSelectors,
+		"img_formats":          pr.ImgFormats,
+		"link_types":           pr.LinkTypes,
+		"head_audit":           pr.HeadAudit,
+		"dark_mode":            pr.DarkMode,
+		"accessibility":        pr.Accessibility,
+		"responsive":           pr.Responsive,
+		"table_issues":         pr.TableIssues,
+		"gmail_issues":         pr.GmailIssues,
+	} {
+		for key, item := range items {
+			c.add(fixture, section, key, "", item)
+		}
+	}
+
+	for section, item := range map[string]ReportContainer{
+		"css_variables": pr.CssVariables,
+		"css_important": pr.CssImportant,
+		"html5_doctype": pr.Html5Doctype,
+	} {
+		if len(item.Lines) > 0 {
+			c.add(fixture, section, "", "", item)
+		}
+	}
+
+	for _, diagnostic := range pr.HtmlDiagnostics {
+		c.addLine(fixture, "html_diagnostics", diagnostic.Type, diagnostic.Tag, diagnostic.Line)
+	}
+	for _, issue := range pr.Contrast.Issues {
+		c.addLine(fixture, "contrast", issue.Scheme, issue.Level, issue.Line)
+	}
+	for _, image := range pr.Images {
+		c.addLine(fixture, "images", image.Format, image.Url, image.Line)
+	}
+	for _, block := range pr.Gmail.StyleBlocks {
+		for _, reason := range block.Reasons {
+			c.addLine(fixture, "gmail_style_blocks", reason, "", block.Line)
+		}
+	}
+	for _, table := range pr.TableLayout.Tables {
+		c.addLine(fixture, "table_layout", "depth", strconv.Itoa(table.Depth), table.Line)
+	}
+}
+
+func (c *templateFindingsCollector) sortedFindings() []TemplateFinding {
+	findings := make([]TemplateFinding, 0, len(c.findings))
+	for _, finding := range c.findings {
+		findings = append(findings, *finding)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Section != findings[j].Section {
+			return findings[i].Section < findings[j].Section
+		}
+		if findings[i].Key != findings[j].Key {
+			return findings[i].Key < findings[j].Key
+		}
+		return findings[i].Value < findings[j].Value
+	})
+	return findings
+}
+
+// ReportFromTemplate render Go template with each fixture and analyse every rendered html,
+// findings merged and tagged by fixtures, which produced them
+func ReportFromTemplate(source []byte, options RenderOptions) (*TemplateReport, error) {
+	renderer, err := newTemplateRenderer(options.Engine, string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		report    = &TemplateReport{}
+		collector = templateFindingsCollector{findings: make(map[string]*TemplateFinding)}
+		undefined = make(map[string]*UndefinedVariable)
+	)
+
+	for _, fixture := range options.Fixtures {
+		var data interface{}
+		if err := json.Unmarshal(fixture.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", fixture.Name, err)
+		}
+
+		undefinedVariables, err := findTemplateUndefinedVariables(string(source), data)
+		if err != nil {
+			return nil, err
+		}
+		for name, line := range undefinedVariables {
+			key := fmt.Sprintf("%s:%d", name, line)
+			if _, ok := undefined[key]; !ok {
+				undefined[key] = &UndefinedVariable{Name: name, Line: line}
+			}
+			undefined[key].Fixtures = append(undefined[key].Fixtures, fixture.Name)
+		}
+
+		var rendered bytes.Buffer
+		if err := renderer.Execute(&rendered, data); err != nil {
+			report.Variants = append(report.Variants, TemplateVariant{Fixture: fixture.Name, Error: err.Error()})
+			continue
+		}
+
+		parserOptions := options.Parser
+		if len(parserOptions.FileName) == 0 {
+			parserOptions.FileName = fixture.Name
+		}
+		variantReport, err := ReportFromHTMLWithOptions(rendered.Bytes(), parserOptions)
+		if err != nil {
+			report.Variants = append(report.Variants, TemplateVariant{Fixture: fixture.Name, Error: err.Error()})
+			continue
+		}
+		report.Variants = append(report.Variants, TemplateVariant{Fixture: fixture.Name, Report: variantReport})
+		collector.collect(fixture.Name, variantReport)
+	}
+
+	report.Findings = collector.sortedFindings()
+	for _, variable := range undefined {
+		report.UndefinedVariables = append(report.UndefinedVariables, *variable)
+	}
+	sort.Slice(report.UndefinedVariables, func(i, j int) bool {
+		if report.UndefinedVariables[i].Line != report.UndefinedVariables[j].Line {
+			return report.UndefinedVariables[i].Line < report.UndefinedVariables[j].Line
+		}
+		return report.UndefinedVariables[i].Name < report.UndefinedVariables[j].Name
+	})
+
+	return report, nil
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFindTemplateUndefinedVariables(t *testing.T) {
+	var tests = []struct {
+		name   string
+		source string
+		data   string
+		want   map[string]int
+	}{
+		{
+			"fields and variables",
+			"<p>{{.user.name}}</p>\n<p>{{.user.email}}</p>\n{{$plan := .plan}}{{$plan.title}}",
+			`{"user": {"name": "Ann"}, "plan": {"price": 1}}`,
+			map[string]int{".user.email": 2, "$plan.title": 3},
+		},
+		{
+			"range over first element",
+			"{{range .items}}\n{{.title}} {{.price}}\n{{end}}{{range $i, $item := .items}}{{$item.url}}{{end}}",
+			`{"items": [{"title": "Book"}]}`,
+			map[string]int{".price": 2, "$item.url": 3},
+		},
+		{
+			"empty range and existence checks are skipped",
+			"{{range .items}}{{.missing}}{{end}}{{if .promo}}{{.promo.code}}{{end}}{{with .coupon}}{{.value}}{{else}}{{.fallback}}{{end}}",
+			`{"items": []}`,
+			map[string]int{".fallback": 1},
+		},
+		{
+			"included template",
+			"{{define \"footer\"}}{{.address}}{{end}}{{template \"footer\" .company}}",
+			`{"company": {"name": "ACME"}}`,
+			map[string]int{".address": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data interface{}
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			got, err := findTemplateUndefinedVariables(tt.source, data)
+			if err != nil {
+				t.Fatalf("findTemplateUndefinedVariables() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportFromTemplate(t *testing.T) {
+	source := `<html><body>
+{{range .banners}}<picture><img src="{{.src}}"></picture>{{end}}
+<p>Hello {{.name}}</p>
+</body></html>`
+
+	for _, engine := range []string{TEMPLATE_ENGINE_TEXT, TEMPLATE_ENGINE_HTML} {
+		t.Run(engine, func(t *testing.T) {
+			report, err := ReportFromTemplate([]byte(source), RenderOptions{
+				Engine: engine,
+				Fixtures: []TemplateFixture{
+					{Name: "empty.json", Data: []byte(`{"name": "Ann"}`)},
+					{Name: "banners.json", Data: []byte(`{"banners": [{"src": "a.webp"}]}`)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("ReportFromTemplate() error = %v", err)
+			}
+
+			if len(report.Variants) != 2 || report.Variants[0].Report == nil || report.Variants[1].Report == nil {
+				t.Fatalf("expected two analysed variants, got %+v", report.Variants)
+			}
+
+			var pictureFixtures []string
+			for _, finding := range report.Findings {
+				if finding.Section == "html_tags" && finding.Key == "picture" {
+					pictureFixtures = finding.Fixtures
+				}
+			}
+			if !reflect.DeepEqual(pictureFixtures, []string{"banners.json"}) {
+				t.Errorf("picture fixtures: got %v, want [banners.json]", pictureFixtures)
+			}
+
+			var imageFinding TemplateFinding
+			for _, finding := range report.Findings {
+				if finding.Section == "images" && finding.Key == "webp" {
+					imageFinding = finding
+				}
+			}
+			if imageFinding.Value != "a.webp" || !reflect.DeepEqual(imageFinding.Lines, map[string][]int{"banners.json": {2}}) {
+				t.Errorf("image finding: got %+v, want a.webp on line 2 of banners.json", imageFinding)
+			}
+
+			want := []UndefinedVariable{{Name: ".banners", Line: 2, Fixtures: []string{"empty.json"}}, {Name: ".name", Line: 3, Fixtures: []string{"banners.json"}}}
+			if !reflect.DeepEqual(report.UndefinedVariables, want) {
+				t.Errorf("undefined variables: got %+v, want %+v", report.UndefinedVariables, want)
+			}
+		})
+	}
+
+	if _, err := ReportFromTemplate([]byte(source), RenderOptions{Engine: "mustache"}); err == nil {
+		t.Errorf("expected error for unsupported engine")
+	}
+}
+
+func TestTextTemplateRendererMissingValues(t *testing.T) {
+	source := `{{define "footer"}}<p>{{.company}}</p>{{end}}<p>{{.name}}</p><pre>&lt;no value&gt; <no value></pre>{{if .vip}}{{.badge}}{{end}}{{range .items}}<i>{{.title}}</i>{{end}}{{template "footer" .}}`
+	renderer, err := newTemplateRenderer(TEMPLATE_ENGINE_TEXT, source)
+	if err != nil {
+		t.Fatalf("newTemplateRenderer() error = %v", err)
+	}
+
+	data := map[string]interface{}{
+		"vip":   true,
+		"items": []interface{}{map[string]interface{}{"title": "One"}, map[string]interface{}{}},
+	}
+	var wr bytes.Buffer
+	if err := renderer.Execute(&wr, data); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := `<p></p><pre>&lt;no value&gt; <no value></pre><i>One</i><i></i><p></p>`
+	if wr.String() != want {
+		t.Errorf("Execute(): got %q, want %q", wr.String(), want)
+	}
+}
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	parse "github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+const (
+	// default max width of email layout in px
+	DEFAULT_RESPONSIVE_MAX_WIDTH = 600
+	// smallest font size in px, which mobile clients do not enlarge
+	RESPONSIVE_MIN_MOBILE_FONT_SIZE = 14
+	// media features, which used for mobile layouts
+	RESPONSIVE_WIDTH_MEDIA_KEYWORD = "width"
+)
+
+// responsive issues
+const (
+	RESPONSIVE_FIXED_WIDTH        = "fixed_width_without_max_width"
+	RESPONSIVE_IMAGE_DIMENSIONS   = "image_without_dimensions"
+	RESPONSIVE_SMALL_MOBILE_FONT  = "small_mobile_font_size"
+	RESPONSIVE_MEDIA_QUERIES_ONLY = "media_queries_only_layout"
+)
+
+var (
+	// tables, images and containers, which can be wider than mobile screen
+	responsiveWidthElements = map[a.Atom]bool{
+		a.Table: true, a.Td: true, a.Th: true, a.Img: true, a.Div: true, a.Center: true,
+		a.Section: true, a.Article: true, a.Header: true, a.Footer: true, a.Main: true,
+	}
+
+	responsiveRulesDB = map[string]map[string]interface{}{}
+)
+
+// initResponsiveRules use stats of max-width, width and @media, missing viewport meta reported by head audit
+func initResponsiveRules() {
+	maxWidthRule := rulesDB.CssProperties["max-width"][""]
+	mediaRule := rulesDB.AtRuleCssStatements["@media"][""]
+
+	responsiveRulesDB = map[string]map[string]interface{}{
+		RESPONSIVE_FIXED_WIDTH:        makeClientsIssueRule("Fixed width without max-width", "Element is wider than configured max width and has no max-width fallback, so mobile clients show horizontal scroll or zoom out email.", SEVERITY_WARNING, maxWidthRule),
+		RESPONSIVE_IMAGE_DIMENSIONS:   makeClientsIssueRule("Image without width or height", "Without width and height attributes clients can not reserve space for image before it loaded (or when images blocked), Outlook also renders image in its original size.", SEVERITY_WARNING, rulesDB.HtmlAttributes["width"][""]),
+		RESPONSIVE_SMALL_MOBILE_FONT:  makeClientsIssueRule("Small font size on mobile", "Text smaller than 14px is hard to read on mobile, iOS Mail enlarges it automatically and can break layout.", SEVERITY_INFO, nil),
+		RESPONSIVE_MEDIA_QUERIES_ONLY: makeClientsIssueRule("Layout depends only on media queries", "Email has no fluid widths (percent widths or max-width) outside of media queries, so clients without media queries support show desktop layout on mobile.", SEVERITY_WARNING, mediaRule),
+	}
+}
+
+type responsiveWidth struct {
+	keys     []string // selectors parts or element class, id and tag
+	position SourcePosition
+}
+
+type responsiveState struct {
+	fixedWidths  []responsiveWidth // widths over max width
+	maxWidths    map[string]bool   // selectors parts with max-width fallback
+	mediaQueries []SourcePosition  // width based media queries
+	fluid        bool              // fluid widths outside of media queries
+}
+
+func (prs *ParserEngine) saveToReportResponsive(issue string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.Responsive, responsiveRulesDB, issue, position)
+}
+
+func (prs *ParserEngine) responsiveMaxWidth() float64 {
+	if prs.options.MaxWidth > 0 {
+		return float64(prs.options.MaxWidth)
+	}
+	return DEFAULT_RESPONSIVE_MAX_WIDTH
+}
+
+// isWidthMediaPosition return true for css inside of media queries by screen size
+func isWidthMediaPosition(position SourcePosition) bool {
+	if strings.Contains(strings.ToLower(position.Media), RESPONSIVE_WIDTH_MEDIA_KEYWORD) {
+		return true
+	}
+	for _, atRule := range position.AtRules {
+		if strings.Contains(strings.ToLower(atRule), RESPONSIVE_WIDTH_MEDIA_KEYWORD) {
+			return true
+		}
+	}
+	return false
+}
+
+// cssWidthValue return width in px, or fluid flag for percent values
+func cssWidthValue(values []css.Token) (float64, bool, bool) {
+	for _, value := range values {
+		if value.TokenType == css.PercentageToken {
+			return 0, true, true
+		}
+		if size, ok := cssFontSizeInPx(value); ok {
+			return size, false, true
+		}
+	}
+	return 0, false, false
+}
+
+// htmlWidthValue parse width attribute: "600", "600px" or "100%"
+func htmlWidthValue(value string) (float64, bool, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.HasSuffix(value, "%") {
+		return 0, true, true
+	}
+	width, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64)
+	if err != nil {
+		return 0, false, false
+	}
+	return width, false, true
+}
+
+func (prs *ParserEngine) setResponsiveFluid() {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.responsive.fluid = true
+}
+
+func (prs *ParserEngine) addResponsiveFixedWidth(keys []string, position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.responsive.fixedWidths = append(prs.responsive.fixedWidths, responsiveWidth{keys, position})
+}
+
+// checkResponsiveAtRule collect media queries by screen size
+func (prs *ParserEngine) checkResponsiveAtRule(atRule string, values []css.Token, position SourcePosition) {
+	if strings.ToLower(atRule) != "@media" || !strings.Contains(strings.ToLower(cssTokensToString(values)), RESPONSIVE_WIDTH_MEDIA_KEYWORD) {
+		return
+	}
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.responsive.mediaQueries = append(prs.responsive.mediaQueries, position)
+}
+
+// checkResponsiveDeclaration find fixed widths and small font sizes in style blocks and inline styles
+func (prs *ParserEngine) checkResponsiveDeclaration(propertyKey string, values []css.Token, position SourcePosition) {
+	propertyKey = strings.ToLower(propertyKey)
+
+	switch propertyKey {
+	case "font-size":
+		if size, ok := cssFontSizeInPx(firstNonWhitespaceToken(values)); ok && size > 0 && size < RESPONSIVE_MIN_MOBILE_FONT_SIZE {
+			prs.saveToReportResponsive(RESPONSIVE_SMALL_MOBILE_FONT, position)
+		}
+		return
+	case "width", "max-width":
+	default:
+		return
+	}
+
+	// inline styles checked with element attributes in checkResponsiveHtml, media queries are mobile layout itself
+	if len(position.Selector) == 0 || isWidthMediaPosition(position) {
+		return
+	}
+
+	width, fluid, ok := cssWidthValue(values)
+	if propertyKey == "max-width" {
+		prs.mx.Lock()
+		defer prs.mx.Unlock()
+
+		// fixed max-width is fallback for wide element, but not fluid layout
+		if fluid {
+			prs.responsive.fluid = true
+		}
+		for _, key := range cssSelectorKeys(position.Selector) {
+			prs.responsive.maxWidths[key] = true
+		}
+		return
+	}
+	if fluid {
+		prs.setResponsiveFluid()
+		return
+	}
+	if ok && width > prs.responsiveMaxWidth() {
+		prs.addResponsiveFixedWidth(cssSelectorKeys(position.Selector), position)
+	}
+}
+
+func firstNonWhitespaceToken(values []css.Token) css.Token {
+	for _, value := range values {
+		if value.TokenType != css.WhitespaceToken {
+			return value
+		}
+	}
+	return css.Token{TokenType: css.ErrorToken}
+}
+
+// checkResponsiveHtml find fixed widths in attributes and inline styles, images without dimensions
+func (prs *ParserEngine) checkResponsiveHtml(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	atom := a.Lookup([]byte(tagName))
+
+	switch atom {
+	case a.Img:
+		if !hasHtmlAttribute(attrs, "width") || !hasHtmlAttribute(attrs, "height") {
+			prs.saveToReportResponsive(RESPONSIVE_IMAGE_DIMENSIONS, position)
+		}
+	case a.Font:
+		if size, ok := htmlFontSizes[strings.TrimPrefix(getHtmlAttributeValue(attrs, "size"), "+")]; ok && size < RESPONSIVE_MIN_MOBILE_FONT_SIZE {
+			prs.saveToReportResponsive(RESPONSIVE_SMALL_MOBILE_FONT, attributePosition(attrsPositions, "size", position))
+		}
+	}
+
+	if !responsiveWidthElements[atom] {
+		return
+	}
+
+	var (
+		styleWidth    float64
+		hasMaxWidth   bool
+		fixedPosition *SourcePosition
+	)
+
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(getHtmlAttributeValue(attrs, "style"))), true)
+	for {
+		gt, _, data := p.Next()
+		if gt == css.ErrorGrammar {
+			break
+		}
+		if gt != css.DeclarationGrammar {
+			continue
+		}
+		switch strings.ToLower(string(data)) {
+		case "max-width":
+			hasMaxWidth = true
+			if _, fluid, _ := cssWidthValue(p.Values()); fluid {
+				prs.setResponsiveFluid()
+			}
+		case "width":
+			if width, fluid, ok := cssWidthValue(p.Values()); fluid {
+				prs.setResponsiveFluid()
+			} else if ok {
+				styleWidth = width
+			}
+		}
+	}
+
+	maxWidth := prs.responsiveMaxWidth()
+	if width, fluid, ok := htmlWidthValue(getHtmlAttributeValue(attrs, "width")); fluid {
+		prs.setResponsiveFluid()
+	} else if ok && width > maxWidth {
+		attrPosition := attributePosition(attrsPositions, "width", position)
+		fixedPosition = &attrPosition
+	}
+	if styleWidth > maxWidth {
+		stylePosition := attributePosition(attrsPositions, "style", position)
+		fixedPosition = &stylePosition
+	}
+
+	// max-width from style blocks known only after whole document processed
+	if !hasMaxWidth && fixedPosition != nil {
+		prs.addResponsiveFixedWidth(htmlElementKeys(tagName, attrs), *fixedPosition)
+	}
+}
+
+// checkResponsive report issues, which known only after whole document processed
+func (prs *ParserEngine) checkResponsive() {
+	for _, fixedWidth := range prs.responsive.fixedWidths {
+		hasMaxWidth := false
+		for _, key := range fixedWidth.keys {
+			if prs.responsive.maxWidths[key] {
+				hasMaxWidth = true
+				break
+			}
+		}
+		if !hasMaxWidth {
+			prs.saveToReportResponsive(RESPONSIVE_FIXED_WIDTH, fixedWidth.position)
+		}
+	}
+
+	if !prs.responsive.fluid {
+		for _, position := range prs.responsive.mediaQueries {
+			prs.saveToReportResponsive(RESPONSIVE_MEDIA_QUERIES_ONLY, position)
+		}
+	}
+}
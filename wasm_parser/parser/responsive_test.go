@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLResponsive(t *testing.T) {
+	var tests = []struct {
+		name    string
+		html    string
+		options ParserOptions
+		want    map[string]map[int]bool
+	}{
+		{
+			"fluid email",
+			`<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		.wrapper { width: 640px; max-width: 100%; }
+		@media (max-width: 600px) { .column { width: 100% !important; } }
+	</style>
+</head>
+<body style="font-size: 16px">
+	<table width="100%"><tr><td>
+		<div class="wrapper" style="width: 640px; max-width: 100%">
+			<img src="hero.png" width="600" height="300" alt="">
+		</div>
+	</td></tr></table>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{},
+		},
+		{
+			"fixed layout",
+			`<html>
+<head>
+	<style>
+		.wrapper { width: 700px; }
+		.legal { font-size: 12px; }
+		@media (max-width: 600px) { .wrapper { width: 320px !important; } }
+	</style>
+</head>
+<body>
+	<table width="700"><tr><td class="wrapper">
+		<img src="hero.png" width="700">
+		<p style="font-size: 11px">Legal</p>
+	</td></tr></table>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{
+				RESPONSIVE_FIXED_WIDTH:        {4: true, 10: true, 11: true},
+				RESPONSIVE_SMALL_MOBILE_FONT:  {5: true, 12: true},
+				RESPONSIVE_MEDIA_QUERIES_ONLY: {6: true},
+				RESPONSIVE_IMAGE_DIMENSIONS:   {11: true},
+			},
+		},
+		{
+			"max-width fallback from style block",
+			`<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		.wrapper { max-width: 100%; }
+	</style>
+</head>
+<body>
+	<table class="wrapper" width="640"><tr><td>Content</td></tr></table>
+	<table id="footer" width="640"><tr><td>Footer</td></tr></table>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{
+				RESPONSIVE_FIXED_WIDTH: {10: true},
+			},
+		},
+		{
+			"fixed max-width is not fluid",
+			`<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		.wrapper { width: 800px; max-width: 800px; }
+		@media (max-width: 600px) { .wrapper { width: 100% !important; } }
+	</style>
+</head>
+<body>
+	<div class="wrapper">Content</div>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{
+				RESPONSIVE_MEDIA_QUERIES_ONLY: {6: true},
+			},
+		},
+		{
+			"custom max width",
+			`<table width="700"><tr><td><img src="hero.png" width="700" height="350"></td></tr></table>`,
+			ParserOptions{Fragment: true, MaxWidth: 720},
+			map[string]map[int]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTMLWithOptions([]byte(tt.html), tt.options)
+			if err != nil {
+				t.Fatalf("ReportFromHTMLWithOptions() error = %v", err)
+			}
+
+			got := make(map[string]map[int]bool)
+			for issue, item := range report.Responsive {
+				got[issue] = item.Lines
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+// style block locations
+const (
+	STYLE_LOCATION_HEAD  = "head"
+	STYLE_LOCATION_BODY  = "body"
+	STYLE_LOCATION_TABLE = "table"
+)
+
+// style block issues
+const (
+	STYLE_ISSUE_IN_BODY      = "in_body"
+	STYLE_ISSUE_INSIDE_TABLE = "inside_table"
+	STYLE_ISSUE_NOT_FIRST    = "not_first_block"
+	STYLE_ISSUE_EMPTY        = "empty_block"
+)
+
+var styleBlockIssuesDescriptions = map[string]string{
+	STYLE_ISSUE_IN_BODY:      "<style> placed in <body>. Gmail on some platforms and other webmail clients strip such blocks, so its rules will be lost.",
+	STYLE_ISSUE_INSIDE_TABLE: "<style> placed inside of <table>. Some clients move or strip such blocks, so its rules will be lost.",
+	STYLE_ISSUE_NOT_FIRST:    "Some clients read only the first <style> block, so rules from this block will be lost there.",
+	STYLE_ISSUE_EMPTY:        "<style> block has no rules and can be removed.",
+}
+
+type StyleBlockIssue struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+type StyleBlock struct {
+	Index        int               `json:"index"`
+	Line         int               `json:"line"`
+	File         string            `json:"file,omitempty"`
+	Location     string            `json:"location"`
+	Media        string            `json:"media"`
+	Conditional  string            `json:"conditional"` // condition of wrapping conditional comment
+	Bytes        int               `json:"bytes"`
+	Rulesets     int               `json:"rulesets"`
+	AtRules      int               `json:"at_rules"`
+	Declarations int               `json:"declarations"`
+	HasRules     bool              `json:"has_rules"` // rules will be lost if block is stripped
+	Issues       []StyleBlockIssue `json:"issues"`
+	Snippet      string            `json:"snippet"`
+	Context      string            `json:"context"`
+}
+
+type cssBlockStats struct {
+	rulesets     int
+	atRules      int
+	declarations int
+}
+
+func (stats *cssBlockStats) track(gt css.GrammarType) {
+	switch gt {
+	case css.BeginRulesetGrammar:
+		stats.rulesets += 1
+	case css.BeginAtRuleGrammar, css.AtRuleGrammar:
+		stats.atRules += 1
+	case css.DeclarationGrammar, css.CustomPropertyGrammar:
+		stats.declarations += 1
+	}
+}
+
+// getStyleBlockLocation detect place of <style> by open html tags
+func (prs *ParserEngine) getStyleBlockLocation() string {
+	location := STYLE_LOCATION_HEAD
+	for _, tag := range prs.htmlTagsStack {
+		switch tag.name {
+		case "html", "head", "style":
+			continue
+		case "table", "thead", "tbody", "tfoot", "tr", "td", "th":
+			return STYLE_LOCATION_TABLE
+		default:
+			location = STYLE_LOCATION_BODY
+		}
+	}
+	return location
+}
+
+// addStyleBlock register <style> element and return its index
+func (prs *ParserEngine) addStyleBlock(position SourcePosition, location, media string, size int) int {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	index := len(prs.pr.StyleBlocks)
+	prs.pr.StyleBlocks = append(prs.pr.StyleBlocks, StyleBlock{
+		Index:       index,
+		Line:        position.Line,
+		Location:    location,
+		Media:       media,
+		Conditional: prs.conditionalComment,
+		Bytes:       size,
+		Snippet:     position.Snippet,
+	})
+	return index
+}
+
+func (prs *ParserEngine) saveStyleBlockStats(index int, stats cssBlockStats) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.pr.StyleBlocks[index].Rulesets = stats.rulesets
+	prs.pr.StyleBlocks[index].AtRules = stats.atRules
+	prs.pr.StyleBlocks[index].Declarations = stats.declarations
+	prs.pr.StyleBlocks[index].HasRules = stats.rulesets > 0 || stats.atRules > 0
+}
+
+func addStyleBlockIssue(block *StyleBlock, issue string) {
+	block.Issues = append(block.Issues, StyleBlockIssue{
+		Type:        issue,
+		Description: styleBlockIssuesDescriptions[issue],
+	})
+}
+
+// checkStyleBlocks add issues to style blocks, should be called after all blocks processed
+func (prs *ParserEngine) checkStyleBlocks() {
+	blocksWithRules := 0
+	for i := range prs.pr.StyleBlocks {
+		block := &prs.pr.StyleBlocks[i]
+		if !block.HasRules {
+			addStyleBlockIssue(block, STYLE_ISSUE_EMPTY)
+			continue
+		}
+		blocksWithRules += 1
+
+		switch block.Location {
+		case STYLE_LOCATION_BODY:
+			addStyleBlockIssue(block, STYLE_ISSUE_IN_BODY)
+		case STYLE_LOCATION_TABLE:
+			addStyleBlockIssue(block, STYLE_ISSUE_INSIDE_TABLE)
+		}
+		// empty blocks are not counted, nothing is lost when client skip them
+		if blocksWithRules > 1 {
+			addStyleBlockIssue(block, STYLE_ISSUE_NOT_FIRST)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLStyleBlocks(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.one { color: red; }
+		@media (max-width: 600px) { .one { color: blue; } }
+	</style>
+	<!--[if mso]><style>td { mso-line-height-rule: exactly; }</style><![endif]-->
+</head>
+<body>
+	<style media="screen">.two { color: green; }</style>
+	<table><tr><td>
+		<style></style>
+	</td></tr></table>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	type styleBlockSummary struct {
+		Line        int
+		Location    string
+		Media       string
+		Conditional string
+		Bytes       int
+		Rulesets    int
+		AtRules     int
+		HasRules    bool
+		Issues      []string
+	}
+
+	got := make([]styleBlockSummary, 0, len(report.StyleBlocks))
+	for _, block := range report.StyleBlocks {
+		var issues []string
+		for _, issue := range block.Issues {
+			issues = append(issues, issue.Type)
+		}
+		got = append(got, styleBlockSummary{block.Line, block.Location, block.Media, block.Conditional, block.Bytes, block.Rulesets, block.AtRules, block.HasRules, issues})
+	}
+
+	want := []styleBlockSummary{
+		{3, STYLE_LOCATION_HEAD, "", "", 79, 2, 1, true, nil},
+		{7, STYLE_LOCATION_HEAD, "", "mso", 37, 1, 0, true, []string{STYLE_ISSUE_NOT_FIRST}},
+		{10, STYLE_LOCATION_BODY, "screen", "", 22, 1, 0, true, []string{STYLE_ISSUE_IN_BODY, STYLE_ISSUE_NOT_FIRST}},
+		{12, STYLE_LOCATION_TABLE, "", "", 0, 0, 0, false, []string{STYLE_ISSUE_EMPTY}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StyleBlocks: got %v, want %v", got, want)
+	}
+}
+
+func TestReportFromHTMLStyleBlocksAfterEmpty(t *testing.T) {
+	html := `<html>
+<head>
+	<style></style>
+	<style>.one { color: red; }</style>
+	<style>.two { color: blue; }</style>
+</head>
+<body></body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	got := make([][]string, 0, len(report.StyleBlocks))
+	for _, block := range report.StyleBlocks {
+		var issues []string
+		for _, issue := range block.Issues {
+			issues = append(issues, issue.Type)
+		}
+		got = append(got, issues)
+	}
+
+	want := [][]string{
+		{STYLE_ISSUE_EMPTY},
+		nil,
+		{STYLE_ISSUE_NOT_FIRST},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StyleBlocks issues: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,322 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	parse "github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	a "golang.org/x/net/html/atom"
+)
+
+const (
+	// Outlook rendering becomes slow and unstable with deeper nesting
+	TABLE_MAX_NESTING_DEPTH = 6
+	LIMIT_TABLES            = 200
+)
+
+// table layout issues
+const (
+	TABLE_NESTING_TOO_DEEP     = "nesting_too_deep"
+	TABLE_MISSING_CELLPADDING  = "missing_cellpadding"
+	TABLE_MISSING_CELLSPACING  = "missing_cellspacing"
+	TABLE_MISSING_BORDER       = "missing_border"
+	TABLE_WIDER_THAN_PARENT    = "table_wider_than_parent"
+	TABLE_ROW_WIDER_THAN_TABLE = "row_wider_than_table"
+	TABLE_INCONSISTENT_COLUMNS = "inconsistent_column_widths"
+	TABLE_CELL_WITHOUT_ROW     = "cell_without_row"
+	TABLE_ROW_WITHOUT_TABLE    = "row_without_table"
+	TABLE_CELL_WITHOUT_TABLE   = "cell_without_table"
+)
+
+var tablesRulesDB = map[string]map[string]interface{}{}
+
+// initTablesRules use stats of width attribute
+func initTablesRules() {
+	widthRule := rulesDB.HtmlAttributes["width"][""]
+
+	tablesRulesDB = map[string]map[string]interface{}{
+		TABLE_NESTING_TOO_DEEP:     makeClientsIssueRule("Tables nested too deep", "Deeply nested tables render slowly and unstable in Outlook (Word engine), flatten layout or use fewer wrappers.", SEVERITY_WARNING, nil),
+		TABLE_MISSING_CELLPADDING:  makeClientsIssueRule("Table without cellpadding", "Clients apply own default cell padding, set cellpadding=\"0\" and use padding on cells.", SEVERITY_INFO, nil),
+		TABLE_MISSING_CELLSPACING:  makeClientsIssueRule("Table without cellspacing", "Clients apply own default spacing between cells, set cellspacing=\"0\".", SEVERITY_INFO, nil),
+		TABLE_MISSING_BORDER:       makeClientsIssueRule("Table without border=\"0\"", "Some clients show default table borders, set border=\"0\" on layout tables.", SEVERITY_INFO, nil),
+		TABLE_WIDER_THAN_PARENT:    makeClientsIssueRule("Table wider than parent cell", "Nested table is wider than cell, which contains it. Outlook expands parent table and breaks layout.", SEVERITY_WARNING, widthRule),
+		TABLE_ROW_WIDER_THAN_TABLE: makeClientsIssueRule("Row wider than table", "Sum of cell widths in row is larger than table width, clients resolve it differently.", SEVERITY_WARNING, widthRule),
+		TABLE_INCONSISTENT_COLUMNS: makeClientsIssueRule("Inconsistent column widths", "Rows with same count of cells declare different widths for same column. Outlook uses widths from first row only.", SEVERITY_WARNING, widthRule),
+		TABLE_CELL_WITHOUT_ROW:     makeClientsIssueRule("Cell without row", "<td> or <th> is not wrapped by <tr>. Browsers insert missing row, but Outlook and some webmail sanitizers do not.", SEVERITY_ERROR, nil),
+		TABLE_ROW_WITHOUT_TABLE:    makeClientsIssueRule("Row without table", "<tr> is outside of <table> and ignored by html parsers.", SEVERITY_ERROR, nil),
+		TABLE_CELL_WITHOUT_TABLE:   makeClientsIssueRule("Cell without table", "<td> or <th> is outside of <table> and ignored by html parsers.", SEVERITY_ERROR, nil),
+	}
+}
+
+// TableRow is row of table with sum of cells widths
+type TableRow struct {
+	Line  int     `json:"line"`
+	Cells int     `json:"cells"` // columns count, colspan included
+	Width float64 `json:"width"` // sum of cells widths in px, 0 if any cell width unknown
+}
+
+// TableNode is table in layout tree
+type TableNode struct {
+	Depth       int        `json:"depth"`  // 1 for top level table
+	Parent      int        `json:"parent"` // index of parent table, -1 for top level table
+	Width       float64    `json:"width"`  // width in px, 0 if unknown
+	ParentWidth float64    `json:"parent_width"`
+	Rows        []TableRow `json:"rows"`
+	Line        int        `json:"line"`
+	File        string     `json:"file,omitempty"`
+	Snippet     string     `json:"snippet"`
+	Context     string     `json:"context"`
+}
+
+type TableLayout struct {
+	Tables   []TableNode `json:"tables"` // in document order
+	MaxDepth int         `json:"max_depth"`
+	More     bool        `json:"more"`
+}
+
+type tableRowState struct {
+	position   SourcePosition
+	cells      int
+	widths     []float64
+	hasUnknown bool
+	hasColspan bool
+}
+
+type tableFrame struct {
+	index     int // in report, -1 if over limit
+	width     float64
+	row       *tableRowState
+	cellWidth float64 // width of open cell, parent width for nested tables
+	columns   []float64
+	reported  bool // inconsistent columns reported
+}
+
+type tableLayoutState struct {
+	stack []*tableFrame
+}
+
+func (prs *ParserEngine) saveToReportTables(issue string, position SourcePosition) {
+	prs.saveToReportIssues(&prs.pr.TableIssues, tablesRulesDB, issue, position)
+}
+
+// htmlElementWidth return width of element in px from width attribute or inline style,
+// percent widths resolved from parent width
+func htmlElementWidth(attrs []html.Attribute, parentWidth float64) (float64, bool) {
+	attrWidth := strings.TrimSpace(getHtmlAttributeValue(attrs, "width"))
+	width, fluid, ok := htmlWidthValue(attrWidth)
+	percent := strings.TrimSuffix(attrWidth, "%")
+
+	p := css.NewParser(parse.NewInput(bytes.NewBufferString(getHtmlAttributeValue(attrs, "style"))), true)
+	for {
+		gt, _, data := p.Next()
+		if gt == css.ErrorGrammar {
+			break
+		}
+		if gt != css.DeclarationGrammar || strings.ToLower(string(data)) != "width" {
+			continue
+		}
+		if styleWidth, styleFluid, styleOk := cssWidthValue(p.Values()); styleOk {
+			width, fluid, ok = styleWidth, styleFluid, styleOk
+			percent = strings.TrimSuffix(strings.TrimSpace(cssTokensToString(p.Values())), "%")
+		}
+	}
+
+	if fluid {
+		value, err := strconv.ParseFloat(percent, 64)
+		if err != nil || parentWidth <= 0 {
+			return 0, false
+		}
+		return parentWidth * value / 100, true
+	}
+	return width, ok
+}
+
+func (prs *ParserEngine) currentTable() *tableFrame {
+	if len(prs.tableLayout.stack) == 0 {
+		return nil
+	}
+	return prs.tableLayout.stack[len(prs.tableLayout.stack)-1]
+}
+
+// collectTableLayout build tables tree from html tokens
+func (prs *ParserEngine) collectTableLayout(token html.Token, position SourcePosition) {
+	if prs.isStyleTagOpen {
+		return
+	}
+
+	switch token.Type {
+	case html.StartTagToken:
+		switch token.DataAtom {
+		case a.Table:
+			prs.openTable(token, position)
+		case a.Tr:
+			prs.openTableRow(position)
+		case a.Td, a.Th:
+			prs.openTableCell(token, position)
+		}
+	case html.EndTagToken:
+		switch token.DataAtom {
+		case a.Table:
+			prs.closeTable()
+		case a.Tr:
+			if table := prs.currentTable(); table != nil {
+				prs.closeTableRow(table)
+			}
+		case a.Td, a.Th:
+			if table := prs.currentTable(); table != nil {
+				table.cellWidth = 0
+			}
+		}
+	}
+}
+
+func (prs *ParserEngine) openTable(token html.Token, position SourcePosition) {
+	parentIndex, parentWidth := -1, 0.0
+	if parent := prs.currentTable(); parent != nil {
+		parentIndex, parentWidth = parent.index, parent.cellWidth
+		if parentWidth <= 0 {
+			parentWidth = parent.width
+		}
+	}
+	depth := len(prs.tableLayout.stack) + 1
+	width, _ := htmlElementWidth(token.Attr, parentWidth)
+
+	if depth > TABLE_MAX_NESTING_DEPTH {
+		prs.saveToReportTables(TABLE_NESTING_TOO_DEEP, position)
+	}
+	if !hasHtmlAttribute(token.Attr, "cellpadding") {
+		prs.saveToReportTables(TABLE_MISSING_CELLPADDING, position)
+	}
+	if !hasHtmlAttribute(token.Attr, "cellspacing") {
+		prs.saveToReportTables(TABLE_MISSING_CELLSPACING, position)
+	}
+	if !hasHtmlAttribute(token.Attr, "border") {
+		prs.saveToReportTables(TABLE_MISSING_BORDER, position)
+	}
+	if width > 0 && parentWidth > 0 && width > parentWidth {
+		prs.saveToReportTables(TABLE_WIDER_THAN_PARENT, position)
+	}
+
+	frame := &tableFrame{index: -1, width: width}
+	prs.tableLayout.stack = append(prs.tableLayout.stack, frame)
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.pr.TableLayout.MaxDepth = max(prs.pr.TableLayout.MaxDepth, depth)
+	if len(prs.pr.TableLayout.Tables) >= LIMIT_TABLES {
+		prs.pr.TableLayout.More = true
+		return
+	}
+	frame.index = len(prs.pr.TableLayout.Tables)
+	prs.pr.TableLayout.Tables = append(prs.pr.TableLayout.Tables, TableNode{
+		Depth:       depth,
+		Parent:      parentIndex,
+		Width:       width,
+		ParentWidth: parentWidth,
+		Rows:        []TableRow{},
+		Line:        position.Line,
+		Snippet:     position.Snippet,
+	})
+}
+
+func (prs *ParserEngine) openTableRow(position SourcePosition) {
+	table := prs.currentTable()
+	if table == nil {
+		if !prs.options.Fragment {
+			prs.saveToReportTables(TABLE_ROW_WITHOUT_TABLE, position)
+		}
+		return // in fragment table can be in other partial
+	}
+	prs.closeTableRow(table)
+	table.row = &tableRowState{position: position}
+}
+
+func (prs *ParserEngine) openTableCell(token html.Token, position SourcePosition) {
+	table := prs.currentTable()
+	if table == nil {
+		if !prs.options.Fragment {
+			prs.saveToReportTables(TABLE_CELL_WITHOUT_TABLE, position)
+		}
+		return // in fragment table can be in other partial
+	}
+	if table.row == nil {
+		prs.saveToReportTables(TABLE_CELL_WITHOUT_ROW, position)
+		table.row = &tableRowState{position: position} // parsers insert row
+	}
+
+	colspan, err := strconv.Atoi(strings.TrimSpace(getHtmlAttributeValue(token.Attr, "colspan")))
+	if err != nil || colspan < 1 {
+		colspan = 1
+	}
+	width, ok := htmlElementWidth(token.Attr, table.width)
+
+	row := table.row
+	row.cells += colspan
+	row.hasColspan = row.hasColspan || colspan > 1
+	row.hasUnknown = row.hasUnknown || !ok
+	row.widths = append(row.widths, width)
+	table.cellWidth = width
+}
+
+func (prs *ParserEngine) closeTableRow(table *tableFrame) {
+	row := table.row
+	if row == nil {
+		return
+	}
+	table.row, table.cellWidth = nil, 0
+
+	var sum float64
+	if !row.hasUnknown {
+		for _, width := range row.widths {
+			sum += width
+		}
+		if table.width > 0 && sum > table.width {
+			prs.saveToReportTables(TABLE_ROW_WIDER_THAN_TABLE, row.position)
+		}
+		if !row.hasColspan && len(row.widths) > 0 {
+			if table.columns == nil {
+				table.columns = row.widths
+			} else if !table.reported && len(table.columns) == len(row.widths) {
+				for i, width := range row.widths {
+					if width != table.columns[i] {
+						prs.saveToReportTables(TABLE_INCONSISTENT_COLUMNS, row.position)
+						table.reported = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	if table.index < 0 {
+		return
+	}
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	prs.pr.TableLayout.Tables[table.index].Rows = append(prs.pr.TableLayout.Tables[table.index].Rows, TableRow{
+		Line:  row.position.Line,
+		Cells: row.cells,
+		Width: sum,
+	})
+}
+
+func (prs *ParserEngine) closeTable() {
+	table := prs.currentTable()
+	if table == nil {
+		return
+	}
+	prs.closeTableRow(table)
+	prs.tableLayout.stack = prs.tableLayout.stack[:len(prs.tableLayout.stack)-1]
+}
+
+// checkTableLayout close tables, which are not closed at document end
+func (prs *ParserEngine) checkTableLayout() {
+	for len(prs.tableLayout.stack) > 0 {
+		prs.closeTable()
+	}
+}
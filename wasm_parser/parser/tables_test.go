@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLTableLayout(t *testing.T) {
+	var tests = []struct {
+		name       string
+		html       string
+		wantIssues map[string]map[int]bool
+		wantTables []TableNode
+	}{
+		{
+			"clean layout",
+			`<table width="600" cellpadding="0" cellspacing="0" border="0">
+	<tr><td width="200">A</td><td width="400">
+		<table width="100%" cellpadding="0" cellspacing="0" border="0"><tr><td>B</td></tr></table>
+	</td></tr>
+	<tr><td width="200">C</td><td width="400">D</td></tr>
+</table>`,
+			map[string]map[int]bool{},
+			[]TableNode{
+				{Depth: 1, Parent: -1, Width: 600, Rows: []TableRow{{Line: 2, Cells: 2, Width: 600}, {Line: 5, Cells: 2, Width: 600}}, Line: 1},
+				{Depth: 2, Parent: 0, Width: 400, ParentWidth: 400, Rows: []TableRow{{Line: 3, Cells: 1}}, Line: 3},
+			},
+		},
+		{
+			"anti-patterns",
+			`<table width="600">
+	<tr><td width="300">A</td><td width="400">B</td></tr>
+	<tr><td width="400">C</td><td width="300">
+		<table width="500" cellpadding="0" cellspacing="0" border="0"><td>D</td></table>
+	</td></tr>
+</table>`,
+			map[string]map[int]bool{
+				TABLE_MISSING_CELLPADDING:  {1: true},
+				TABLE_MISSING_CELLSPACING:  {1: true},
+				TABLE_MISSING_BORDER:       {1: true},
+				TABLE_ROW_WIDER_THAN_TABLE: {2: true, 3: true},
+				TABLE_INCONSISTENT_COLUMNS: {3: true},
+				TABLE_WIDER_THAN_PARENT:    {4: true},
+				TABLE_CELL_WITHOUT_ROW:     {4: true},
+			},
+			[]TableNode{
+				{Depth: 1, Parent: -1, Width: 600, Rows: []TableRow{{Line: 2, Cells: 2, Width: 700}, {Line: 3, Cells: 2, Width: 700}}, Line: 1},
+				{Depth: 2, Parent: 0, Width: 500, ParentWidth: 300, Rows: []TableRow{{Line: 4, Cells: 1}}, Line: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTMLWithOptions([]byte(tt.html), ParserOptions{Fragment: true})
+			if err != nil {
+				t.Fatalf("ReportFromHTMLWithOptions() error = %v", err)
+			}
+
+			gotIssues := make(map[string]map[int]bool)
+			for issue, item := range report.TableIssues {
+				gotIssues[issue] = item.Lines
+			}
+			if !reflect.DeepEqual(gotIssues, tt.wantIssues) {
+				t.Errorf("issues: got %v, want %v", gotIssues, tt.wantIssues)
+			}
+
+			var gotTables []TableNode
+			for _, table := range report.TableLayout.Tables {
+				table.Snippet, table.Context = "", ""
+				gotTables = append(gotTables, table)
+			}
+			if !reflect.DeepEqual(gotTables, tt.wantTables) {
+				t.Errorf("tables: got %+v, want %+v", gotTables, tt.wantTables)
+			}
+			if report.TableLayout.MaxDepth != 2 {
+				t.Errorf("max depth: got %d, want 2", report.TableLayout.MaxDepth)
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLTablesWithoutTable(t *testing.T) {
+	var tests = []struct {
+		name       string
+		html       string
+		options    ParserOptions
+		wantIssues map[string]map[int]bool
+	}{
+		{
+			"document",
+			`<html><body>
+<tr>
+<td>Header</td>
+</tr>
+</body></html>`,
+			ParserOptions{},
+			map[string]map[int]bool{
+				TABLE_ROW_WITHOUT_TABLE:  {2: true},
+				TABLE_CELL_WITHOUT_TABLE: {3: true},
+			},
+		},
+		{
+			"fragment",
+			`<tr>
+<td>Header</td>
+</tr>`,
+			ParserOptions{Fragment: true},
+			map[string]map[int]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReportFromHTMLWithOptions([]byte(tt.html), tt.options)
+			if err != nil {
+				t.Fatalf("ReportFromHTMLWithOptions() error = %v", err)
+			}
+
+			gotIssues := make(map[string]map[int]bool)
+			for issue, item := range report.TableIssues {
+				gotIssues[issue] = item.Lines
+			}
+			if !reflect.DeepEqual(gotIssues, tt.wantIssues) {
+				t.Errorf("issues: got %v, want %v", gotIssues, tt.wantIssues)
+			}
+		})
+	}
+}
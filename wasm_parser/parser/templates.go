@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+)
+
+// template delimiters kinds
+const (
+	TEMPLATE_TAG_OUTPUT  = iota // value, which replaced by text on render: {{ name }}, *|FNAME|*, [[unsubscribe]]
+	TEMPLATE_TAG_CONTROL        // logic or comment, which produce no text: {% if %}, {{#each}}, {# comment #}
+)
+
+const (
+	TEMPLATE_OUTPUT_PLACEHOLDER = '_'
+	LIMIT_TEMPLATE_TAG_LENGTH   = 2000
+)
+
+var (
+	// opening delimiter -> closing delimiter, longest first
+	templateDelimiters = []struct {
+		open  string
+		close string
+	}{
+		{"{{!--", "--}}"}, // handlebars comment
+		{"{{{", "}}}"},    // handlebars raw output
+		{"{{", "}}"},      // handlebars, mustache, liquid and jinja output
+		{"{%", "%}"},      // liquid and jinja tags
+		{"{#", "#}"},      // jinja comment
+		{"*|", "|*"},      // mailchimp merge tags
+		{"[[", "]]"},      // esp merge tags (unsubscribe, webversion)
+		{"%%", "%%"},      // salesforce marketing cloud personalization strings and ampscript
+	}
+	// liquid and jinja conditionals: opening tag -> closing tag
+	templateConditionalTags = map[string]string{
+		"if":     "endif",
+		"unless": "endunless",
+		"case":   "endcase",
+	}
+)
+
+// templateTag is template delimiter, found in document
+type templateTag struct {
+	start int
+	end   int
+	kind  int
+	body  string // content between delimiters without whitespace control markers
+	open  string
+}
+
+// templateBlock is conditional section of template
+type templateBlock struct {
+	start     int
+	end       int
+	closeKey  string
+	condition string
+}
+
+type templateState struct {
+	blocks []templateBlock
+}
+
+// findTemplateTag return template tag, which starts at offset, or false
+func findTemplateTag(document []byte, offset int) (templateTag, bool) {
+	for _, delimiter := range templateDelimiters {
+		if !bytes.HasPrefix(document[offset:], []byte(delimiter.open)) {
+			continue
+		}
+
+		bodyStart := offset + len(delimiter.open)
+		limit := min(len(document), bodyStart+LIMIT_TEMPLATE_TAG_LENGTH)
+		closeIndex := bytes.Index(document[bodyStart:limit], []byte(delimiter.close))
+		if closeIndex < 0 {
+			return templateTag{}, false
+		}
+		body := string(document[bodyStart : bodyStart+closeIndex])
+		if !isTemplateTagBody(delimiter.open, body) {
+			return templateTag{}, false
+		}
+
+		tag := templateTag{
+			start: offset,
+			end:   bodyStart + closeIndex + len(delimiter.close),
+			kind:  TEMPLATE_TAG_OUTPUT,
+			body:  strings.Trim(body, WHITESPACE+"-~"),
+			open:  delimiter.open,
+		}
+		if isTemplateControlTag(tag) {
+			tag.kind = TEMPLATE_TAG_CONTROL
+		}
+		return tag, true
+	}
+	return templateTag{}, false
+}
+
+// isTemplateTagBody filter false positives for short delimiters, which can be part of regular content
+func isTemplateTagBody(open, body string) bool {
+	trimmed := strings.Trim(body, WHITESPACE)
+	if len(trimmed) == 0 {
+		return false
+	}
+
+	switch open {
+	case "[[", "*|":
+		return !strings.ContainsAny(body, "[]<>{}\n")
+	case "%%":
+		// %%FirstName%%, %%=v(@name)=%%, %%[ ampscript ]%%
+		return !strings.ContainsRune(body, '\n') && (body[0] == '[' || body[0] == '=' || isHtmlTagNameStart(body[0]))
+	}
+	return true
+}
+
+func isTemplateControlTag(tag templateTag) bool {
+	switch tag.open {
+	case "{{!--", "{%", "{#":
+		return true
+	case "{{":
+		if tag.body == "else" || strings.HasPrefix(tag.body, "else ") {
+			return true
+		}
+		return len(tag.body) > 0 && strings.ContainsRune("#/^!>", rune(tag.body[0]))
+	case "*|":
+		name := strings.ToUpper(tag.body)
+		return strings.HasPrefix(name, "IF:") || strings.HasPrefix(name, "IFNOT:") ||
+			strings.HasPrefix(name, "ELSEIF:") || strings.HasPrefix(name, "ELSE:") || strings.HasPrefix(name, "END:")
+	case "%%":
+		return strings.HasPrefix(tag.body, "[")
+	}
+	return false
+}
+
+// templateConditionalKeys return key of conditional opening and key of closing, which tag represents
+func templateConditionalKeys(tag templateTag) (opening string, closing string) {
+	switch tag.open {
+	case "{%":
+		fields := strings.Fields(tag.body)
+		if len(fields) == 0 {
+			return "", ""
+		}
+		name := strings.ToLower(fields[0])
+		if closeKey, ok := templateConditionalTags[name]; ok {
+			return "{%" + closeKey, ""
+		}
+		return "", "{%" + name
+	case "{{":
+		if len(tag.body) < 2 {
+			return "", ""
+		}
+		name := strings.Fields(tag.body[1:])
+		if len(name) == 0 {
+			return "", ""
+		}
+		switch tag.body[0] {
+		case '#':
+			if name[0] == "if" || name[0] == "unless" {
+				return "{{/" + name[0], ""
+			}
+		case '^': // mustache inverted section
+			return "{{/" + name[0], ""
+		case '/':
+			return "", "{{/" + name[0]
+		}
+	case "*|":
+		name := strings.ToUpper(tag.body)
+		if strings.HasPrefix(name, "IF:") || strings.HasPrefix(name, "IFNOT:") {
+			return "*|END:IF", ""
+		}
+		if name == "END:IF" {
+			return "", "*|END:IF"
+		}
+	}
+	return "", ""
+}
+
+// isTemplateTagInsideHtmlTag return true, if template output placed between attributes (<td {{attrs}}>),
+// where placeholder will be parsed as attribute name
+func isTemplateTagInsideHtmlTag(inHtmlTag bool, quote byte, document []byte, offset int) bool {
+	if !inHtmlTag || quote != 0 {
+		return false
+	}
+	prev := bytes.TrimRight(document[:offset], WHITESPACE)
+	return len(prev) == 0 || prev[len(prev)-1] != '='
+}
+
+// maskTemplateTags replace template delimiters with neutral placeholders of the same length,
+// so html tokenizer and css parser do not see template syntax and all offsets and lines stay exact
+func maskTemplateTags(document []byte) ([]byte, templateState) {
+	var (
+		masked    []byte
+		state     templateState
+		openStack []int
+		inHtmlTag bool
+		quote     byte
+	)
+
+	for offset := 0; offset < len(document); offset++ {
+		ch := document[offset]
+		tag, ok := findTemplateTag(document, offset)
+		if !ok {
+			// track html tags, so output placeholders between attributes can be replaced by spaces
+			switch {
+			case quote != 0:
+				if ch == quote {
+					quote = 0
+				}
+			case inHtmlTag && (ch == '"' || ch == '\''):
+				quote = ch
+			case inHtmlTag && ch == '>':
+				inHtmlTag = false
+			case !inHtmlTag && ch == '<' && offset+1 < len(document) && isHtmlTagNameStart(document[offset+1]):
+				inHtmlTag = true
+			}
+			continue
+		}
+
+		if masked == nil {
+			masked = bytes.Clone(document)
+		}
+		placeholder := byte(TEMPLATE_OUTPUT_PLACEHOLDER)
+		if tag.kind == TEMPLATE_TAG_CONTROL || isTemplateTagInsideHtmlTag(inHtmlTag, quote, document, offset) {
+			placeholder = ' '
+		}
+		for i := tag.start; i < tag.end; i++ {
+			if masked[i] != '\n' && masked[i] != '\r' {
+				masked[i] = placeholder
+			}
+		}
+
+		opening, closing := templateConditionalKeys(tag)
+		if len(opening) > 0 {
+			openStack = append(openStack, len(state.blocks))
+			state.blocks = append(state.blocks, templateBlock{
+				start:     tag.start,
+				end:       len(document),
+				closeKey:  opening,
+				condition: limitSnippet(collapseWhitespace(string(document[tag.start:tag.end]))),
+			})
+		}
+		if len(closing) > 0 {
+			// close nearest block with the same key, unclosed inner blocks end here too
+			for i := len(openStack) - 1; i >= 0; i-- {
+				if state.blocks[openStack[i]].closeKey == closing {
+					for _, blockIndex := range openStack[i:] {
+						state.blocks[blockIndex].end = tag.end
+					}
+					openStack = openStack[:i]
+					break
+				}
+			}
+		}
+
+		offset = tag.end - 1
+	}
+
+	if masked == nil {
+		return document, state
+	}
+	return masked, state
+}
+
+func isHtmlTagNameStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// templateConditionsAt return template conditions, which wrap offset, outer first
+func (prs *ParserEngine) templateConditionsAt(offset int) []string {
+	var conditions []string
+	for _, block := range prs.templates.blocks {
+		if block.start <= offset && offset < block.end {
+			conditions = append(conditions, block.condition)
+		}
+	}
+	return conditions
+}
+
+// templateConditionsAtLine return template conditions, which wrap start of line
+func (prs *ParserEngine) templateConditionsAtLine(line int) []string {
+	if len(prs.templates.blocks) == 0 || line < 1 || line > len(prs.bytesToLine) {
+		return nil
+	}
+	return prs.templateConditionsAt(prs.bytesToLine[line-1])
+}
+
+func (pos SourcePosition) withTemplateConditions(conditions []string) SourcePosition {
+	if len(conditions) > 0 {
+		pos.TemplateConditions = conditions
+	}
+	return pos
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaskTemplateTags(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"liquid output and tags",
+			"{% if vip %}<b style=\"color: {{ brand }}\">Hi</b>{% endif %}",
+			"            <b style=\"color: ___________\">Hi</b>           ",
+		},
+		{
+			"handlebars output between attributes",
+			"<td {{attrs}} width=\"{{w}}\">{{{raw}}}</td>",
+			"<td           width=\"_____\">_________</td>",
+		},
+		{
+			"esp merge tags",
+			"Hi *|FNAME|*, [[unsubscribe]] %%=v(@x)=%%",
+			"Hi _________, _______________ ___________",
+		},
+		{
+			"multiline tag keeps newlines",
+			"{%\n  if vip\n%}",
+			"  \n        \n  ",
+		},
+		{
+			"not a template",
+			"<p>100%% [[ ]] {{</p>",
+			"<p>100%% [[ ]] {{</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masked, _ := maskTemplateTags([]byte(tt.html))
+			if string(masked) != tt.want {
+				t.Errorf("got %q, want %q", masked, tt.want)
+			}
+			if len(masked) != len(tt.html) {
+				t.Errorf("length changed: got %d, want %d", len(masked), len(tt.html))
+			}
+		})
+	}
+}
+
+func TestReportFromHTMLTemplates(t *testing.T) {
+	html := `<html><body>
+{% if user.vip %}
+<div style="display: flex; color: {{ brand }}">VIP {{ name }}</div>
+{% endif %}
+<table><tr>{{#each rows}}<td {{attrs}}>*|FNAME|*</td>{{/each}}</tr></table>
+{{#if dark}}<style>
+.a { display: grid; }
+</style>{{/if}}
+*|IF:PREMIUM|*<div style="display: none">Premium</div>*|END:IF|*
+</body></html>`
+
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf("ReportFromHTML() error = %v", err)
+	}
+
+	got := make(map[int][]string)
+	for _, occurrence := range report.CssProperties["display"][""].Occurrences {
+		got[occurrence.Line] = occurrence.TemplateConditions
+	}
+	want := map[int][]string{
+		3: {"{% if user.vip %}"},
+		7: {"{{#if dark}}"},
+		9: {"*|IF:PREMIUM|*"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("template conditions: got %v, want %v", got, want)
+	}
+
+	if len(report.HtmlDiagnostics) > 0 {
+		t.Errorf("unexpected diagnostics: %v", report.HtmlDiagnostics)
+	}
+	if len(report.UnknownNames) > 0 {
+		t.Errorf("unexpected unknown names: %v", report.UnknownNames)
+	}
+
+	context := report.CssProperties["display"]["flex"].Occurrences[0].Context
+	if context != "{% if user.vip %}\n<div style=\"display: flex; color: {{ brand }}\">VIP {{ name }}</div>\n{% endif %}" {
+		t.Errorf("context should keep original template source, got %q", context)
+	}
+}
+
+func TestReportFromHTMLTemplatesInsideStyleLine(t *testing.T) {
+	html := `<html><body>
+<style>{% if dark %}.a { display: grid; }{% endif %} .b { display: flex; }</style>
+</body></html>`
+
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf("ReportFromHTML() error = %v", err)
+	}
+
+	var tests = []struct {
+		checkType string
+		container ReportContainer
+		want      []string
+	}{
+		{"display grid", report.CssProperties["display"]["grid"], []string{"{% if dark %}"}},
+		{"display flex", report.CssProperties["display"]["flex"], nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkType, func(t *testing.T) {
+			if len(tt.container.Occurrences) != 1 {
+				t.Fatalf("%s: got %d occurrences, want 1", tt.checkType, len(tt.container.Occurrences))
+			}
+			if got := tt.container.Occurrences[0].TemplateConditions; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, got, tt.want)
+			}
+		})
+	}
+}
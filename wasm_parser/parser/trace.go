@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+// trace events kinds
+const (
+	TRACE_HTML_TOKEN  = "html_token"
+	TRACE_CSS_GRAMMAR = "css_grammar"
+	TRACE_RULE_HIT    = "rule_hit"
+	TRACE_RULE_MISS   = "rule_miss"
+)
+
+type TraceEvent struct {
+	Kind   string `json:"kind"`
+	Type   string `json:"type"` // token type, grammar type or rules group
+	Data   string `json:"data"` // token source, grammar data or rule key
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+}
+
+// TraceSink receive parser events. Style blocks processed in parallel, so sink should be safe for concurrent use
+type TraceSink interface {
+	Trace(event TraceEvent)
+}
+
+// TraceWriter write events as json lines
+type TraceWriter struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{w: w}
+}
+
+func (tw *TraceWriter) Trace(event TraceEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+
+	tw.w.Write(append(line, '\n'))
+}
+
+func (prs *ParserEngine) isTraceEnabled() bool {
+	return prs.options.Trace != nil
+}
+
+func (prs *ParserEngine) trace(event TraceEvent) {
+	if prs.isTraceEnabled() {
+		event.Line = prs.originLine(event.Line)
+		prs.options.Trace.Trace(event)
+	}
+}
+
+// traceRuleLookup report hit or miss of key in rules database
+func (prs *ParserEngine) traceRuleLookup(group, key string, found bool, position SourcePosition) {
+	if !prs.isTraceEnabled() {
+		return
+	}
+
+	kind := TRACE_RULE_MISS
+	if found {
+		kind = TRACE_RULE_HIT
+	}
+	prs.trace(TraceEvent{
+		Kind: kind,
+		Type: group,
+		Data: key,
+		Line: position.Line,
+	})
+}
+
+// traceCssGrammar report css grammar item with its offset in css and mapped line in document
+func (prs *ParserEngine) traceCssGrammar(gt css.GrammarType, data []byte, p *css.Parser, line int) {
+	if !prs.isTraceEnabled() {
+		return
+	}
+
+	prs.trace(TraceEvent{
+		Kind:   TRACE_CSS_GRAMMAR,
+		Type:   gt.String(),
+		Data:   cssGrammarSnippet(gt, data, p.Values()),
+		Offset: p.Offset(),
+		Line:   line,
+	})
+}
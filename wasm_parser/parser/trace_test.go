@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type traceCollector struct {
+	mx     sync.Mutex
+	events []TraceEvent
+}
+
+func (tc *traceCollector) Trace(event TraceEvent) {
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+	tc.events = append(tc.events, event)
+}
+
+func (tc *traceCollector) find(kind, typ, data string) *TraceEvent {
+	for i, event := range tc.events {
+		if event.Kind == kind && event.Type == typ && event.Data == data {
+			return &tc.events[i]
+		}
+	}
+	return nil
+}
+
+func TestReportFromHTMLTrace(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		.title {
+			display: flex;
+		}
+	</style>
+</head>
+<body>
+	<div class="title" style="unknown-prop: 1px">Content</div>
+</body></html>`
+
+	collector := &traceCollector{}
+	if _, err := ReportFromHTMLWithOptions([]byte(html), ParserOptions{Trace: collector}); err != nil {
+		t.Fatalf(`ReportFromHTMLWithOptions("%s"), %v`, html, err)
+	}
+
+	var tests = []struct {
+		kind string
+		typ  string
+		data string
+		line int
+	}{
+		{TRACE_HTML_TOKEN, "StartTag", `<div class="title" style="unknown-prop: 1px">`, 10},
+		{TRACE_CSS_GRAMMAR, "BeginRuleset", ".title", 4},
+		{TRACE_CSS_GRAMMAR, "Declaration", "display: flex", 5},
+		{TRACE_RULE_HIT, "css_properties", "display", 5},
+		{TRACE_RULE_MISS, "css_properties", "unknown-prop", 10},
+	}
+
+	for _, tt := range tests {
+		event := collector.find(tt.kind, tt.typ, tt.data)
+		if event == nil {
+			t.Errorf("Trace: event %s %s %q not found", tt.kind, tt.typ, tt.data)
+			continue
+		}
+		if event.Line != tt.line {
+			t.Errorf("Trace: event %s %s %q line: got %d, want %d", tt.kind, tt.typ, tt.data, event.Line, tt.line)
+		}
+	}
+}
+
+func TestTraceWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewTraceWriter(&buf)
+	writer.Trace(TraceEvent{Kind: TRACE_RULE_HIT, Type: "css_properties", Data: "display", Line: 3})
+	writer.Trace(TraceEvent{Kind: TRACE_RULE_MISS, Type: "css_functions", Data: "foo", Line: 4})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("TraceWriter: got %d lines, want 2", len(lines))
+	}
+
+	var event TraceEvent
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("TraceWriter: invalid json %q, %v", lines[1], err)
+	}
+	if event.Kind != TRACE_RULE_MISS || event.Data != "foo" || event.Line != 4 {
+		t.Errorf("TraceWriter: got %v", event)
+	}
+}
@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// unknown names categories
+const (
+	UNKNOWN_CSS_PROPERTIES_TYPE  = "css_properties"
+	UNKNOWN_HTML_TAGS_TYPE       = "html_tags"
+	UNKNOWN_HTML_ATTRIBUTES_TYPE = "html_attributes"
+
+	LIMIT_NAME_SUGGESTIONS = 3
+)
+
+var (
+	knownNamesDB = map[string]map[string]bool{
+		UNKNOWN_CSS_PROPERTIES_TYPE:  {},
+		UNKNOWN_HTML_TAGS_TYPE:       {},
+		UNKNOWN_HTML_ATTRIBUTES_TYPE: {},
+	}
+	knownNamesList = map[string][]string{}
+)
+
+func initKnownNames() {
+	for _, name := range knownCssProperties {
+		knownNamesDB[UNKNOWN_CSS_PROPERTIES_TYPE][name] = true
+	}
+	for name := range rulesDB.CssProperties {
+		knownNamesDB[UNKNOWN_CSS_PROPERTIES_TYPE][name] = true
+	}
+	for _, name := range knownHtmlTags {
+		knownNamesDB[UNKNOWN_HTML_TAGS_TYPE][name] = true
+	}
+	for name := range rulesDB.HtmlTags {
+		knownNamesDB[UNKNOWN_HTML_TAGS_TYPE][name] = true
+	}
+	for _, name := range knownHtmlAttributes {
+		knownNamesDB[UNKNOWN_HTML_ATTRIBUTES_TYPE][name] = true
+	}
+	for name := range rulesDB.HtmlAttributes {
+		knownNamesDB[UNKNOWN_HTML_ATTRIBUTES_TYPE][name] = true
+	}
+
+	for category, names := range knownNamesDB {
+		list := make([]string, 0, len(names))
+		for name := range names {
+			list = append(list, name)
+		}
+		sort.Strings(list)
+		knownNamesList[category] = list
+	}
+}
+
+// editDistance is optimal string alignment distance (Damerau-Levenshtein with adjacent transpositions)
+func editDistance(s1, s2 string) int {
+	r1, r2 := []rune(s1), []rune(s2)
+	rows := make([][]int, len(r1)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(r2)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && r1[i-1] == r2[j-2] && r1[i-2] == r2[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(r1)][len(r2)]
+}
+
+func suggestKnownNames(category, name string) []interface{} {
+	type suggestion struct {
+		name     string
+		distance int
+		prefix   int
+	}
+
+	maxDistance := 3
+	switch {
+	case len(name) <= 4:
+		maxDistance = 1
+	case len(name) <= 8:
+		maxDistance = 2
+	}
+
+	var suggestions []suggestion
+	for _, known := range knownNamesList[category] {
+		if lenDiff := len(known) - len(name); lenDiff > maxDistance || -lenDiff > maxDistance {
+			continue
+		}
+		if distance := editDistance(name, known); distance <= maxDistance {
+			prefix := 0
+			for prefix < len(name) && prefix < len(known) && name[prefix] == known[prefix] {
+				prefix++
+			}
+			suggestions = append(suggestions, suggestion{name: known, distance: distance, prefix: prefix})
+		}
+	}
+
+	// closest first, typos usually happen at the end of the name
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].distance == suggestions[j].distance {
+			return suggestions[i].prefix > suggestions[j].prefix
+		}
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	result := make([]interface{}, 0, LIMIT_NAME_SUGGESTIONS)
+	for i := 0; i < len(suggestions) && i < LIMIT_NAME_SUGGESTIONS; i++ {
+		result = append(result, suggestions[i].name)
+	}
+	return result
+}
+
+func (prs *ParserEngine) saveToReportUnknownName(category, name string, position SourcePosition) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if prKeyData, ok := prs.pr.UnknownNames[category]; ok {
+		if prValData, ok := prKeyData[name]; ok {
+			prValData = addPositionToReportContainer(prValData, position)
+			prKeyData[name] = prValData
+			prs.pr.UnknownNames[category] = prKeyData
+			return
+		}
+	}
+
+	ruleData := map[string]interface{}{
+		"suggestions": suggestKnownNames(category, name),
+	}
+
+	if prKeyData, ok := prs.pr.UnknownNames[category]; ok {
+		prKeyData[name] = makeInitialReportContainer(position, ruleData)
+	} else {
+		rData := make(map[string]ReportContainer)
+		rData[name] = makeInitialReportContainer(position, ruleData)
+
+		if len(prs.pr.UnknownNames) > 0 {
+			prs.pr.UnknownNames[category] = rData
+		} else {
+			rootData := make(map[string]map[string]ReportContainer)
+			rootData[category] = rData
+			prs.pr.UnknownNames = rootData
+		}
+	}
+}
+
+func (prs *ParserEngine) checkUnknownCssProperty(propertyKey string, position SourcePosition) {
+	if len(propertyKey) == 0 || strings.HasPrefix(propertyKey, "-") || strings.HasPrefix(propertyKey, "mso-") {
+		return // custom properties, vendor prefixes and microsoft office properties
+	}
+	if !knownNamesDB[UNKNOWN_CSS_PROPERTIES_TYPE][propertyKey] {
+		prs.saveToReportUnknownName(UNKNOWN_CSS_PROPERTIES_TYPE, propertyKey, position)
+	}
+}
+
+func isIgnoredHtmlAttributeName(attrKey string) bool {
+	return len(attrKey) == 0 ||
+		strings.HasPrefix(attrKey, "data-") ||
+		strings.HasPrefix(attrKey, "on") ||
+		strings.HasPrefix(attrKey, "xmlns") ||
+		strings.Contains(attrKey, ":")
+}
+
+func (prs *ParserEngine) checkUnknownHtmlNames(tagName string, attrs []html.Attribute, attrsPositions map[string]SourcePosition, position SourcePosition) {
+	if strings.Contains(tagName, ":") || strings.Contains(tagName, "-") || prs.isInsideForeignHtml() {
+		return // xml (VML), custom elements and foreign content
+	}
+
+	if !knownNamesDB[UNKNOWN_HTML_TAGS_TYPE][tagName] {
+		prs.saveToReportUnknownName(UNKNOWN_HTML_TAGS_TYPE, tagName, position)
+		return
+	}
+
+	for _, att := range attrs {
+		attrKey := strings.ToLower(strings.Trim(att.Key, WHITESPACE))
+		if isIgnoredHtmlAttributeName(attrKey) {
+			continue
+		}
+		if !knownNamesDB[UNKNOWN_HTML_ATTRIBUTES_TYPE][attrKey] {
+			prs.saveToReportUnknownName(UNKNOWN_HTML_ATTRIBUTES_TYPE, attrKey, attributePosition(attrsPositions, attrKey, position))
+		}
+	}
+}
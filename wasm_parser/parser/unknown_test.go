@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLUnknownNames(t *testing.T) {
+	html := `<html><body>
+	<style>
+		.button {
+			colr: red;
+			-webkit-text-size-adjust: 100%;
+			mso-line-height-rule: exactly;
+		}
+	</style>
+	<tabel cellspaceing="0" cellpadding="0">
+		<tr><td data-id="1" onclick="go()" style="paddng: 10px">Cell</td></tr>
+	</tabel>
+	<table cellspaceing="0"></table>
+	<svg><feGaussianBlur stdDeviation="5" /></svg>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	var tests = []struct {
+		checkType string
+		got       interface{}
+		want      interface{}
+	}{
+		{"UnknownNames css_properties colr", report.UnknownNames["css_properties"]["colr"].Lines, map[int]bool{4: true}},
+		{"UnknownNames css_properties colr suggestions", report.UnknownNames["css_properties"]["colr"].Rules, map[string]interface{}{"suggestions": []interface{}{"color"}}},
+		{"UnknownNames css_properties paddng", report.UnknownNames["css_properties"]["paddng"].Lines, map[int]bool{10: true}},
+		{"UnknownNames html_tags tabel", report.UnknownNames["html_tags"]["tabel"].Lines, map[int]bool{9: true}},
+		{"UnknownNames html_tags tabel suggestions", report.UnknownNames["html_tags"]["tabel"].Rules, map[string]interface{}{"suggestions": []interface{}{"table", "label"}}},
+		{"UnknownNames html_attributes cellspaceing", report.UnknownNames["html_attributes"]["cellspaceing"].Lines, map[int]bool{12: true}},
+		{"UnknownNames html_attributes cellspaceing suggestions", report.UnknownNames["html_attributes"]["cellspaceing"].Rules, map[string]interface{}{"suggestions": []interface{}{"cellspacing", "cellpadding"}}},
+		{"UnknownNames count", len(report.UnknownNames["css_properties"]) + len(report.UnknownNames["html_tags"]) + len(report.UnknownNames["html_attributes"]), 4},
+	}
+
+	for _, tt := range tests {
+		testname := tt.checkType
+		t.Run(testname, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.checkType, tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	LIMIT_REPORT_DIAGNOSTICS = 500
+)
+
+// html well-formedness diagnostics
+const (
+	HTML_DIAGNOSTIC_UNCLOSED_TAG          = "unclosed_tag"
+	HTML_DIAGNOSTIC_STRAY_END_TAG         = "stray_end_tag"
+	HTML_DIAGNOSTIC_MISNESTED_TAG         = "misnested_tag"
+	HTML_DIAGNOSTIC_INVALID_NESTING       = "invalid_nesting"
+	HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT = "invalid_table_content"
+	HTML_DIAGNOSTIC_DUPLICATE_ATTRIBUTE   = "duplicate_attribute"
+	HTML_DIAGNOSTIC_SELF_CLOSING_NON_VOID = "self_closing_non_void"
+)
+
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+var (
+	htmlDiagnosticsDescriptions = map[string]string{
+		HTML_DIAGNOSTIC_UNCLOSED_TAG:          "Element is never closed. Browsers close it implicitly, but Outlook (Word rendering engine) and webmail sanitizers (like Gmail) can close it in a different place, which change layout.",
+		HTML_DIAGNOSTIC_STRAY_END_TAG:         "End tag has no matching start tag. Browsers ignore it (or insert empty element for </p> and </br>), webmail sanitizers may drop or keep surrounding content.",
+		HTML_DIAGNOSTIC_MISNESTED_TAG:         "Element closed before its child elements. Browsers repair it with adoption agency algorithm, Outlook (Word rendering engine) and webmail sanitizers repair it differently.",
+		HTML_DIAGNOSTIC_INVALID_NESTING:       "Element is not allowed inside its parent. Email clients move or split such elements differently, so layout may be broken in some of them.",
+		HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT: "Content placed directly inside table structure. Browsers move it before the table (foster parenting), Outlook (Word rendering engine) may keep or drop it.",
+		HTML_DIAGNOSTIC_DUPLICATE_ATTRIBUTE:   "Attribute declared more than once. Browsers use the first value, some webmail sanitizers use the last one.",
+		HTML_DIAGNOSTIC_SELF_CLOSING_NON_VOID: "Self-closing syntax is ignored for non-void HTML elements, so element stay open until parent closed.",
+	}
+
+	voidHtmlElements = map[string]bool{
+		"area": true, "base": true, "basefont": true, "bgsound": true, "br": true, "col": true,
+		"embed": true, "frame": true, "hr": true, "img": true, "input": true, "keygen": true,
+		"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+
+	optionalEndHtmlElements = map[string]bool{
+		"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+		"option": true, "optgroup": true, "tr": true, "td": true, "th": true, "thead": true,
+		"tbody": true, "tfoot": true, "colgroup": true, "caption": true, "rt": true, "rp": true,
+	}
+
+	// start tags, which implicitly close element on top of the stack
+	impliedEndHtmlElements = map[string]map[string]bool{
+		"p":        {"p": true},
+		"li":       {"li": true},
+		"dt":       {"dt": true, "dd": true},
+		"dd":       {"dt": true, "dd": true},
+		"option":   {"option": true, "optgroup": true},
+		"optgroup": {"optgroup": true},
+		"tr":       {"tr": true, "tbody": true, "thead": true, "tfoot": true},
+		"td":       {"td": true, "th": true, "tr": true, "tbody": true, "thead": true, "tfoot": true},
+		"th":       {"td": true, "th": true, "tr": true, "tbody": true, "thead": true, "tfoot": true},
+		"thead":    {"tbody": true, "tfoot": true},
+		"tbody":    {"tbody": true, "tfoot": true},
+		"colgroup": {"colgroup": true, "thead": true, "tbody": true, "tfoot": true, "tr": true},
+	}
+
+	requiredParentHtmlElements = map[string]map[string]bool{
+		"td":       {"tr": true},
+		"th":       {"tr": true},
+		"tr":       {"table": true, "thead": true, "tbody": true, "tfoot": true},
+		"thead":    {"table": true},
+		"tbody":    {"table": true},
+		"tfoot":    {"table": true},
+		"caption":  {"table": true},
+		"colgroup": {"table": true},
+		"li":       {"ul": true, "ol": true, "menu": true},
+		"dt":       {"dl": true},
+		"dd":       {"dl": true},
+	}
+
+	allowedTableHtmlElements = map[string]bool{
+		"caption": true, "colgroup": true, "col": true, "thead": true, "tbody": true, "tfoot": true,
+		"tr": true, "td": true, "th": true, "script": true, "style": true, "template": true,
+	}
+
+	blockHtmlElements = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "center": true,
+		"div": true, "dl": true, "fieldset": true, "figure": true, "footer": true, "form": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+		"hr": true, "main": true, "menu": true, "nav": true, "ol": true, "p": true, "pre": true,
+		"section": true, "table": true, "ul": true,
+	}
+
+	inlineHtmlElements = map[string]bool{
+		"a": true, "abbr": true, "b": true, "big": true, "cite": true, "code": true, "em": true,
+		"font": true, "i": true, "label": true, "p": true, "s": true, "small": true, "span": true,
+		"strike": true, "strong": true, "sub": true, "sup": true, "u": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+
+	// elements, which can not be nested in itself
+	selfNestingHtmlElements = map[string]bool{
+		"a": true, "form": true, "button": true, "label": true,
+	}
+
+	foreignHtmlElements = map[string]bool{
+		"svg": true, "math": true,
+	}
+)
+
+type HtmlDiagnostic struct {
+	Type        string `json:"type"`
+	Severity    string `json:"severity"`
+	Tag         string `json:"tag"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+	Line        int    `json:"line"`
+	File        string `json:"file,omitempty"`
+	Column      int    `json:"column"`
+	Snippet     string `json:"snippet"`
+	Context     string `json:"context"`
+}
+
+type openHtmlTag struct {
+	name     string
+	position SourcePosition
+	column   int
+}
+
+type rawHtmlAttribute struct {
+	key    string
+	source string
+	offset int // in raw tag
+}
+
+func (prs *ParserEngine) getColumnFromOffset(tagLine, offset int) int {
+	if tagLine < 1 || tagLine > len(prs.bytesToLine) {
+		return 1
+	}
+	return offset - prs.bytesToLine[tagLine-1] + 1
+}
+
+func (prs *ParserEngine) saveToReportHtmlDiagnostic(diagnosticType, severity, tagName, message string, position SourcePosition, column int) {
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	if len(prs.pr.HtmlDiagnostics) >= LIMIT_REPORT_DIAGNOSTICS {
+		prs.pr.HtmlDiagnosticsMore = true
+		return
+	}
+
+	prs.pr.HtmlDiagnostics = append(prs.pr.HtmlDiagnostics, HtmlDiagnostic{
+		Type:        diagnosticType,
+		Severity:    severity,
+		Tag:         tagName,
+		Message:     message,
+		Description: htmlDiagnosticsDescriptions[diagnosticType],
+		Line:        position.Line,
+		Column:      column,
+		Snippet:     position.Snippet,
+	})
+}
+
+func (prs *ParserEngine) reportUnclosedHtmlTag(tag openHtmlTag, closedBy string) {
+	severity := SEVERITY_ERROR
+	if optionalEndHtmlElements[tag.name] {
+		severity = SEVERITY_WARNING
+	}
+
+	if len(closedBy) == 0 {
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_UNCLOSED_TAG, severity, tag.name, fmt.Sprintf("<%s> is never closed", tag.name), tag.position, tag.column)
+		return
+	}
+
+	if inlineHtmlElements[tag.name] { // overlapped formatting elements
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_MISNESTED_TAG, severity, tag.name, fmt.Sprintf("<%s> is not closed before %s", tag.name, closedBy), tag.position, tag.column)
+	} else {
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_UNCLOSED_TAG, severity, tag.name, fmt.Sprintf("<%s> is not closed before %s", tag.name, closedBy), tag.position, tag.column)
+	}
+}
+
+func (prs *ParserEngine) htmlStackIndexOf(tagName string) int {
+	for i := len(prs.htmlTagsStack) - 1; i >= 0; i-- {
+		if prs.htmlTagsStack[i].name == tagName {
+			return i
+		}
+	}
+	return -1
+}
+
+func (prs *ParserEngine) isInsideForeignHtml() bool {
+	for _, tag := range prs.htmlTagsStack {
+		if foreignHtmlElements[tag.name] {
+			return true
+		}
+	}
+	return false
+}
+
+// rawHtmlAttributes return all attributes of the tag with its source, tokenizer drop duplicates
+func rawHtmlAttributes(raw []byte) []rawHtmlAttribute {
+	var (
+		attrs []rawHtmlAttribute
+		i     int = 1 // skip "<"
+	)
+
+	isSpace := func(c byte) bool {
+		return strings.IndexByte(WHITESPACE, c) >= 0
+	}
+
+	// skip tag name
+	for i < len(raw) && !isSpace(raw[i]) && raw[i] != '>' && raw[i] != '/' {
+		i++
+	}
+
+	for i < len(raw) {
+		for i < len(raw) && (isSpace(raw[i]) || raw[i] == '/') {
+			i++
+		}
+		if i >= len(raw) || raw[i] == '>' {
+			return attrs
+		}
+
+		keyStart := i
+		i++ // attribute name can start with "="
+		for i < len(raw) && !isSpace(raw[i]) && raw[i] != '=' && raw[i] != '>' && raw[i] != '/' {
+			i++
+		}
+		attrKey := strings.ToLower(string(raw[keyStart:i]))
+		keyEnd := i
+
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		if i >= len(raw) || raw[i] != '=' {
+			attrs = append(attrs, rawHtmlAttribute{key: attrKey, source: string(raw[keyStart:keyEnd]), offset: keyStart})
+			continue
+		}
+		i++ // skip "="
+		for i < len(raw) && isSpace(raw[i]) {
+			i++
+		}
+		if i < len(raw) && (raw[i] == '"' || raw[i] == '\'') {
+			quote := raw[i]
+			i++
+			for i < len(raw) && raw[i] != quote {
+				i++
+			}
+			i++ // skip closing quote
+		} else {
+			for i < len(raw) && !isSpace(raw[i]) && raw[i] != '>' {
+				i++
+			}
+		}
+		attrs = append(attrs, rawHtmlAttribute{key: attrKey, source: string(raw[keyStart:min(i, len(raw))]), offset: keyStart})
+	}
+
+	return attrs
+}
+
+func (prs *ParserEngine) checkDuplicateHtmlAttributes(raw []byte, tagName string, position SourcePosition, tagOffset, tagLine int) {
+	seenAttrs := make(map[string]bool)
+	for _, attr := range rawHtmlAttributes(raw) {
+		if seenAttrs[attr.key] {
+			// attributes of multiline tag can be on next lines
+			attrOffset := tagOffset + attr.offset
+			attrLine := prs.getLineFromOffset(tagLine, attrOffset)
+			position.Line = attrLine
+			prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_DUPLICATE_ATTRIBUTE, SEVERITY_WARNING, tagName, fmt.Sprintf("<%s> has duplicate attribute \"%s\"", tagName, attr.key), position.withSnippet(attr.source), prs.getColumnFromOffset(attrLine, attrOffset))
+		}
+		seenAttrs[attr.key] = true
+	}
+}
+
+func (prs *ParserEngine) checkHtmlStartTagStructure(tagName string, isSelfClosing bool, position SourcePosition, column int) {
+	// close elements with optional end tag
+	for len(prs.htmlTagsStack) > 0 {
+		top := prs.htmlTagsStack[len(prs.htmlTagsStack)-1]
+		if closers, ok := impliedEndHtmlElements[top.name]; ok && closers[tagName] {
+			prs.reportUnclosedHtmlTag(top, fmt.Sprintf("<%s>", tagName))
+			prs.htmlTagsStack = prs.htmlTagsStack[:len(prs.htmlTagsStack)-1]
+			continue
+		}
+		break
+	}
+
+	if len(prs.htmlTagsStack) > 0 {
+		parent := prs.htmlTagsStack[len(prs.htmlTagsStack)-1]
+
+		if allowedParents, ok := requiredParentHtmlElements[tagName]; ok && !allowedParents[parent.name] {
+			prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_INVALID_NESTING, SEVERITY_ERROR, tagName, fmt.Sprintf("<%s> is not allowed inside <%s>", tagName, parent.name), position, column)
+		} else if (parent.name == "table" || parent.name == "thead" || parent.name == "tbody" || parent.name == "tfoot" || parent.name == "tr") && !allowedTableHtmlElements[tagName] && !strings.Contains(tagName, ":") {
+			prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT, SEVERITY_ERROR, tagName, fmt.Sprintf("<%s> is placed directly inside <%s>", tagName, parent.name), position, column)
+		}
+	}
+
+	if selfNestingHtmlElements[tagName] && prs.htmlStackIndexOf(tagName) >= 0 {
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_INVALID_NESTING, SEVERITY_ERROR, tagName, fmt.Sprintf("<%s> is nested inside another <%s>", tagName, tagName), position, column)
+	}
+
+	if blockHtmlElements[tagName] {
+		for i := len(prs.htmlTagsStack) - 1; i >= 0; i-- {
+			parentName := prs.htmlTagsStack[i].name
+			if inlineHtmlElements[parentName] {
+				prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_INVALID_NESTING, SEVERITY_WARNING, tagName, fmt.Sprintf("block element <%s> is placed inside <%s>", tagName, parentName), position, column)
+				break
+			}
+			if blockHtmlElements[parentName] || parentName == "td" || parentName == "th" || parentName == "li" || parentName == "body" {
+				break
+			}
+		}
+	}
+
+	if voidHtmlElements[tagName] {
+		return
+	}
+
+	if isSelfClosing {
+		if strings.Contains(tagName, ":") || prs.isInsideForeignHtml() || foreignHtmlElements[tagName] {
+			return // xml elements (VML) and foreign content can be self closed
+		}
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_SELF_CLOSING_NON_VOID, SEVERITY_WARNING, tagName, fmt.Sprintf("<%s/> is not void element and stay open", tagName), position, column)
+	}
+
+	prs.htmlTagsStack = append(prs.htmlTagsStack, openHtmlTag{
+		name:     tagName,
+		position: position,
+		column:   column,
+	})
+}
+
+func (prs *ParserEngine) checkHtmlEndTagStructure(tagName string, position SourcePosition, column int) {
+	index := prs.htmlStackIndexOf(tagName)
+	if index < 0 {
+		if prs.options.Fragment {
+			return // start tag can be in other partial
+		}
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_STRAY_END_TAG, SEVERITY_ERROR, tagName, fmt.Sprintf("</%s> has no matching start tag", tagName), position, column)
+		return
+	}
+
+	for i := len(prs.htmlTagsStack) - 1; i > index; i-- {
+		prs.reportUnclosedHtmlTag(prs.htmlTagsStack[i], fmt.Sprintf("</%s>", tagName))
+	}
+	prs.htmlTagsStack = prs.htmlTagsStack[:index]
+}
+
+func (prs *ParserEngine) checkHtmlTextStructure(text string, position SourcePosition, column int) {
+	if len(prs.htmlTagsStack) == 0 || len(strings.Trim(text, WHITESPACE)) == 0 {
+		return
+	}
+
+	parent := prs.htmlTagsStack[len(prs.htmlTagsStack)-1]
+	switch parent.name {
+	case "table", "thead", "tbody", "tfoot", "tr":
+		prs.saveToReportHtmlDiagnostic(HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT, SEVERITY_ERROR, parent.name, fmt.Sprintf("text is placed directly inside <%s>", parent.name), position, column)
+	}
+}
+
+func (prs *ParserEngine) checkHtmlStructure(htmlTokenizer *html.Tokenizer, token html.Token, tagOffset, tagLine int) {
+	if prs.isHiddenConditional {
+		return // content of conditional comments is validated only by Outlook
+	}
+
+	column := prs.getColumnFromOffset(tagLine, tagOffset)
+	position := SourcePosition{Line: tagLine}.withSnippet(string(htmlTokenizer.Raw()))
+
+	switch token.Type {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		tagName := strings.ToLower(token.Data)
+		if len(tagName) == 0 {
+			return
+		}
+		prs.checkDuplicateHtmlAttributes(htmlTokenizer.Raw(), tagName, position, tagOffset, tagLine)
+		prs.checkHtmlStartTagStructure(tagName, token.Type == html.SelfClosingTagToken, position, column)
+	case html.EndTagToken:
+		tagName := strings.ToLower(token.Data)
+		if len(tagName) == 0 {
+			return
+		}
+		prs.checkHtmlEndTagStructure(tagName, position, column)
+	case html.TextToken:
+		prs.checkHtmlTextStructure(token.Data, position, column)
+	}
+}
+
+func (prs *ParserEngine) checkUnclosedHtmlTags() {
+	for i := len(prs.htmlTagsStack) - 1; i >= 0; i-- {
+		prs.reportUnclosedHtmlTag(prs.htmlTagsStack[i], "")
+	}
+	prs.htmlTagsStack = []openHtmlTag{}
+
+	sort.SliceStable(prs.pr.HtmlDiagnostics, func(i, j int) bool {
+		if prs.pr.HtmlDiagnostics[i].Line == prs.pr.HtmlDiagnostics[j].Line {
+			return prs.pr.HtmlDiagnostics[i].Column < prs.pr.HtmlDiagnostics[j].Column
+		}
+		return prs.pr.HtmlDiagnostics[i].Line < prs.pr.HtmlDiagnostics[j].Line
+	})
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFromHTMLDiagnostics(t *testing.T) {
+	html := `<html><body>
+	<table>
+		<tr>
+			<td>One
+			<td>Two</td>
+		</tr>
+		<div>Fostered</div>
+	</table>
+	</div>
+	<a href="https://example.com"><table><tr><td>Link</td></tr></table></a>
+	<b><i>Text</b></i>
+	<img src="a.png" alt="one" alt="two" />
+	<span/></span>
+	<!--[if mso]><table><tr><td><![endif]-->
+	<p>Ghost table</p>
+	<!--[if mso]></td></tr></table><![endif]-->
+	<div>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	got := make([][3]interface{}, 0, len(report.HtmlDiagnostics))
+	for _, diagnostic := range report.HtmlDiagnostics {
+		got = append(got, [3]interface{}{diagnostic.Type, diagnostic.Line, diagnostic.Column})
+	}
+
+	want := [][3]interface{}{
+		{HTML_DIAGNOSTIC_UNCLOSED_TAG, 4, 4},
+		{HTML_DIAGNOSTIC_INVALID_TABLE_CONTENT, 7, 3},
+		{HTML_DIAGNOSTIC_STRAY_END_TAG, 9, 2},
+		{HTML_DIAGNOSTIC_INVALID_NESTING, 10, 32},
+		{HTML_DIAGNOSTIC_MISNESTED_TAG, 11, 5},
+		{HTML_DIAGNOSTIC_STRAY_END_TAG, 11, 16},
+		{HTML_DIAGNOSTIC_DUPLICATE_ATTRIBUTE, 12, 29},
+		{HTML_DIAGNOSTIC_SELF_CLOSING_NON_VOID, 13, 2},
+		{HTML_DIAGNOSTIC_UNCLOSED_TAG, 17, 2},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HtmlDiagnostics: got %v, want %v", got, want)
+	}
+}
+
+func TestReportFromHTMLDuplicateAttributeOnNextLine(t *testing.T) {
+	html := `<div>
+  <img src="a.png"
+       alt="one"
+       alt="two">
+</div>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	got := make([][3]interface{}, 0, len(report.HtmlDiagnostics))
+	for _, diagnostic := range report.HtmlDiagnostics {
+		got = append(got, [3]interface{}{diagnostic.Type, diagnostic.Line, diagnostic.Column})
+	}
+
+	want := [][3]interface{}{
+		{HTML_DIAGNOSTIC_DUPLICATE_ATTRIBUTE, 4, 8},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HtmlDiagnostics: got %v, want %v", got, want)
+	}
+}
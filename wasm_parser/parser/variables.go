@@ -0,0 +1,312 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	css "github.com/tdewolff/parse/v2/css"
+)
+
+const (
+	LIMIT_REPORT_CSS_VARIABLES = 500
+	LIMIT_CSS_VARIABLES_DEPTH  = 16
+
+	CSS_VARIABLE_INLINE_SCOPE = "[style]"
+)
+
+// css variables usage issues
+const (
+	CSS_VARIABLE_ISSUE_UNDEFINED        = "undefined_variable"
+	CSS_VARIABLE_ISSUE_MISSING_FALLBACK = "missing_fallback"
+)
+
+var (
+	// definitions in this scopes available for whole document
+	cssVariablesGlobalScopes = map[string]bool{
+		":root": true, "html": true, "body": true, "*": true,
+	}
+)
+
+type CssVariableDefinition struct {
+	Name    string   `json:"name"`
+	Value   string   `json:"value"`
+	Scope   string   `json:"scope"` // selector or [style] for inline definitions
+	AtRules []string `json:"at_rules"`
+	Line    int      `json:"line"`
+	File    string   `json:"file,omitempty"`
+	Snippet string   `json:"snippet"`
+	Context string   `json:"context"`
+}
+
+type CssVariableUsage struct {
+	Name          string   `json:"name"`
+	Property      string   `json:"property"`
+	Selector      string   `json:"selector"`
+	Fallback      string   `json:"fallback"`
+	HasFallback   bool     `json:"has_fallback"`
+	IsDefined     bool     `json:"is_defined"`
+	ResolvedValue string   `json:"resolved_value"` // static value, which client without variables support lose
+	Issues        []string `json:"issues"`
+	Line          int      `json:"line"`
+	File          string   `json:"file,omitempty"`
+	Snippet       string   `json:"snippet"`
+	Context       string   `json:"context"`
+}
+
+type CssVariablesGraph struct {
+	Definitions        []CssVariableDefinition `json:"definitions"`
+	Usages             []CssVariableUsage      `json:"usages"`
+	UnsupportedClients []string                `json:"unsupported_clients"`
+	More               bool                    `json:"more"`
+}
+
+type cssVariableReference struct {
+	name        string
+	fallback    []css.Token
+	hasFallback bool
+}
+
+// cssTokensToValue restore value from tokens, parser drop whitespaces between them
+func cssTokensToValue(values []css.Token) string {
+	var (
+		buf       strings.Builder
+		needSpace bool
+	)
+	for _, val := range values {
+		switch val.TokenType {
+		case css.WhitespaceToken, css.CommentToken:
+			needSpace = buf.Len() > 0
+			continue
+		case css.CommaToken, css.RightParenthesisToken:
+			needSpace = false
+		}
+		if needSpace {
+			buf.WriteByte(' ')
+		}
+		buf.Write(val.Data)
+		needSpace = val.TokenType != css.FunctionToken && val.TokenType != css.LeftParenthesisToken
+	}
+	return buf.String()
+}
+
+func tokenizeCssValue(value string) []css.Token {
+	var tokens []css.Token
+	lexer := css.NewLexer(parse.NewInputString(value))
+	for {
+		tt, data := lexer.Next()
+		if tt == css.ErrorToken {
+			return tokens
+		}
+		tokens = append(tokens, css.Token{TokenType: tt, Data: append([]byte{}, data...)})
+	}
+}
+
+// parseCssVariableReferences find var() functions, including nested in fallbacks
+func parseCssVariableReferences(values []css.Token) []cssVariableReference {
+	var references []cssVariableReference
+
+	for i := 0; i < len(values); i++ {
+		if values[i].TokenType != css.FunctionToken || strings.ToLower(string(values[i].Data)) != "var(" {
+			continue
+		}
+
+		reference := cssVariableReference{}
+		depth := 1
+		fallbackStart := -1
+		j := i + 1
+		for ; j < len(values) && depth > 0; j++ {
+			switch values[j].TokenType {
+			case css.FunctionToken, css.LeftParenthesisToken:
+				depth += 1
+			case css.RightParenthesisToken:
+				depth -= 1
+			case css.CustomPropertyNameToken:
+				if len(reference.name) == 0 && fallbackStart < 0 {
+					reference.name = string(values[j].Data)
+				}
+			case css.CommaToken:
+				if depth == 1 && fallbackStart < 0 {
+					fallbackStart = j + 1
+					reference.hasFallback = true
+				}
+			}
+		}
+
+		if fallbackStart >= 0 {
+			fallbackEnd := j
+			if depth == 0 {
+				fallbackEnd = j - 1 // without closing parenthesis
+			}
+			reference.fallback = values[fallbackStart:fallbackEnd]
+		}
+
+		if len(reference.name) > 0 {
+			references = append(references, reference)
+		}
+		references = append(references, parseCssVariableReferences(reference.fallback)...)
+		i = j - 1
+	}
+
+	return references
+}
+
+func cssVariableScope(position SourcePosition) string {
+	if len(position.Selector) > 0 {
+		return position.Selector
+	}
+	return CSS_VARIABLE_INLINE_SCOPE
+}
+
+func (prs *ParserEngine) saveCssVariableDefinition(name string, values []css.Token, position SourcePosition) {
+	value := strings.Trim(cssTokensToString(values), WHITESPACE)
+
+	definition := CssVariableDefinition{
+		Name:    name,
+		Value:   value,
+		Scope:   cssVariableScope(position),
+		AtRules: position.AtRules,
+		Line:    position.Line,
+		Snippet: position.Snippet,
+	}
+
+	prs.mx.Lock()
+	prs.cssVariableDefinitions[name] = append(prs.cssVariableDefinitions[name], definition)
+	if len(prs.pr.CssVariablesGraph.Definitions) < LIMIT_REPORT_CSS_VARIABLES {
+		prs.pr.CssVariablesGraph.Definitions = append(prs.pr.CssVariablesGraph.Definitions, definition)
+	} else {
+		prs.pr.CssVariablesGraph.More = true
+	}
+	prs.mx.Unlock()
+
+	// variables can use other variables
+	prs.checkCssVariableUsages(name, tokenizeCssValue(value), position)
+}
+
+func (prs *ParserEngine) checkCssVariableUsages(propertyKey string, values []css.Token, position SourcePosition) {
+	references := parseCssVariableReferences(values)
+	if len(references) == 0 {
+		return
+	}
+
+	prs.mx.Lock()
+	defer prs.mx.Unlock()
+
+	for _, reference := range references {
+		if len(prs.pr.CssVariablesGraph.Usages) >= LIMIT_REPORT_CSS_VARIABLES {
+			prs.pr.CssVariablesGraph.More = true
+			return
+		}
+		prs.pr.CssVariablesGraph.Usages = append(prs.pr.CssVariablesGraph.Usages, CssVariableUsage{
+			Name:        reference.name,
+			Property:    strings.ToLower(propertyKey),
+			Selector:    position.Selector,
+			Fallback:    cssTokensToValue(reference.fallback),
+			HasFallback: reference.hasFallback,
+			Line:        position.Line,
+			Snippet:     position.Snippet,
+		})
+	}
+}
+
+// preferredCssVariableDefinition choose definition, which apply to the whole document
+func preferredCssVariableDefinition(definitions []CssVariableDefinition) CssVariableDefinition {
+	for _, definition := range definitions {
+		if cssVariablesGlobalScopes[definition.Scope] && len(definition.AtRules) == 0 {
+			return definition
+		}
+	}
+	for _, definition := range definitions {
+		if len(definition.AtRules) == 0 {
+			return definition
+		}
+	}
+	return definitions[0]
+}
+
+// resolveCssVariablesValue replace var() in value by static values
+func resolveCssVariablesValue(value string, definitions map[string][]CssVariableDefinition, visited map[string]bool) string {
+	if !strings.Contains(strings.ToLower(value), "var(") || len(visited) > LIMIT_CSS_VARIABLES_DEPTH {
+		return value
+	}
+
+	tokens := tokenizeCssValue(value)
+	var buf strings.Builder
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].TokenType != css.FunctionToken || strings.ToLower(string(tokens[i].Data)) != "var(" {
+			buf.Write(tokens[i].Data)
+			continue
+		}
+
+		// find end of var()
+		depth, j := 1, i+1
+		for ; j < len(tokens) && depth > 0; j++ {
+			switch tokens[j].TokenType {
+			case css.FunctionToken, css.LeftParenthesisToken:
+				depth += 1
+			case css.RightParenthesisToken:
+				depth -= 1
+			}
+		}
+		references := parseCssVariableReferences(tokens[i:j])
+		if len(references) > 0 {
+			buf.WriteString(resolveCssVariable(references[0], definitions, visited))
+		}
+		i = j - 1
+	}
+	return buf.String()
+}
+
+func resolveCssVariable(reference cssVariableReference, definitions map[string][]CssVariableDefinition, visited map[string]bool) string {
+	if defs, ok := definitions[reference.name]; ok && !visited[reference.name] {
+		visited[reference.name] = true
+		defer delete(visited, reference.name)
+		return resolveCssVariablesValue(preferredCssVariableDefinition(defs).Value, definitions, visited)
+	}
+	if reference.hasFallback {
+		return resolveCssVariablesValue(cssTokensToValue(reference.fallback), definitions, visited)
+	}
+	return ""
+}
+
+// checkCssVariablesGraph link usages with definitions, should be called after all css processed
+func (prs *ParserEngine) checkCssVariablesGraph() {
+	graph := &prs.pr.CssVariablesGraph
+
+	// style tags processed in parallel
+	sort.SliceStable(graph.Definitions, func(i, j int) bool {
+		return graph.Definitions[i].Line < graph.Definitions[j].Line
+	})
+	sort.SliceStable(graph.Usages, func(i, j int) bool {
+		return graph.Usages[i].Line < graph.Usages[j].Line
+	})
+
+	// report has limited list of definitions, usages checked against all of them
+	definitions := prs.cssVariableDefinitions
+	for _, defs := range definitions {
+		sort.SliceStable(defs, func(i, j int) bool {
+			return defs[i].Line < defs[j].Line
+		})
+	}
+
+	for i := range graph.Usages {
+		usage := &graph.Usages[i]
+		_, usage.IsDefined = definitions[usage.Name]
+		usage.ResolvedValue = resolveCssVariable(cssVariableReference{
+			name:        usage.Name,
+			fallback:    tokenizeCssValue(usage.Fallback),
+			hasFallback: usage.HasFallback,
+		}, definitions, make(map[string]bool))
+
+		if !usage.IsDefined {
+			usage.Issues = append(usage.Issues, CSS_VARIABLE_ISSUE_UNDEFINED)
+		}
+		if !usage.HasFallback {
+			usage.Issues = append(usage.Issues, CSS_VARIABLE_ISSUE_MISSING_FALLBACK)
+		}
+	}
+
+	if len(graph.Usages) > 0 {
+		graph.UnsupportedClients = clientsWithSupport(rulesDB.CssVariables, CLIENT_SUPPORT_NO)
+	}
+}
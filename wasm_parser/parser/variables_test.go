@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportFromHTMLCssVariablesGraph(t *testing.T) {
+	html := `<html>
+<head>
+	<style>
+		:root { --brand: #ff0000; --accent: var(--brand); }
+		@media (prefers-color-scheme: dark) {
+			:root { --brand: #00ff00; }
+		}
+		.button { color: var(--accent); border: var(--border-width, 1px) solid var(--border-color); }
+	</style>
+</head>
+<body>
+	<div style="--gap: 10px; padding: var(--gap) var(--missing, var(--gap, 5px))">Content</div>
+</body></html>`
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	// log.Printf("report: %v\n", report)
+
+	gotDefinitions := make([][4]interface{}, 0, len(report.CssVariablesGraph.Definitions))
+	for _, definition := range report.CssVariablesGraph.Definitions {
+		gotDefinitions = append(gotDefinitions, [4]interface{}{definition.Line, definition.Name, definition.Value, definition.Scope})
+	}
+
+	wantDefinitions := [][4]interface{}{
+		{4, "--brand", "#ff0000", ":root"},
+		{4, "--accent", "var(--brand)", ":root"},
+		{6, "--brand", "#00ff00", ":root"},
+		{12, "--gap", "10px", CSS_VARIABLE_INLINE_SCOPE},
+	}
+
+	if !reflect.DeepEqual(gotDefinitions, wantDefinitions) {
+		t.Errorf("CssVariablesGraph Definitions: got %v, want %v", gotDefinitions, wantDefinitions)
+	}
+
+	type usageSummary struct {
+		Line          int
+		Name          string
+		Property      string
+		Fallback      string
+		ResolvedValue string
+		Issues        []string
+	}
+
+	gotUsages := make([]usageSummary, 0, len(report.CssVariablesGraph.Usages))
+	for _, usage := range report.CssVariablesGraph.Usages {
+		gotUsages = append(gotUsages, usageSummary{usage.Line, usage.Name, usage.Property, usage.Fallback, usage.ResolvedValue, usage.Issues})
+	}
+
+	wantUsages := []usageSummary{
+		{4, "--brand", "--accent", "", "#ff0000", []string{CSS_VARIABLE_ISSUE_MISSING_FALLBACK}},
+		{8, "--accent", "color", "", "#ff0000", []string{CSS_VARIABLE_ISSUE_MISSING_FALLBACK}},
+		{8, "--border-width", "border", "1px", "1px", []string{CSS_VARIABLE_ISSUE_UNDEFINED}},
+		{8, "--border-color", "border", "", "", []string{CSS_VARIABLE_ISSUE_UNDEFINED, CSS_VARIABLE_ISSUE_MISSING_FALLBACK}},
+		{12, "--gap", "padding", "", "10px", []string{CSS_VARIABLE_ISSUE_MISSING_FALLBACK}},
+		{12, "--missing", "padding", "var(--gap, 5px)", "10px", []string{CSS_VARIABLE_ISSUE_UNDEFINED}},
+		{12, "--gap", "padding", "5px", "10px", nil},
+	}
+
+	if !reflect.DeepEqual(gotUsages, wantUsages) {
+		t.Errorf("CssVariablesGraph Usages: got %v, want %v", gotUsages, wantUsages)
+	}
+
+	if len(report.CssVariablesGraph.UnsupportedClients) == 0 {
+		t.Errorf("CssVariablesGraph UnsupportedClients: expected clients without variables support")
+	}
+}
+
+func TestReportFromHTMLCssVariablesOverLimit(t *testing.T) {
+	var styles strings.Builder
+	for i := 0; i <= LIMIT_REPORT_CSS_VARIABLES; i++ {
+		fmt.Fprintf(&styles, "<style>.v%d { --v%d: %dpx; }</style>\n", i, i, i)
+	}
+	html := styles.String() + fmt.Sprintf(`<p style="padding: var(--v%d, 0)">Text</p>`, LIMIT_REPORT_CSS_VARIABLES)
+
+	report, err := ReportFromHTML([]byte(html))
+	if err != nil {
+		t.Fatalf(`ReportFromHTML("%s"), %v`, html, err)
+	}
+
+	graph := report.CssVariablesGraph
+	if len(graph.Definitions) != LIMIT_REPORT_CSS_VARIABLES || !graph.More {
+		t.Errorf("CssVariablesGraph Definitions: got %d (more %v), want %d", len(graph.Definitions), graph.More, LIMIT_REPORT_CSS_VARIABLES)
+	}
+	if len(graph.Usages) != 1 || !graph.Usages[0].IsDefined || graph.Usages[0].ResolvedValue != fmt.Sprintf("%dpx", LIMIT_REPORT_CSS_VARIABLES) {
+		t.Errorf("CssVariablesGraph Usages: got %+v, want defined --v%d", graph.Usages, LIMIT_REPORT_CSS_VARIABLES)
+	}
+}
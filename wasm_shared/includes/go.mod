@@ -0,0 +1,3 @@
+module github.com/le0pard/vmail/wasm_shared/includes
+
+go 1.25.0
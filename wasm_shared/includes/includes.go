@@ -0,0 +1,99 @@
+package includes
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MapFS is in-memory file system of include partials: file path to its content.
+// Directories are implied by file paths
+type MapFS map[string][]byte
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi fileInfo) Name() string               { return fi.name }
+func (fi fileInfo) Size() int64                { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode          { return fi.mode }
+func (fi fileInfo) ModTime() time.Time         { return time.Time{} }
+func (fi fileInfo) IsDir() bool                { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() interface{}           { return nil }
+func (fi fileInfo) Type() fs.FileMode          { return fi.mode.Type() }
+func (fi fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+type file struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+type dir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if count > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(entries) {
+		entries = entries[:count]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+func (fsys MapFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if content, ok := fsys[name]; ok {
+		return &file{Reader: bytes.NewReader(content), info: fileInfo{name: path.Base(name), size: int64(len(content)), mode: 0444}}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := make(map[string]fileInfo)
+	for filePath, content := range fsys {
+		rest, found := strings.CutPrefix(filePath, prefix)
+		if !found || len(rest) == 0 {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			children[child] = fileInfo{name: child, mode: fs.ModeDir | 0555}
+		} else {
+			children[child] = fileInfo{name: child, size: int64(len(content)), mode: 0444}
+		}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	d := &dir{info: fileInfo{name: path.Base(name), mode: fs.ModeDir | 0555}}
+	for _, child := range children {
+		d.entries = append(d.entries, child)
+	}
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].Name() < d.entries[j].Name() })
+	return d, nil
+}
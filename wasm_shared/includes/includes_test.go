@@ -0,0 +1,30 @@
+package includes
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMapFS(t *testing.T) {
+	fsys := MapFS{
+		"header.html":          []byte("<table><tr><td>Logo</td></tr></table>"),
+		"partials/footer.hbs":  []byte("<p>Footer</p>"),
+		"partials/social.html": []byte(""),
+	}
+
+	if err := fstest.TestFS(fsys, "header.html", "partials/footer.hbs", "partials/social.html"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := fs.ReadFile(fsys, "partials/footer.hbs")
+	if err != nil || string(content) != "<p>Footer</p>" {
+		t.Errorf("ReadFile: got %q, error %v", content, err)
+	}
+	if _, err := fs.ReadFile(fsys, "missing.html"); err == nil {
+		t.Errorf("ReadFile: expected error for missing file")
+	}
+	if _, err := fs.ReadFile(fsys, "../header.html"); err == nil {
+		t.Errorf("ReadFile: expected error for invalid path")
+	}
+}